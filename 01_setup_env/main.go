@@ -6,17 +6,16 @@ import (
 	"go-orm-demo/shared"
 
 	"github.com/ESGI-M2/GO/orm/builder"
-	"github.com/ESGI-M2/GO/orm/factory"
 )
 
 func main() {
 	cfgBuilder := builder.NewConfigBuilder().
-		WithDialect(factory.MySQL).
+		WithDialect(shared.DialectFromEnv()).
 		WithEnvFile("../shared/env.sample").
 		FromEnv().
 		WithAutoCreateDatabase()
 
-	ormBuilder := builder.NewSimpleORM().
+	ormBuilder := shared.NewSimpleORM().
 		WithConfigBuilder(cfgBuilder)
 
 	// Connect to the database
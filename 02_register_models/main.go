@@ -6,24 +6,28 @@ import (
 	"go-orm-demo/shared"
 
 	"github.com/ESGI-M2/GO/orm/builder"
-	"github.com/ESGI-M2/GO/orm/factory"
 )
 
 func main() {
 	cfg := builder.NewConfigBuilder().
-		WithDialect(factory.MySQL).
+		WithDialect(shared.DialectFromEnv()).
 		WithEnvFile("../shared/env.sample").
 		FromEnv().
 		WithAutoCreateDatabase()
 
-	orm := builder.NewSimpleORM().
+	// Post references users through a foreign key, which the library's DDL renders
+	// inline where MySQL rejects it, so Post is migrated by shared.Migrate instead
+	orm := shared.NewSimpleORM().
 		WithConfigBuilder(cfg).
-		RegisterModels(&shared.User{}, &shared.Post{})
+		RegisterModels(&shared.User{})
 
 	if err := orm.Connect(); err != nil {
 		log.Fatalf("connect: %v", err)
 	}
 	defer orm.Close()
+	if err := shared.Migrate(orm.GetORM(), &shared.Post{}); err != nil {
+		log.Fatalf("migrate: %v", err)
+	}
 
 	shared.Pretty("status", "models registered and migrated successfully")
 }
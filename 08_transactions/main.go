@@ -9,16 +9,15 @@ import (
 
 	ormcore "github.com/ESGI-M2/GO/orm"
 	"github.com/ESGI-M2/GO/orm/builder"
-	"github.com/ESGI-M2/GO/orm/factory"
 )
 
 func main() {
 	cfg := builder.NewConfigBuilder().
-		WithDialect(factory.MySQL).
+		WithDialect(shared.DialectFromEnv()).
 		WithEnvFile("../shared/env.sample").
 		FromEnv()
 
-	orm := builder.NewSimpleORM().
+	orm := shared.NewSimpleORM().
 		WithConfigBuilder(cfg).
 		RegisterModels(&shared.User{})
 
@@ -6,16 +6,15 @@ import (
 	"go-orm-demo/shared"
 
 	"github.com/ESGI-M2/GO/orm/builder"
-	"github.com/ESGI-M2/GO/orm/factory"
 )
 
 func main() {
 	cfg := builder.NewConfigBuilder().
-		WithDialect(factory.MySQL).
+		WithDialect(shared.DialectFromEnv()).
 		WithEnvFile("../shared/env.sample").
 		FromEnv()
 
-	ormBuilder := builder.NewSimpleORM().
+	ormBuilder := shared.NewSimpleORM().
 		WithConfigBuilder(cfg).
 		RegisterModels(&shared.User{})
 
@@ -28,14 +27,14 @@ func main() {
 	repo := orm.Repository(&shared.User{})
 	shared.SeedBulkUsers(repo, 3)
 
-	// raw select
-	rows, err := orm.Raw("SELECT id, name, age FROM user ORDER BY id DESC LIMIT 5").Find()
+	// raw select; {{table User}} resolves to the table the ORM actually uses ("users")
+	rows, err := shared.Raw(orm, "SELECT id, name, age FROM {{table User}} ORDER BY id DESC LIMIT 5").Find()
 	if err != nil {
 		log.Fatalf("raw select: %v", err)
 	}
 	shared.Pretty("latest 5 users via raw SQL", rows)
 
 	// aggregate
-	avgAgeRes, _ := orm.Raw("SELECT AVG(age) as avg_age FROM user").Find()
+	avgAgeRes, _ := shared.Raw(orm, "SELECT AVG(age) as avg_age FROM {{table User}}").Find()
 	shared.Pretty("average age", avgAgeRes)
 }
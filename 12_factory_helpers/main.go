@@ -15,9 +15,15 @@ func main() {
 	df := factory.NewDialectFactory()
 	shared.Pretty("available dialects", df.GetAvailableDialects())
 
+	// The factory only builds MySQL and Postgres connections
+	if shared.DialectFromEnv() == shared.SQLite {
+		fmt.Println("config builder and quick setup: skipped, the library's factory has no SQLite dialect")
+		return
+	}
+
 	// ConfigBuilder workflow
 	cfgBuilder := builder.NewConfigBuilder().
-		WithDialect(factory.MySQL).
+		WithDialect(shared.DialectFromEnv()).
 		WithEnvFile("../shared/env.sample").
 		FromEnv().
 		WithAutoCreateDatabase()
@@ -29,7 +35,7 @@ func main() {
 	shared.Pretty("built config", map[string]interface{}{"config": cfg, "dialect": dialectType, "autoCreate": autoCreate})
 
 	// QuickSetupFromEnv helper
-	quickORM, err := builder.QuickSetupFromEnv("mysql", &shared.User{})
+	quickORM, err := builder.QuickSetupFromEnv(string(shared.DialectFromEnv()), &shared.User{})
 	if err != nil {
 		log.Fatalf("quick setup: %v", err)
 	}
@@ -9,17 +9,17 @@ import (
 	"go-orm-demo/shared"
 
 	"github.com/ESGI-M2/GO/orm/builder"
-	"github.com/ESGI-M2/GO/orm/factory"
 	mysql "github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
 )
 
 func main() {
 	cfg := builder.NewConfigBuilder().
-		WithDialect(factory.MySQL).
+		WithDialect(shared.DialectFromEnv()).
 		WithEnvFile("../shared/env.sample").
 		FromEnv()
 
-	orm := builder.NewSimpleORM().
+	orm := shared.NewSimpleORM().
 		WithConfigBuilder(cfg).
 		RegisterModels(&shared.User{})
 
@@ -65,5 +65,9 @@ func main() {
 		if errors.As(err, &mysqlErr) {
 			fmt.Println("MySQL error code:", mysqlErr.Number)
 		}
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			fmt.Println("PostgreSQL error code:", pqErr.Code)
+		}
 	}
 }
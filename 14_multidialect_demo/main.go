@@ -7,6 +7,7 @@ import (
 
 	"go-orm-demo/shared"
 
+	ormcore "github.com/ESGI-M2/GO/orm"
 	"github.com/ESGI-M2/GO/orm/builder"
 	"github.com/ESGI-M2/GO/orm/factory"
 )
@@ -34,8 +35,11 @@ func runMySQL() {
 	shared.Pretty("mysql user", u)
 }
 
+// runMock connects factory.Mock through shared.NewSimpleORM, which backs it with a
+// fresh SQLite ":memory:" database: queries are evaluated, constraints enforced and
+// failed transactions rolled back, where the library's mock dialect returns no rows.
 func runMock() {
-	orm := builder.NewSimpleORM().
+	orm := shared.NewSimpleORM().
 		WithDialect(factory.Mock).
 		RegisterModels(&shared.User{})
 
@@ -46,11 +50,44 @@ func runMock() {
 	defer orm.Close()
 
 	fmt.Println("dialect:", orm.GetDialectType())
-	repo := orm.GetORM().Repository(&shared.User{})
-	u := &shared.User{Name: "Mocker", Email: "mock@example.com", CreatedAt: time.Now()}
+	db := orm.GetORM()
+	repo := shared.NewRepository(db, &shared.User{})
+	shared.SeedAdvancedUsers(repo)
+
+	u := &shared.User{Name: "Mocker", Email: "mock@example.com", Age: 33, CreatedAt: time.Now()}
 	_ = repo.Save(u)
-	rows, _ := repo.Find(u.ID)
-	shared.Pretty("mock find", rows)
+	fmt.Println("auto-increment id:", u.ID)
+	found, _ := repo.Get(u.ID)
+	shared.Pretty("mock get", found)
+
+	rows, _ := db.Query(&shared.User{}).
+		WhereIn("name", []interface{}{"Anna", "Brian", "Clara", "Eve"}).
+		WhereBetween("age", 20, 30).
+		WhereLike("name", "%a%").
+		OrderBy("age", "DESC").
+		Limit(2).
+		Offset(1).
+		Find()
+	shared.Pretty("mock filtered page", rows)
+	older, _ := db.Query(&shared.User{}).Where("age", ">", 26).Count()
+	fmt.Println("users older than 26:", older)
+
+	if err := repo.Save(&shared.User{Name: "Dup", Email: "mock@example.com", CreatedAt: time.Now()}); err != nil {
+		fmt.Printf("unique constraint enforced: %v\n", err)
+	}
+
+	// Soft-deleted rows stay in the table but leave the scoped queries
+	_ = repo.SoftDelete(u)
+	gone, _ := repo.Find(u.ID)
+	trashed, _ := repo.Query().OnlyTrashed().Count()
+	fmt.Printf("after soft delete: found %v, %d trashed\n", gone != nil, trashed)
+
+	_ = db.Transaction(func(tx ormcore.ORM) error {
+		_ = tx.Repository(&shared.User{}).Save(&shared.User{Name: "Ghost", Email: "ghost@example.com", CreatedAt: time.Now()})
+		return fmt.Errorf("simulated error to rollback")
+	})
+	ghosts, _ := db.Query(&shared.User{}).Where("name", "=", "Ghost").Count()
+	fmt.Printf("rolled back users: %d (expect 0)\n", ghosts)
 }
 
 func runPostgres() {
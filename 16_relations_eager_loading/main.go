@@ -8,43 +8,43 @@ import (
 	"go-orm-demo/shared"
 
 	"github.com/ESGI-M2/GO/orm/builder"
-	"github.com/ESGI-M2/GO/orm/factory"
 )
 
 func main() {
 	cfg := builder.NewConfigBuilder().
-		WithDialect(factory.MySQL).
+		WithDialect(shared.DialectFromEnv()).
 		WithEnvFile("../shared/env.sample").
 		FromEnv()
 
-	orm := builder.NewSimpleORM().
+	orm := shared.NewSimpleORM().
 		WithConfigBuilder(cfg).
-		RegisterModels(&shared.User{}, &shared.Post{})
+		RegisterModels(&shared.User{})
 
 	if err := orm.Connect(); err != nil {
 		log.Fatalf("connect: %v", err)
 	}
 	defer orm.Close()
 
-	// Recreate tables for a clean run
+	// Recreate tables for a clean run; posts reference users, so they go through
+	// shared.Migrate, which renders the foreign key as a table constraint
 	_ = orm.GetORM().DropTable(&shared.Post{})
 	_ = orm.GetORM().DropTable(&shared.User{})
-	_ = orm.GetORM().CreateTable(&shared.User{})
-	_ = orm.GetORM().CreateTable(&shared.Post{})
+	if err := shared.Migrate(orm.GetORM(), &shared.User{}, &shared.Post{}); err != nil {
+		log.Fatalf("migrate: %v", err)
+	}
 
-	userRepo := orm.GetORM().Repository(&shared.User{})
-	postRepo := orm.GetORM().Repository(&shared.Post{})
+	userRepo := shared.NewRepository(orm.GetORM(), &shared.User{})
 
-	u := &shared.User{Name: "Eager", Email: fmt.Sprintf("eager_%d@example.com", time.Now().UnixNano()), Age: 28, CreatedAt: time.Now()}
-	if err := userRepo.Save(u); err != nil {
+	u := &shared.User{Name: "Eager", Email: fmt.Sprintf("eager_%d@example.com", time.Now().UnixNano()), Age: 28, CreatedAt: time.Now(),
+		Posts: []shared.Post{
+			{Title: "First", Content: "first"},
+			{Title: "Second", Content: "second"},
+		}}
+	// Saves the user, then its posts with user_id set, in one transaction
+	if err := userRepo.WithAssociations().Save(u); err != nil {
 		log.Printf("save user err: %v", err)
 	}
 
-	p1 := &shared.Post{Title: "First", Content: "first", UserID: u.ID, CreatedAt: time.Now()}
-	p2 := &shared.Post{Title: "Second", Content: "second", UserID: u.ID, CreatedAt: time.Now()}
-	_ = postRepo.Save(p1)
-	_ = postRepo.Save(p2)
-
 	// Eager load posts when fetching user
 	result, err := userRepo.FindWithRelations(u.ID, "Posts")
 	if err != nil {
@@ -53,8 +53,7 @@ func main() {
 	shared.Pretty("user with posts", result)
 
 	// WithCount example – count posts per user
-	qb := orm.GetORM().Query(&shared.User{}).WithCount("Posts")
-	users, err := qb.Find()
+	users, err := userRepo.Query().WithCount("Posts").Find()
 	if err != nil {
 		log.Printf("WithCount err: %v", err)
 	}
@@ -3,23 +3,37 @@ package main
 import (
 	"fmt"
 	"log"
+	"time"
+
+	"go-orm-demo/shared"
 
 	"github.com/ESGI-M2/GO/orm/builder"
-	"github.com/ESGI-M2/GO/orm/factory"
 )
 
 func main() {
 	cfg := builder.NewConfigBuilder().
-		WithDialect(factory.MySQL).
+		WithDialect(shared.DialectFromEnv()).
 		WithEnvFile("../shared/env.sample").
 		FromEnv()
 
-	orm := builder.NewSimpleORM().WithConfigBuilder(cfg)
+	orm := shared.NewSimpleORM().
+		WithConfigBuilder(cfg).
+		RegisterModels(&shared.User{})
 	if err := orm.Connect(); err != nil {
 		log.Fatalf("connect: %v", err)
 	}
 	defer orm.Close()
 
+	// Recreate the posts table for a clean run; it references users
+	_ = orm.GetORM().DropTable(&shared.Post{})
+	if err := shared.Migrate(orm.GetORM(), &shared.Post{}); err != nil {
+		log.Fatalf("migrate: %v", err)
+	}
+	author := &shared.User{Name: "Helper", Email: fmt.Sprintf("helper_%d@example.com", time.Now().UnixNano()), CreatedAt: time.Now()}
+	if err := orm.GetORM().Repository(&shared.User{}).Save(author); err != nil {
+		log.Fatalf("save author: %v", err)
+	}
+
 	d := orm.GetORM().GetDialect()
 
 	// Show helper strings (they would be embedded in SQL normally)
@@ -29,4 +43,26 @@ func main() {
 	// Example full-text search clause
 	ft := d.FullTextSearch("content", "demo")
 	fmt.Println("FullTextSearch sample:", ft)
+
+	// The same helpers as builder expressions, with values bound as arguments
+	shared.SeedPosts(orm.GetORM().Repository(&shared.Post{}), author.ID)
+	ex := shared.ExprFor(d)
+
+	todayRandom := shared.Apply(orm.GetORM().Query(&shared.Post{}),
+		ex.WhereDate("created_at", "=", time.Now()),
+		ex.OrderByRandom(),
+	).Limit(2)
+	fmt.Println("WhereDate + OrderByRandom:", todayRandom.GetSQL(), todayRandom.GetArgs())
+	rows, err := todayRandom.Find()
+	if err != nil {
+		log.Printf("date/random query: %v", err)
+	}
+	shared.Pretty("posts created today, random order", rows)
+
+	// Rendering only: these need a JSON column and a full-text index
+	jsonQ := shared.Apply(orm.GetORM().Query(&shared.Post{}), ex.WhereJSON("meta", "$.plan", "=", "pro"))
+	fmt.Println("WhereJSON:", jsonQ.GetSQL(), jsonQ.GetArgs())
+
+	ftQ := shared.Apply(orm.GetORM().Query(&shared.Post{}), ex.WhereFullText([]string{"title", "content"}, "demo"))
+	fmt.Println("WhereFullText:", ftQ.GetSQL(), ftQ.GetArgs())
 }
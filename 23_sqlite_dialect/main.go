@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"go-orm-demo/shared"
+
+	ormcore "github.com/ESGI-M2/GO/orm"
+)
+
+func main() {
+	// ":memory:" needs no file and no server; pass a path such as "demo.db" to keep the data
+	orm, err := shared.NewSQLiteORM(":memory:", &shared.User{}, &shared.Post{})
+	if err != nil {
+		log.Fatalf("sqlite setup: %v", err)
+	}
+	defer orm.Close()
+
+	userRepo := orm.Repository(&shared.User{})
+	postRepo := orm.Repository(&shared.Post{})
+
+	u := &shared.User{Name: "Lite", Email: fmt.Sprintf("lite_%d@example.com", time.Now().UnixNano()), Age: 33, CreatedAt: time.Now()}
+	if err := userRepo.Save(u); err != nil {
+		log.Fatalf("save user: %v", err)
+	}
+	shared.Pretty("saved user", u)
+
+	// relations
+	shared.SeedPosts(postRepo, u.ID)
+	withPosts, err := userRepo.FindWithRelations(u.ID, "Posts")
+	if err != nil {
+		log.Fatalf("find with relations: %v", err)
+	}
+	shared.Pretty("user with posts", withPosts)
+
+	// transaction commit and rollback
+	_ = orm.Transaction(func(tx ormcore.ORM) error {
+		return tx.Repository(&shared.User{}).Save(&shared.User{Name: "TxLite", Email: "txlite@example.com", CreatedAt: time.Now()})
+	})
+	_ = orm.Transaction(func(tx ormcore.ORM) error {
+		_ = tx.Repository(&shared.User{}).Save(&shared.User{Name: "TxRollback", Email: "txrollback@example.com", CreatedAt: time.Now()})
+		return fmt.Errorf("simulated error to rollback")
+	})
+	committed, _ := orm.Query(&shared.User{}).Where("name", "=", "TxLite").Count()
+	rolledBack, _ := orm.Query(&shared.User{}).Where("name", "=", "TxRollback").Count()
+	fmt.Printf("committed users: %d (expect 1), rolled back users: %d (expect 0)\n", committed, rolledBack)
+
+	// soft delete and restore
+	_ = userRepo.SoftDelete(u)
+	trashed, _ := userRepo.FindTrashed()
+	shared.Pretty("trashed list", trashed)
+
+	_ = userRepo.Restore(u)
+	restored, _ := userRepo.Find(u.ID)
+	shared.Pretty("restored user", restored)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go-orm-demo/shared"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	ormcore "github.com/ESGI-M2/GO/orm"
+	mysql "github.com/go-sql-driver/mysql"
+)
+
+func main() {
+	orm, db, err := shared.NewSQLMockORM(&shared.User{})
+	if err != nil {
+		log.Fatalf("sqlmock setup: %v", err)
+	}
+	defer orm.Close()
+
+	repo := orm.Repository(&shared.User{})
+	now := time.Now()
+
+	// 1. Insert and read back
+	db.Mock.ExpectExec(`INSERT INTO users \(name, email, age, created_at, deleted_at\)`).
+		WithArgs("Mocked", "mocked@example.com", 0, sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(42, 1))
+	db.Mock.ExpectQuery(`SELECT \* FROM users WHERE id = \?`).
+		WithArgs(42).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email"}).AddRow(42, "Mocked", "mocked@example.com"))
+
+	u := &shared.User{Name: "Mocked", Email: "mocked@example.com", CreatedAt: now}
+	if err := repo.Save(u); err != nil {
+		log.Fatalf("save: %v", err)
+	}
+	found, _ := repo.Find(u.ID)
+	shared.Pretty("found mocked user", found)
+
+	// 2. Duplicate key on insert
+	db.Mock.ExpectExec(`INSERT INTO users`).
+		WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'mocked@example.com' for key 'email'"})
+
+	err = repo.Save(&shared.User{Name: "Dup", Email: "mocked@example.com", CreatedAt: now})
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		fmt.Println("duplicate key error code:", mysqlErr.Number)
+	}
+
+	// 3. Deadlock inside a transaction rolls back
+	db.Mock.ExpectBegin()
+	db.Mock.ExpectExec(`UPDATE users SET`).
+		WillReturnError(&mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"})
+	db.Mock.ExpectRollback()
+
+	err = orm.Transaction(func(tx ormcore.ORM) error {
+		u.Name = "Renamed"
+		return tx.Repository(&shared.User{}).Update(u)
+	})
+	if errors.As(err, &mysqlErr) {
+		fmt.Println("deadlock error code:", mysqlErr.Number)
+	}
+
+	if err := db.ExpectationsWereMet(); err != nil {
+		log.Fatalf("unmet expectations: %v", err)
+	}
+	fmt.Println("all expectations were met")
+
+	shared.Pretty("recorded statements", db.GetLogs())
+}
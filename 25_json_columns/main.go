@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"go-orm-demo/shared"
+
+	"github.com/ESGI-M2/GO/orm/builder"
+)
+
+// AccountSettings is stored as a single JSON document
+type AccountSettings struct {
+	Plan          string   `json:"plan"`
+	Theme         string   `json:"theme"`
+	Notifications bool     `json:"notifications"`
+	Tags          []string `json:"tags"`
+}
+
+// Account keeps its settings in a JSON column
+type Account struct {
+	ID       int                          `table:"accounts" orm:"pk,auto"`
+	Email    string                       `orm:"column:email,unique"`
+	Settings shared.JSON[AccountSettings] `orm:"column:settings,json"`
+}
+
+func main() {
+	cfg := builder.NewConfigBuilder().
+		WithDialect(shared.DialectFromEnv()).
+		WithEnvFile("../shared/env.sample").
+		FromEnv()
+
+	orm := shared.NewSimpleORM().
+		WithConfigBuilder(cfg).
+		RegisterModels(&Account{})
+	if err := orm.Connect(); err != nil {
+		log.Fatalf("connect: %v", err)
+	}
+	defer orm.Close()
+
+	// The ORM creates the column as TEXT; switch it to JSON / JSONB
+	if err := shared.MigrateJSONColumns(orm.GetORM(), &Account{}); err != nil {
+		log.Fatalf("json columns: %v", err)
+	}
+
+	repo := orm.GetORM().Repository(&Account{})
+	suffix := time.Now().UnixNano()
+	accounts := []*Account{
+		{Email: fmt.Sprintf("pro_%d@example.com", suffix), Settings: shared.NewJSON(AccountSettings{Plan: "pro", Theme: "dark", Notifications: true, Tags: []string{"beta"}})},
+		{Email: fmt.Sprintf("free_%d@example.com", suffix), Settings: shared.NewJSON(AccountSettings{Plan: "free", Theme: "light"})},
+	}
+	for _, a := range accounts {
+		if err := repo.Save(a); err != nil {
+			log.Fatalf("save account: %v", err)
+		}
+	}
+
+	// Find returns a map; Decode unmarshals the settings back into the struct
+	row, err := repo.Find(accounts[0].ID)
+	if err != nil {
+		log.Fatalf("find account: %v", err)
+	}
+	var loaded Account
+	if err := shared.Decode(row.(map[string]interface{}), &loaded); err != nil {
+		log.Fatalf("decode account: %v", err)
+	}
+	fmt.Printf("loaded %s: plan=%s theme=%s tags=%v\n", loaded.Email, loaded.Settings.Data.Plan, loaded.Settings.Data.Theme, loaded.Settings.Data.Tags)
+
+	// Update a nested value and save the whole document again
+	loaded.Settings.Data.Theme = "solarized"
+	if err := repo.Update(&loaded); err != nil {
+		log.Fatalf("update account: %v", err)
+	}
+
+	// Query by a path inside the document
+	ex := shared.ExprFor(orm.GetORM().GetDialect())
+	q := shared.Apply(orm.GetORM().Query(&Account{}), ex.WhereJSON("settings", "$.plan", "=", "pro"))
+	fmt.Println("WhereJSON:", q.GetSQL(), q.GetArgs())
+	rows, err := q.Find()
+	if err != nil {
+		log.Fatalf("json query: %v", err)
+	}
+	var pros []Account
+	if err := shared.DecodeAll(rows, &pros); err != nil {
+		log.Fatalf("decode accounts: %v", err)
+	}
+	shared.Pretty("pro accounts", pros)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"go-orm-demo/shared"
+
+	"github.com/ESGI-M2/GO/orm/builder"
+)
+
+func main() {
+	cfg := builder.NewConfigBuilder().
+		WithDialect(shared.DialectFromEnv()).
+		WithEnvFile("../shared/env.sample").
+		FromEnv()
+
+	orm := shared.NewSimpleORM().
+		WithConfigBuilder(cfg).
+		RegisterModels(&shared.User{})
+	if err := orm.Connect(); err != nil {
+		log.Fatalf("connect: %v", err)
+	}
+	defer orm.Close()
+
+	// Recreate the posts table for a clean run; it references users
+	_ = orm.GetORM().DropTable(&shared.Post{})
+	if err := shared.Migrate(orm.GetORM(), &shared.Post{}); err != nil {
+		log.Fatalf("migrate: %v", err)
+	}
+	author := &shared.User{Name: "Writer", Email: fmt.Sprintf("writer_%d@example.com", time.Now().UnixNano()), CreatedAt: time.Now()}
+	if err := orm.GetORM().Repository(&shared.User{}).Save(author); err != nil {
+		log.Fatalf("save author: %v", err)
+	}
+
+	// Post.Title and Post.Content are tagged `fulltext`
+	if err := shared.MigrateFullTextIndexes(orm.GetORM(), &shared.Post{}); err != nil {
+		log.Fatalf("full-text index: %v", err)
+	}
+
+	repo := orm.GetORM().Repository(&shared.Post{})
+	posts := []*shared.Post{
+		{Title: "Go ORM demo", Content: "A demo of the query builder and a second demo of transactions", UserID: author.ID, CreatedAt: time.Now()},
+		{Title: "Release notes", Content: "Bug fixes, plus a short demo of soft deletes", UserID: author.ID, CreatedAt: time.Now()},
+		{Title: "Database tuning", Content: "Indexes, pools and query plans", UserID: author.ID, CreatedAt: time.Now()},
+	}
+	for _, p := range posts {
+		if err := repo.Save(p); err != nil {
+			log.Fatalf("save post: %v", err)
+		}
+	}
+
+	search := shared.NewRepository(orm.GetORM(), &shared.Post{}).Query().Search("demo")
+	fmt.Println("Search:", search.Builder().GetSQL(), search.Builder().GetArgs())
+	rows, err := search.Find()
+	if err != nil {
+		log.Fatalf("search: %v", err)
+	}
+	for _, row := range rows {
+		var p shared.Post
+		if err := shared.Decode(row, &p); err != nil {
+			log.Fatalf("decode post: %v", err)
+		}
+		fmt.Printf("%-16s relevance=%v\n", p.Title, row["relevance"])
+	}
+
+	// The search is a query on the model: conditions and limits chain onto it
+	best, err := search.Where("title", "<>", "Go ORM demo").Limit(1).Find()
+	if err != nil {
+		log.Fatalf("search: %v", err)
+	}
+	fmt.Println("best match other than the ORM demo:", best[0]["title"])
+}
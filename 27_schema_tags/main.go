@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"go-orm-demo/shared"
+
+	"github.com/ESGI-M2/GO/orm/builder"
+)
+
+// Author shows single-column indexes, sizes and defaults
+type Author struct {
+	ID        int       `table:"authors" orm:"pk,auto"`
+	Email     string    `orm:"column:email,size:191,unique"`
+	Name      string    `orm:"column:name,size:100,index"`
+	Active    bool      `orm:"column:active,default:true"`
+	CreatedAt time.Time `orm:"column:created_at,default:CURRENT_TIMESTAMP"`
+}
+
+// Article shows a foreign key, a composite unique and a composite index
+type Article struct {
+	ID          int        `table:"articles" orm:"pk,auto"`
+	AuthorID    int        `orm:"column:author_id,fk:authors.id,on_delete:cascade,unique:uq_articles_author_slug"`
+	Slug        string     `orm:"column:slug,size:120,unique:uq_articles_author_slug"`
+	Title       string     `orm:"column:title,size:200,not null"`
+	Body        string     `orm:"column:body,type:TEXT"`
+	Status      string     `orm:"column:status,size:20,default:draft,index:idx_articles_status_published"`
+	PublishedAt *time.Time `orm:"column:published_at,nullable,index:idx_articles_status_published"`
+}
+
+func main() {
+	cfg := builder.NewConfigBuilder().
+		WithDialect(shared.DialectFromEnv()).
+		WithEnvFile("../shared/env.sample").
+		FromEnv()
+
+	// The models are not registered here: ORM.Migrate would emit the library DDL,
+	// whose inline foreign key is invalid on MySQL
+	simple := shared.NewSimpleORM().WithConfigBuilder(cfg)
+	if err := simple.Connect(); err != nil {
+		log.Fatalf("connect: %v", err)
+	}
+	defer simple.Close()
+	orm := simple.GetORM()
+
+	// Start from scratch so that schema changes in this file are picked up
+	_ = orm.GetDialect().DropTable("articles")
+	_ = orm.GetDialect().DropTable("authors")
+
+	stmts, err := shared.SchemaSQL(orm, &Author{}, &Article{})
+	if err != nil {
+		log.Fatalf("schema sql: %v", err)
+	}
+	for _, stmt := range stmts {
+		fmt.Println(stmt + ";")
+	}
+	if err := shared.Migrate(orm, &Author{}, &Article{}); err != nil {
+		log.Fatalf("migrate: %v", err)
+	}
+
+	authors := orm.Repository(&Author{})
+	articles := orm.Repository(&Article{})
+
+	author := &Author{Email: "ada@example.com", Name: "Ada", Active: true, CreatedAt: time.Now()}
+	if err := authors.Save(author); err != nil {
+		log.Fatalf("save author: %v", err)
+	}
+	first := &Article{AuthorID: author.ID, Slug: "hello", Title: "Hello", Body: "First article", Status: "draft"}
+	if err := articles.Save(first); err != nil {
+		log.Fatalf("save article: %v", err)
+	}
+
+	// Same author and slug: rejected by the composite unique index
+	err = articles.Save(&Article{AuthorID: author.ID, Slug: "hello", Title: "Hello again", Status: "draft"})
+	fmt.Println("duplicate author/slug rejected:", err != nil)
+
+	// Unknown author: rejected by the foreign key
+	err = articles.Save(&Article{AuthorID: author.ID + 1000, Slug: "orphan", Title: "Orphan", Status: "draft"})
+	fmt.Println("unknown author rejected:", err != nil)
+
+	// Deleting the author cascades to the articles
+	if err := authors.Delete(author); err != nil {
+		log.Fatalf("delete author: %v", err)
+	}
+	left, _ := orm.Query(&Article{}).Where("author_id", "=", author.ID).Count()
+	fmt.Printf("articles left after deleting the author: %d (expect 0)\n", left)
+}
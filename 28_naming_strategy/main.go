@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"go-orm-demo/shared"
+
+	"github.com/ESGI-M2/GO/orm/builder"
+)
+
+// BlogPost declares no table, so the strategy derives one
+type BlogPost struct {
+	ID        int       `orm:"pk,auto"`
+	AuthorID  int       `orm:"column:author_id,index"`
+	Title     string    `orm:"column:title"`
+	CreatedAt time.Time `orm:"column:created_at"`
+}
+
+// Category picks its own table through a TableName method
+type Category struct {
+	ID   int    `orm:"pk,auto"`
+	Name string `orm:"column:name,unique"`
+}
+
+func (Category) TableName() string { return "post_categories" }
+
+func main() {
+	cfg := builder.NewConfigBuilder().
+		WithDialect(shared.DialectFromEnv()).
+		WithEnvFile("../shared/env.sample").
+		FromEnv()
+
+	// Models are named before any table is created, so they are not registered here
+	simple := shared.NewSimpleORM().WithConfigBuilder(cfg)
+	if err := simple.Connect(); err != nil {
+		log.Fatalf("connect: %v", err)
+	}
+	defer simple.Close()
+	orm := simple.GetORM()
+
+	// Every table of this tenant gets the "acme_" prefix
+	naming := shared.SnakeCaseNaming{TablePrefix: "acme_"}
+	models := []interface{}{&shared.User{}, &BlogPost{}, &Category{}}
+	if err := shared.ApplyNaming(orm, naming, models...); err != nil {
+		log.Fatalf("naming: %v", err)
+	}
+	for _, model := range models {
+		meta, _ := orm.GetMetadata(model)
+		fmt.Printf("%-18T -> %s\n", model, meta.TableName)
+	}
+	if err := shared.Migrate(orm, models...); err != nil {
+		log.Fatalf("migrate: %v", err)
+	}
+
+	// Repositories and the query builder use the resolved names
+	u := &shared.User{Name: "Tenant", Email: fmt.Sprintf("tenant_%d@example.com", time.Now().UnixNano()), Age: 40, CreatedAt: time.Now()}
+	if err := orm.Repository(&shared.User{}).Save(u); err != nil {
+		log.Fatalf("save user: %v", err)
+	}
+	if err := orm.Repository(&BlogPost{}).Save(&BlogPost{AuthorID: u.ID, Title: "Hello tenant", CreatedAt: time.Now()}); err != nil {
+		log.Fatalf("save post: %v", err)
+	}
+
+	// Raw SQL refers to models instead of hard-coded table names
+	rows, err := shared.Raw(orm, `SELECT u.name, COUNT(p.id) AS posts
+		FROM {{table User}} u LEFT JOIN {{table BlogPost}} p ON p.author_id = u.id
+		GROUP BY u.id, u.name`).Find()
+	if err != nil {
+		log.Fatalf("raw query: %v", err)
+	}
+	shared.Pretty("posts per user", rows)
+
+	// A field whose column the strategy would rename must say so explicitly
+	type Untagged struct {
+		ID        int       `orm:"pk,auto"`
+		UpdatedAt time.Time `orm:"index"`
+	}
+	fmt.Println("untagged:", shared.ApplyNaming(orm, naming, &Untagged{}))
+}
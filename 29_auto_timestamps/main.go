@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"go-orm-demo/shared"
+
+	"github.com/ESGI-M2/GO/orm/builder"
+)
+
+// Note gets its timestamps from the repository
+type Note struct {
+	ID        int       `table:"notes" orm:"pk,auto"`
+	Body      string    `orm:"column:body"`
+	CreatedAt time.Time `orm:"column:created_at,autoCreateTime"`
+	UpdatedAt time.Time `orm:"column:updated_at,autoUpdateTime"`
+}
+
+func main() {
+	cfg := builder.NewConfigBuilder().
+		WithDialect(shared.DialectFromEnv()).
+		WithEnvFile("../shared/env.sample").
+		FromEnv()
+
+	orm := shared.NewSimpleORM().
+		WithConfigBuilder(cfg).
+		RegisterModels(&Note{})
+	if err := orm.Connect(); err != nil {
+		log.Fatalf("connect: %v", err)
+	}
+	defer orm.Close()
+
+	// A test clock that moves one hour forward on every call
+	clock := time.Date(2024, 1, 1, 9, 0, 0, 0, time.FixedZone("CET", 3600))
+	tick := func() time.Time {
+		clock = clock.Add(time.Hour)
+		return clock
+	}
+	repo := shared.NewRepository(orm.GetORM(), &Note{}, shared.WithClock(tick))
+
+	note := &Note{Body: "draft"}
+	if err := repo.Save(note); err != nil {
+		log.Fatalf("save: %v", err)
+	}
+	fmt.Printf("created: created_at=%s updated_at=%s\n", note.CreatedAt, note.UpdatedAt)
+
+	note.Body = "final"
+	if err := repo.Update(note); err != nil {
+		log.Fatalf("update: %v", err)
+	}
+	fmt.Printf("updated: created_at=%s updated_at=%s\n", note.CreatedAt, note.UpdatedAt)
+
+	// An explicit creation time is kept
+	imported := &Note{Body: "imported", CreatedAt: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)}
+	batch := []interface{}{&Note{Body: "one"}, imported}
+	if err := repo.BatchCreate(batch); err != nil {
+		log.Fatalf("batch create: %v", err)
+	}
+	for _, n := range batch {
+		n := n.(*Note)
+		fmt.Printf("batch %-8s created_at=%s updated_at=%s\n", n.Body, n.CreatedAt, n.UpdatedAt)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"go-orm-demo/shared"
+
+	"github.com/ESGI-M2/GO/orm/builder"
+)
+
+// Document is edited concurrently; Version detects lost updates
+type Document struct {
+	ID      int    `table:"documents" orm:"pk,auto"`
+	Title   string `orm:"column:title"`
+	Body    string `orm:"column:body"`
+	Version int    `orm:"column:version,version"`
+}
+
+func main() {
+	cfg := builder.NewConfigBuilder().
+		WithDialect(shared.DialectFromEnv()).
+		WithEnvFile("../shared/env.sample").
+		FromEnv()
+
+	orm := shared.NewSimpleORM().
+		WithConfigBuilder(cfg).
+		RegisterModels(&Document{})
+	if err := orm.Connect(); err != nil {
+		log.Fatalf("connect: %v", err)
+	}
+	defer orm.Close()
+
+	repo := shared.NewRepository(orm.GetORM(), &Document{})
+	doc := &Document{Title: "Spec", Body: "v1"}
+	if err := repo.Save(doc); err != nil {
+		log.Fatalf("save: %v", err)
+	}
+	fmt.Printf("saved id=%d version=%d\n", doc.ID, doc.Version)
+
+	// Two API replicas load the same row
+	load := func() *Document {
+		row, err := repo.Find(doc.ID)
+		if err != nil {
+			log.Fatalf("find: %v", err)
+		}
+		var d Document
+		if err := shared.Decode(row.(map[string]interface{}), &d); err != nil {
+			log.Fatalf("decode: %v", err)
+		}
+		return &d
+	}
+	replicaA, replicaB := load(), load()
+
+	replicaA.Body = "edited by A"
+	if err := repo.Update(replicaA); err != nil {
+		log.Fatalf("update A: %v", err)
+	}
+	fmt.Printf("replica A saved, version=%d\n", replicaA.Version)
+
+	// B still holds version 1: its write is rejected instead of overwriting A
+	replicaB.Title = "Spec (B)"
+	err := repo.Update(replicaB)
+	fmt.Printf("replica B: %v (stale: %t)\n", err, errors.Is(err, shared.ErrStaleObject))
+
+	// B reloads, reapplies its change and succeeds
+	replicaB = load()
+	replicaB.Title = "Spec (B)"
+	if err := repo.Update(replicaB); err != nil {
+		log.Fatalf("retry B: %v", err)
+	}
+	shared.Pretty("final document", load())
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"go-orm-demo/shared"
+
+	"github.com/ESGI-M2/GO/orm/builder"
+)
+
+func main() {
+	cfg := builder.NewConfigBuilder().
+		WithDialect(shared.DialectFromEnv()).
+		WithEnvFile("../shared/env.sample").
+		FromEnv()
+
+	orm := shared.NewSimpleORM().
+		WithConfigBuilder(cfg).
+		RegisterModels(&shared.User{})
+	if err := orm.Connect(); err != nil {
+		log.Fatalf("connect: %v", err)
+	}
+	defer orm.Close()
+
+	// WithTracking snapshots what FindInto loads, for as long as the repository lives
+	repo := shared.NewRepository(orm.GetORM(), &shared.User{}, shared.WithTracking())
+
+	// Omit works on inserts too, here leaving deleted_at to its default
+	user := &shared.User{Name: "Alice", Email: fmt.Sprintf("alice_%d@example.com", time.Now().UnixNano()), Age: 30}
+	if err := repo.Omit("deleted_at").Save(user); err != nil {
+		log.Fatalf("save: %v", err)
+	}
+
+	// Two writers load the same user; FindInto tracks what was loaded
+	var a, b shared.User
+	if err := repo.FindInto(user.ID, &a); err != nil {
+		log.Fatalf("load a: %v", err)
+	}
+	if err := repo.FindInto(user.ID, &b); err != nil {
+		log.Fatalf("load b: %v", err)
+	}
+
+	// Writer B only touches the age
+	b.Age = 31
+	if err := repo.UpdateFields(&b, "age"); err != nil {
+		log.Fatalf("update b: %v", err)
+	}
+
+	// Writer A renamed the user; only the name is written, so B's age survives
+	a.Name = "Alice Cooper"
+	fmt.Println("dirty columns of A:", repo.Dirty(&a))
+	if err := repo.Update(&a); err != nil {
+		log.Fatalf("update a: %v", err)
+	}
+	fmt.Println("dirty columns of A after update:", repo.Dirty(&a))
+
+	// Select restricts an update to the listed columns, whatever else changed
+	a.Email = "alice.cooper@example.com"
+	a.Age = 99
+	if err := repo.Select("email").Update(&a); err != nil {
+		log.Fatalf("select update: %v", err)
+	}
+
+	fmt.Println("dirty columns of A after the selective update:", repo.Dirty(&a))
+
+	var reloaded shared.User
+	if err := repo.FindInto(user.ID, &reloaded); err != nil {
+		log.Fatalf("reload: %v", err)
+	}
+	fmt.Printf("reloaded: name=%q email=%q age=%d (expect 31)\n", reloaded.Name, reloaded.Email, reloaded.Age)
+
+	_ = repo.Delete(user)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go-orm-demo/shared"
+
+	ormcore "github.com/ESGI-M2/GO/orm"
+	"github.com/ESGI-M2/GO/orm/builder"
+)
+
+type actorKey struct{}
+
+// Order implements hook methods; shared.Repository finds and calls them
+type Order struct {
+	ID        int        `table:"orders" orm:"pk,auto"`
+	Customer  string     `orm:"column:customer"`
+	Total     float64    `orm:"column:total"`
+	Status    string     `orm:"column:status"`
+	DeletedAt *time.Time `orm:"column:deleted_at,soft"`
+
+	// Label is computed by AfterFind, it is not stored
+	Label string
+}
+
+// OrderEvent is the audit trail written by the hooks, in the same transaction
+type OrderEvent struct {
+	ID      int    `table:"order_events" orm:"pk,auto"`
+	OrderID int    `orm:"column:order_id"`
+	Action  string `orm:"column:action"`
+	Actor   string `orm:"column:actor"`
+}
+
+func (o *Order) BeforeSave(ctx context.Context, tx ormcore.ORM) error {
+	if o.Total < 0 {
+		return fmt.Errorf("total must not be negative, got %.2f", o.Total)
+	}
+	if o.Status == "" {
+		o.Status = "pending"
+	}
+	return nil
+}
+
+func (o *Order) AfterCreate(ctx context.Context, tx ormcore.ORM) error {
+	return audit(ctx, tx, o, "created")
+}
+
+func (o *Order) AfterUpdate(ctx context.Context, tx ormcore.ORM) error {
+	if err := audit(ctx, tx, o, "status "+o.Status); err != nil {
+		return err
+	}
+	// Rolls back the update and the audit row written just above
+	if o.Status == "lost" {
+		return errors.New("lost orders need a manual review")
+	}
+	return nil
+}
+
+func (o *Order) BeforeDelete(ctx context.Context, tx ormcore.ORM) error {
+	if o.Status == "shipped" {
+		return errors.New("shipped orders cannot be deleted")
+	}
+	return nil
+}
+
+func (o *Order) BeforeSoftDelete(ctx context.Context, tx ormcore.ORM) error {
+	return audit(ctx, tx, o, "cancelled")
+}
+
+func (o *Order) AfterRestore(ctx context.Context, tx ormcore.ORM) error {
+	return audit(ctx, tx, o, "reopened")
+}
+
+func (o *Order) AfterFind(ctx context.Context, tx ormcore.ORM) error {
+	o.Label = fmt.Sprintf("#%d %s (%.2f, %s)", o.ID, o.Customer, o.Total, o.Status)
+	return nil
+}
+
+// audit records an event through the transaction handed to the hook
+func audit(ctx context.Context, tx ormcore.ORM, o *Order, action string) error {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return tx.Repository(&OrderEvent{}).Save(&OrderEvent{OrderID: o.ID, Action: action, Actor: actor})
+}
+
+func main() {
+	cfg := builder.NewConfigBuilder().
+		WithDialect(shared.DialectFromEnv()).
+		WithEnvFile("../shared/env.sample").
+		FromEnv()
+
+	orm := shared.NewSimpleORM().
+		WithConfigBuilder(cfg).
+		RegisterModels(&Order{}, &OrderEvent{})
+	if err := orm.Connect(); err != nil {
+		log.Fatalf("connect: %v", err)
+	}
+	defer orm.Close()
+
+	ctx := context.WithValue(context.Background(), actorKey{}, "alice")
+	repo := shared.NewRepository(orm.GetORM(), &Order{}).WithContext(ctx)
+	events := func(o *Order) int64 {
+		n, _ := orm.GetORM().Query(&OrderEvent{}).Where("order_id", "=", o.ID).Count()
+		return n
+	}
+
+	order := &Order{Customer: "ACME", Total: 120}
+	if err := repo.Save(order); err != nil {
+		log.Fatalf("save: %v", err)
+	}
+	fmt.Printf("created order %d, status=%s, audit rows=%d\n", order.ID, order.Status, events(order))
+
+	// BeforeSave rejects the write before anything reaches the database
+	order.Total = -5
+	fmt.Println("negative total:", repo.Update(order))
+	order.Total = 120
+
+	order.Status = "paid"
+	if err := repo.Update(order); err != nil {
+		log.Fatalf("update: %v", err)
+	}
+	fmt.Printf("paid, audit rows=%d\n", events(order))
+
+	// AfterUpdate fails: the status change and its audit row are rolled back together
+	order.Status = "lost"
+	fmt.Println("lost:", repo.Update(order))
+	fmt.Printf("audit rows after rollback=%d\n", events(order))
+
+	var reloaded Order
+	if err := repo.FindInto(order.ID, &reloaded); err != nil {
+		log.Fatalf("find: %v", err)
+	}
+	fmt.Println("reloaded:", reloaded.Label)
+
+	if err := repo.SoftDelete(&reloaded); err != nil {
+		log.Fatalf("soft delete: %v", err)
+	}
+	if err := repo.Restore(&reloaded); err != nil {
+		log.Fatalf("restore: %v", err)
+	}
+
+	reloaded.Status = "shipped"
+	if err := repo.Update(&reloaded); err != nil {
+		log.Fatalf("ship: %v", err)
+	}
+	fmt.Println("delete shipped:", repo.Delete(&reloaded))
+
+	var trail []OrderEvent
+	rows, err := orm.GetORM().Query(&OrderEvent{}).Where("order_id", "=", order.ID).OrderBy("id", "ASC").Find()
+	if err != nil {
+		log.Fatalf("audit trail: %v", err)
+	}
+	if err := shared.DecodeAll(rows, &trail); err != nil {
+		log.Fatalf("decode: %v", err)
+	}
+	shared.Pretty("audit trail", trail)
+}
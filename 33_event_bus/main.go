@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"go-orm-demo/shared"
+
+	ormcore "github.com/ESGI-M2/GO/orm"
+	"github.com/ESGI-M2/GO/orm/builder"
+)
+
+func main() {
+	cfg := builder.NewConfigBuilder().
+		WithDialect(shared.DialectFromEnv()).
+		WithEnvFile("../shared/env.sample").
+		FromEnv()
+
+	orm := shared.NewSimpleORM().
+		WithConfigBuilder(cfg).
+		RegisterModels(&shared.User{})
+	if err := orm.Connect(); err != nil {
+		log.Fatalf("connect: %v", err)
+	}
+	defer orm.Close()
+	db := orm.GetORM()
+	_ = db.DropTable(&shared.User{})
+	_ = db.CreateTable(&shared.User{})
+
+	bus := shared.Events(db)
+
+	// Audit trail: synchronous, so it is written before the repository call returns
+	bus.Subscribe(shared.EventCreated, &shared.User{}, func(e shared.Event) {
+		fmt.Printf("audit: %s %v created\n", e.Table, e.Key)
+	})
+	bus.Subscribe(shared.EventUpdated, &shared.User{}, func(e shared.Event) {
+		for _, col := range e.Changed() {
+			fmt.Printf("audit: %s %v %s %v -> %v\n", e.Table, e.Key, col, e.Before[col], e.After[col])
+		}
+	})
+	bus.Subscribe(shared.EventDeleted, nil, func(e shared.Event) {
+		fmt.Printf("audit: %s %v deleted (soft=%t)\n", e.Table, e.Key, e.Soft)
+	})
+	bus.Subscribe(shared.EventRestored, nil, func(e shared.Event) {
+		fmt.Printf("audit: %s %v restored\n", e.Table, e.Key)
+	})
+
+	// Cache invalidation: asynchronous, once per committed transaction and model
+	var mu sync.Mutex
+	var invalidations []string
+	bus.Subscribe(shared.EventCommitted, nil, func(e shared.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		invalidations = append(invalidations, fmt.Sprintf("%s (%d changes)", e.Table, len(e.Changes)))
+	}, shared.Async())
+
+	repo := shared.NewRepository(db, &shared.User{})
+	u := &shared.User{Name: "Alice", Email: "alice@example.com", Age: 30}
+	if err := repo.Save(u); err != nil {
+		log.Fatalf("save: %v", err)
+	}
+	u.Age = 31
+	u.Email = "alice@example.org"
+	if err := repo.Update(u); err != nil {
+		log.Fatalf("update: %v", err)
+	}
+	if err := repo.SoftDelete(u); err != nil {
+		log.Fatalf("soft delete: %v", err)
+	}
+	if err := repo.Restore(u); err != nil {
+		log.Fatalf("restore: %v", err)
+	}
+
+	// A rolled back transaction publishes nothing
+	ctx := context.Background()
+	err := shared.Transaction(ctx, db, func(tx ormcore.ORM) error {
+		if err := repo.WithTx(tx).Save(&shared.User{Name: "Ghost", Email: "ghost@example.com"}); err != nil {
+			return err
+		}
+		return errors.New("import aborted")
+	})
+	fmt.Println("rolled back:", err)
+
+	// A committed one publishes its changes, then a single committed event
+	err = shared.Transaction(ctx, db, func(tx ormcore.ORM) error {
+		users := repo.WithTx(tx)
+		for _, name := range []string{"Bob", "Carol"} {
+			if err := users.Save(&shared.User{Name: name, Email: name + "@example.com"}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("import: %v", err)
+	}
+
+	// A plain orm.Transaction hides its outcome from the bus: the write goes through
+	// but publishes nothing
+	err = db.Transaction(func(tx ormcore.ORM) error {
+		return repo.WithTx(tx).Save(&shared.User{Name: "Dan", Email: "dan@example.com"})
+	})
+	if err != nil {
+		log.Fatalf("plain transaction: %v", err)
+	}
+	fmt.Println("plain transaction: committed, no events")
+
+	bus.Wait()
+	sort.Strings(invalidations)
+	shared.Pretty("cache invalidations", invalidations)
+}
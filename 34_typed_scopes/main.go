@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go-orm-demo/shared"
+
+	"github.com/ESGI-M2/GO/orm/builder"
+	"github.com/ESGI-M2/GO/orm/core/interfaces"
+)
+
+// Invoice belongs to a tenant; a global scope keeps tenants apart
+type Invoice struct {
+	ID        int        `table:"invoices" orm:"pk,auto"`
+	TenantID  int        `orm:"column:tenant_id"`
+	Customer  string     `orm:"column:customer"`
+	Amount    float64    `orm:"column:amount"`
+	DeletedAt *time.Time `orm:"column:deleted_at,soft"`
+}
+
+type tenantKey struct{}
+
+// OlderThan is a parameterized local scope
+func OlderThan(age int) shared.Expr {
+	return func(q interfaces.QueryBuilder) interfaces.QueryBuilder {
+		return q.Where("age", ">", age)
+	}
+}
+
+// NameLike is another one; scopes compose in the order given
+func NameLike(pattern string) shared.Expr {
+	return func(q interfaces.QueryBuilder) interfaces.QueryBuilder {
+		return q.WhereLike("name", pattern)
+	}
+}
+
+// MinAmount keeps invoices of at least the given amount
+func MinAmount(amount float64) shared.Expr {
+	return func(q interfaces.QueryBuilder) interfaces.QueryBuilder {
+		return q.Where("amount", ">=", amount)
+	}
+}
+
+func main() {
+	cfg := builder.NewConfigBuilder().
+		WithDialect(shared.DialectFromEnv()).
+		WithEnvFile("../shared/env.sample").
+		FromEnv()
+
+	orm := shared.NewSimpleORM().
+		WithConfigBuilder(cfg).
+		RegisterModels(&shared.User{}, &Invoice{})
+	if err := orm.Connect(); err != nil {
+		log.Fatalf("connect: %v", err)
+	}
+	defer orm.Close()
+	db := orm.GetORM()
+	_ = db.DropTable(&shared.User{})
+	_ = db.CreateTable(&shared.User{})
+
+	// --- local scopes with parameters ---
+	users := shared.NewRepository(db, &shared.User{})
+	shared.SeedAdvancedUsers(users)
+
+	names, err := users.WithScopes(OlderThan(25)).Pluck("name")
+	if err != nil {
+		log.Fatalf("older than 25: %v", err)
+	}
+	fmt.Println("older than 25:", names)
+	names, _ = users.WithScopes(OlderThan(20), NameLike("%a%")).Pluck("name")
+	fmt.Println("older than 20 with an a:", names)
+
+	// --- global scopes: tenant filter and soft deletes ---
+	shared.AddGlobalScope(db, &Invoice{}, "tenant", func(ctx context.Context) shared.Expr {
+		tenant, ok := ctx.Value(tenantKey{}).(int)
+		return func(q interfaces.QueryBuilder) interfaces.QueryBuilder {
+			if !ok {
+				return q.WhereRaw("1 = 0") // no tenant, no rows
+			}
+			return q.Where("tenant_id", "=", tenant)
+		}
+	})
+
+	invoices := shared.NewRepository(db, &Invoice{})
+	seed := []*Invoice{
+		{TenantID: 1, Customer: "ACME", Amount: 120},
+		{TenantID: 1, Customer: "Globex", Amount: 40},
+		{TenantID: 1, Customer: "Initech", Amount: 300},
+		{TenantID: 2, Customer: "Umbrella", Amount: 999},
+	}
+	for _, inv := range seed {
+		if err := invoices.Save(inv); err != nil {
+			log.Fatalf("save invoice: %v", err)
+		}
+	}
+
+	tenant1 := invoices.WithContext(context.WithValue(context.Background(), tenantKey{}, 1))
+	customers, _ := tenant1.Pluck("customer")
+	fmt.Println("tenant 1 customers:", customers)
+	customers, _ = tenant1.WithScopes(MinAmount(100)).Pluck("customer")
+	fmt.Println("tenant 1 invoices >= 100:", customers)
+	n, _ := invoices.Count()
+	fmt.Println("without a tenant:", n)
+
+	// Soft deletes are a global scope too
+	if err := tenant1.SoftDelete(seed[1]); err != nil {
+		log.Fatalf("soft delete: %v", err)
+	}
+	n, _ = tenant1.Count()
+	withTrashed, _ := tenant1.WithoutGlobalScope(shared.SoftDeleteScope).Count()
+	fmt.Printf("tenant 1: %d live, %d with trashed\n", n, withTrashed)
+
+	// Opting out, e.g. for an admin report across tenants
+	n, _ = invoices.WithoutGlobalScope("tenant").Count()
+	fmt.Println("all tenants:", n)
+
+	// A misspelt scope name is reported instead of silently ignored
+	_, err = invoices.WithoutGlobalScope("tennant").Count()
+	fmt.Println("typo:", err)
+}
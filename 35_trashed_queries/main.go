@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"go-orm-demo/shared"
+
+	"github.com/ESGI-M2/GO/orm/builder"
+)
+
+func main() {
+	cfg := builder.NewConfigBuilder().
+		WithDialect(shared.DialectFromEnv()).
+		WithEnvFile("../shared/env.sample").
+		FromEnv()
+
+	orm := shared.NewSimpleORM().
+		WithConfigBuilder(cfg).
+		RegisterModels(&shared.User{})
+	if err := orm.Connect(); err != nil {
+		log.Fatalf("connect: %v", err)
+	}
+	defer orm.Close()
+	db := orm.GetORM()
+
+	// Recreate tables for a clean run
+	_ = db.DropTable(&shared.Post{})
+	_ = db.DropTable(&shared.User{})
+	if err := shared.Migrate(db, &shared.User{}, &shared.Post{}); err != nil {
+		log.Fatalf("migrate: %v", err)
+	}
+
+	users := shared.NewRepository(db, &shared.User{})
+	posts := shared.NewRepository(db, &shared.Post{})
+	shared.SeedAdvancedUsers(users) // Anna 22, Brian 30, Clara 27, Derek 19, Eve 25
+
+	find := func(name string) *shared.User {
+		var found []shared.User
+		if err := users.Query().WithTrashed().Where("name", "=", name).Into(&found); err != nil || len(found) == 0 {
+			log.Fatalf("find %s: %v", name, err)
+		}
+		return &found[0]
+	}
+	names := func(q *shared.ModelQuery) []string {
+		var list []shared.User
+		if err := q.OrderBy("name", "ASC").Into(&list); err != nil {
+			log.Fatalf("query: %v", err)
+		}
+		out := []string{}
+		for _, u := range list {
+			out = append(out, u.Name)
+		}
+		return out
+	}
+
+	anna, brian := find("Anna"), find("Brian")
+	for _, p := range []*shared.Post{
+		{Title: "Anna's first", UserID: anna.ID},
+		{Title: "Anna's draft", UserID: anna.ID},
+		{Title: "Brian's only", UserID: brian.ID},
+	} {
+		if err := posts.Save(p); err != nil {
+			log.Fatalf("save post: %v", err)
+		}
+	}
+	var drafts []shared.Post
+	_ = posts.Query().Where("title", "=", "Anna's draft").Into(&drafts)
+	_ = posts.SoftDelete(&drafts[0])
+
+	_ = users.SoftDelete(brian)
+	_ = users.SoftDelete(find("Derek"))
+
+	// Trash modes compose with the rest of the query
+	fmt.Println("live:", names(users.Query()))
+	fmt.Println("with trashed, 25+:", names(users.Query().WithTrashed().Where("age", ">=", 25)))
+	fmt.Println("only trashed:", names(users.Query().OnlyTrashed()))
+	page, err := users.Query().WithTrashed().OrderBy("name", "ASC").Paginate(2, 2)
+	if err != nil {
+		log.Fatalf("paginate: %v", err)
+	}
+	fmt.Printf("page %d/%d of %d users with trashed: %d rows\n", page.CurrentPage, page.LastPage, page.Total, len(page.Data))
+
+	// Eager-loaded relations follow the same mode
+	countPosts := func(q *shared.ModelQuery) map[string]int {
+		rows, err := q.With("Posts").Find()
+		if err != nil {
+			log.Fatalf("with posts: %v", err)
+		}
+		out := map[string]int{}
+		for _, row := range rows {
+			out[fmt.Sprint(row["name"])] = len(row["Posts"].([]map[string]interface{}))
+		}
+		return out
+	}
+	fmt.Println("posts of live Anna:", countPosts(users.Query().Where("name", "=", "Anna")))
+	fmt.Println("posts of Anna with trashed:", countPosts(users.Query().WithTrashed().Where("name", "=", "Anna")))
+	fmt.Println("posts of trashed users:", countPosts(users.Query().OnlyTrashed()))
+
+	// Bulk restore and force delete, with hooks, in one transaction each
+	n, err := users.Query().Where("age", "<", 25).Restore()
+	if err != nil {
+		log.Fatalf("restore: %v", err)
+	}
+	fmt.Printf("restored %d young user(s): %v\n", n, names(users.Query()))
+
+	// posts.user_id references users, so the trashed users' posts go first
+	if _, err := posts.Query().WithTrashed().Where("user_id", "=", brian.ID).ForceDelete(); err != nil {
+		log.Fatalf("force delete posts: %v", err)
+	}
+	n, err = users.Query().OnlyTrashed().ForceDelete()
+	if err != nil {
+		log.Fatalf("force delete: %v", err)
+	}
+	fmt.Printf("force deleted %d user(s), with trashed: %v\n", n, names(users.Query().WithTrashed()))
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"go-orm-demo/shared"
+
+	"github.com/ESGI-M2/GO/orm/builder"
+)
+
+// Author soft deletes and restores its posts with it
+type Author struct {
+	ID        int        `table:"authors" orm:"pk,auto"`
+	Name      string     `orm:"column:name"`
+	DeletedAt *time.Time `orm:"column:deleted_at,soft"`
+	Posts     []Article  `orm:"relation:one_to_many,fk:author_id,cascade:soft"`
+}
+
+type Article struct {
+	ID        int        `table:"articles" orm:"pk,auto"`
+	Title     string     `orm:"column:title"`
+	AuthorID  int        `orm:"column:author_id"`
+	DeletedAt *time.Time `orm:"column:deleted_at,soft"`
+}
+
+func main() {
+	cfg := builder.NewConfigBuilder().
+		WithDialect(shared.DialectFromEnv()).
+		WithEnvFile("../shared/env.sample").
+		FromEnv()
+
+	orm := shared.NewSimpleORM().
+		WithConfigBuilder(cfg).
+		RegisterModels(&Author{}, &Article{})
+	if err := orm.Connect(); err != nil {
+		log.Fatalf("connect: %v", err)
+	}
+	defer orm.Close()
+	db := orm.GetORM()
+
+	// Recreate tables for a clean run
+	_ = db.DropTable(&Article{})
+	_ = db.DropTable(&Author{})
+	_ = db.CreateTable(&Author{})
+	_ = db.CreateTable(&Article{})
+
+	authors := shared.NewRepository(db, &Author{})
+	posts := shared.NewRepository(db, &Article{})
+
+	anna := &Author{Name: "Anna"}
+	if err := authors.Save(anna); err != nil {
+		log.Fatalf("save author: %v", err)
+	}
+	var old *Article
+	for _, title := range []string{"Hello", "Second thoughts", "Old news"} {
+		p := &Article{Title: title, AuthorID: anna.ID}
+		if err := posts.Save(p); err != nil {
+			log.Fatalf("save post: %v", err)
+		}
+		old = p
+	}
+
+	// A post deleted on its own before the author stays deleted on restore
+	if err := posts.SoftDelete(old); err != nil {
+		log.Fatalf("soft delete post: %v", err)
+	}
+
+	titles := func(q *shared.ModelQuery) []string {
+		var list []Article
+		if err := q.Where("author_id", "=", anna.ID).OrderBy("id", "ASC").Into(&list); err != nil {
+			log.Fatalf("posts: %v", err)
+		}
+		out := []string{}
+		for _, p := range list {
+			out = append(out, p.Title)
+		}
+		return out
+	}
+	fmt.Println("live posts:", titles(posts.Query()))
+
+	// Soft deleting the author marks its live posts deleted in the same transaction
+	if err := authors.SoftDelete(anna); err != nil {
+		log.Fatalf("soft delete author: %v", err)
+	}
+	fmt.Println("after deleting Anna, live posts:", titles(posts.Query()))
+	fmt.Println("trashed posts:", titles(posts.Query().OnlyTrashed()))
+
+	// Restoring the author brings back the posts deleted with it, even from a bare key
+	if err := authors.Restore(&Author{ID: anna.ID}); err != nil {
+		log.Fatalf("restore author: %v", err)
+	}
+	fmt.Println("after restoring Anna, live posts:", titles(posts.Query()))
+	fmt.Println("still trashed:", titles(posts.Query().OnlyTrashed()))
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go-orm-demo/shared"
+
+	ormcore "github.com/ESGI-M2/GO/orm"
+	"github.com/ESGI-M2/GO/orm/builder"
+)
+
+// Account is soft deleted when closed and erased after the retention period
+type Account struct {
+	ID        int        `table:"accounts" orm:"pk,auto"`
+	Email     string     `orm:"column:email,unique"`
+	DeletedAt *time.Time `orm:"column:deleted_at,soft"`
+}
+
+// BeforeDelete runs for every pruned row, e.g. to erase data kept elsewhere
+func (a *Account) BeforeDelete(ctx context.Context, tx ormcore.ORM) error {
+	fmt.Println("  erasing", a.Email)
+	return nil
+}
+
+const retention = 30 * 24 * time.Hour
+
+func main() {
+	cfg := builder.NewConfigBuilder().
+		WithDialect(shared.DialectFromEnv()).
+		WithEnvFile("../shared/env.sample").
+		FromEnv()
+
+	orm := shared.NewSimpleORM().
+		WithConfigBuilder(cfg).
+		RegisterModels(&Account{})
+	if err := orm.Connect(); err != nil {
+		log.Fatalf("connect: %v", err)
+	}
+	defer orm.Close()
+	db := orm.GetORM()
+
+	// Recreate table for a clean run
+	_ = db.DropTable(&Account{})
+	_ = db.CreateTable(&Account{})
+
+	// A movable clock to close accounts in the past
+	now := time.Now()
+	clock := now
+	accounts := shared.NewRepository(db, &Account{}, shared.WithClock(func() time.Time { return clock }))
+
+	seed := []struct {
+		email         string
+		closedDaysAgo int // 0 for an open account
+	}{
+		{"ann@example.com", 90}, {"bob@example.com", 45}, {"cat@example.com", 31},
+		{"dan@example.com", 29}, {"eve@example.com", 1}, {"fay@example.com", 0},
+	}
+	for _, s := range seed {
+		a := &Account{Email: s.email}
+		if err := accounts.Save(a); err != nil {
+			log.Fatalf("save: %v", err)
+		}
+		if s.closedDaysAgo > 0 {
+			clock = now.Add(-time.Duration(s.closedDaysAgo) * 24 * time.Hour)
+			if err := accounts.SoftDelete(a); err != nil {
+				log.Fatalf("soft delete: %v", err)
+			}
+		}
+	}
+	clock = now
+
+	trashed, _ := accounts.Query().OnlyTrashed().Count()
+	fmt.Printf("%d closed accounts, pruning those closed over 30 days ago\n", trashed)
+
+	// Chunks of two rows, one transaction each, oldest first
+	n, err := accounts.PruneTrashed(retention,
+		shared.PruneChunkSize(2),
+		shared.PruneProgress(func(chunk, total int64) {
+			fmt.Printf("  chunk of %d, %d so far\n", chunk, total)
+		}))
+	if err != nil {
+		log.Fatalf("prune: %v", err)
+	}
+	fmt.Println("pruned:", n)
+
+	live, _ := accounts.Count()
+	trashed, _ = accounts.Query().OnlyTrashed().Count()
+	fmt.Printf("left: %d live, %d closed within the retention period\n", live, trashed)
+}
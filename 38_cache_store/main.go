@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"go-orm-demo/shared"
+
+	"github.com/ESGI-M2/GO/orm/builder"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func main() {
+	cfg := builder.NewConfigBuilder().
+		WithDialect(shared.DialectFromEnv()).
+		WithEnvFile("../shared/env.sample").
+		FromEnv()
+
+	orm := shared.NewSimpleORM().
+		WithConfigBuilder(cfg).
+		RegisterModels(&shared.User{})
+	if err := orm.Connect(); err != nil {
+		log.Fatalf("connect: %v", err)
+	}
+	defer orm.Close()
+	db := orm.GetORM()
+
+	// Recreate table for a clean run
+	_ = db.DropTable(&shared.User{})
+	_ = db.CreateTable(&shared.User{})
+
+	users := shared.NewRepository(db, &shared.User{})
+	shared.SeedAdvancedUsers(users) // Anna 22, Brian 30, Clara 27, Derek 19, Eve 25
+
+	// --- in-memory LRU, at most 2 entries and 64 KiB ---
+	lru := shared.NewLRUCache(2, 64<<10)
+	shared.UseCache(db, lru)
+
+	adults := users.Query().Where("age", ">=", 21).Cache(60)
+	for i := 0; i < 3; i++ {
+		if _, err := adults.Find(); err != nil {
+			log.Fatalf("cached find: %v", err)
+		}
+	}
+	s := lru.Stats()
+	fmt.Printf("3 runs of one query: %d miss, %d hits\n", s.Misses, s.Hits)
+
+	_, _ = users.Query().Cache(60).Count()
+	_, _ = users.Query().Where("name", "=", "Eve").Cache(60).FindOne()
+	s = lru.Stats()
+	fmt.Printf("after 2 more queries: %d entries, %d evicted, %d bytes\n", s.Entries, s.Evictions, s.Bytes)
+
+	// --- Redis, shared by the replicas of an app ---
+	// REDIS_ADDR points to a real server; otherwise an in-process stand-in is used
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		mr, err := miniredis.Run()
+		if err != nil {
+			log.Fatalf("miniredis: %v", err)
+		}
+		defer mr.Close()
+		addr = mr.Addr()
+	}
+	replicaA := shared.NewRedisCache(redis.NewClient(&redis.Options{Addr: addr}), "demo:")
+	replicaB := shared.NewRedisCache(redis.NewClient(&redis.Options{Addr: addr}), "demo:")
+	ctx := context.Background()
+	_ = replicaA.DeleteByTag(ctx, "users")
+
+	count := users.Query().Cache(60)
+	shared.UseCache(db, replicaA)
+	n, _ := count.Count()
+	fmt.Println("replica A counts users:", n)
+
+	// Rows removed behind the ORM's back: replica B still gets A's cached count
+	if _, err := db.GetDialect().Exec("DELETE FROM users"); err != nil {
+		log.Fatalf("delete: %v", err)
+	}
+	shared.UseCache(db, replicaB)
+	n, _ = count.Count()
+	fmt.Println("replica B, from the shared cache:", n)
+
+	// Dropping the table's tag makes the next run hit the database
+	if err := replicaB.DeleteByTag(ctx, "users"); err != nil {
+		log.Fatalf("delete by tag: %v", err)
+	}
+	n, _ = count.Count()
+	fmt.Println("after dropping the users tag:", n)
+	n, _ = count.WithoutCache().Count()
+	fmt.Println("without cache:", n)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"go-orm-demo/shared"
+
+	ormcore "github.com/ESGI-M2/GO/orm"
+	"github.com/ESGI-M2/GO/orm/builder"
+)
+
+func main() {
+	cfg := builder.NewConfigBuilder().
+		WithDialect(shared.DialectFromEnv()).
+		WithEnvFile("../shared/env.sample").
+		FromEnv()
+
+	orm := shared.NewSimpleORM().
+		WithConfigBuilder(cfg).
+		RegisterModels(&shared.User{})
+	if err := orm.Connect(); err != nil {
+		log.Fatalf("connect: %v", err)
+	}
+	defer orm.Close()
+	db := orm.GetORM()
+
+	// Recreate table for a clean run
+	_ = db.DropTable(&shared.User{})
+	_ = db.CreateTable(&shared.User{})
+
+	lru := shared.NewLRUCache(100, 0)
+	shared.UseCache(db, lru)
+
+	users := shared.NewRepository(db, &shared.User{})
+	shared.SeedAdvancedUsers(users) // Anna 22, Brian 30, Clara 27, Derek 19, Eve 25
+
+	all := users.Query().Cache(60)
+	over30 := users.Query().Where("age", ">", 30).Cache(60)
+	show := func(label string) {
+		n, _ := all.Count()
+		m, _ := over30.Count()
+		fmt.Printf("%-32s %d users, %d over 30 (cache hits so far: %d)\n", label+":", n, m, lru.Stats().Hits)
+	}
+	show("seeded")
+	show("again, from the cache")
+
+	// Any write through a repository drops the table's cached queries
+	frank := &shared.User{Name: "Frank", Email: "frank@example.com", Age: 41}
+	if err := users.Save(frank); err != nil {
+		log.Fatalf("save: %v", err)
+	}
+	show("after Save")
+	if err := users.Increment("age", 10); err != nil {
+		log.Fatalf("increment: %v", err)
+	}
+	show("after Increment(age, 10)")
+	if err := users.Delete(frank); err != nil {
+		log.Fatalf("delete: %v", err)
+	}
+	show("after Delete")
+
+	// In a transaction, at the write and again once it commits, so nothing read
+	// in between stays cached; a rollback never leaves stale entries
+	ctx := context.Background()
+	err := shared.Transaction(ctx, db, func(tx ormcore.ORM) error {
+		_ = users.WithTx(tx).Save(&shared.User{Name: "Gina", Email: "gina@example.com", Age: 33})
+		return errors.New("changed my mind")
+	})
+	fmt.Println("rolled back:", err)
+	show("after the rollback")
+	err = shared.Transaction(ctx, db, func(tx ormcore.ORM) error {
+		return users.WithTx(tx).Save(&shared.User{Name: "Gina", Email: "gina@example.com", Age: 33})
+	})
+	if err != nil {
+		log.Fatalf("transaction: %v", err)
+	}
+	show("after the commit")
+
+	// Writes the ORM does not see need a manual flush
+	if _, err := db.GetDialect().Exec("DELETE FROM users WHERE age > 30"); err != nil {
+		log.Fatalf("raw delete: %v", err)
+	}
+	show("after a raw DELETE")
+	if err := shared.FlushCache(db, &shared.User{}); err != nil {
+		log.Fatalf("flush: %v", err)
+	}
+	show("after FlushCache")
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"go-orm-demo/shared"
+
+	ormcore "github.com/ESGI-M2/GO/orm"
+	"github.com/ESGI-M2/GO/orm/builder"
+)
+
+func main() {
+	cfg := builder.NewConfigBuilder().
+		WithDialect(shared.DialectFromEnv()).
+		WithEnvFile("../shared/env.sample").
+		FromEnv()
+
+	orm := shared.NewSimpleORM().
+		WithConfigBuilder(cfg).
+		RegisterModels(&shared.User{})
+	if err := orm.Connect(); err != nil {
+		log.Fatalf("connect: %v", err)
+	}
+	defer orm.Close()
+	db := orm.GetORM()
+
+	// Recreate table for a clean run
+	_ = db.DropTable(&shared.User{})
+	_ = db.CreateTable(&shared.User{})
+
+	anna := &shared.User{Name: "Anna", Email: "anna@example.com", Age: 22}
+	if err := shared.NewRepository(db, &shared.User{}).Save(anna); err != nil {
+		log.Fatalf("save: %v", err)
+	}
+
+	// --- one session per request ---
+	sess := shared.NewSession(db)
+	users := sess.Repository(&shared.User{})
+
+	a, err := users.Get(anna.ID)
+	if err != nil {
+		log.Fatalf("get: %v", err)
+	}
+	first := a.(*shared.User)
+
+	// A change the session does not see proves the second Get ran no query
+	d := db.GetDialect()
+	if _, err := d.Exec("UPDATE users SET name = 'Anne' WHERE id = "+d.GetPlaceholder(0), anna.ID); err != nil {
+		log.Fatalf("raw update: %v", err)
+	}
+	b, _ := users.Get(anna.ID)
+	fmt.Printf("same instance: %v, name still %q\n", b == a, b.(*shared.User).Name)
+
+	// Find still queries and returns the row map
+	found, _ := users.Find(anna.ID)
+	fmt.Println("Find returns the stored row:", found.(map[string]interface{})["name"])
+
+	// Writes through the session reach its instance, whichever copy they are made on
+	copied := *first
+	copied.Age = 23
+	if err := users.UpdateFields(&copied, "age"); err != nil {
+		log.Fatalf("update: %v", err)
+	}
+	fmt.Println("age after writing a copy:", first.Age)
+
+	// Rows created in the session join the map
+	brian := &shared.User{Name: "Brian", Email: "brian@example.com", Age: 30}
+	_ = users.Save(brian)
+	c, _ := users.Get(brian.ID)
+	fmt.Println("created instance returned by Get:", c == brian)
+
+	// Soft-deleted rows leave the map and are hidden again
+	_ = users.SoftDelete(brian)
+	_, err = users.Get(brian.ID)
+	fmt.Println("after soft delete:", err)
+	gone, _ := users.Find(brian.ID)
+	fmt.Println("Find after soft delete:", gone == nil)
+
+	// Outside a session, every Get loads a fresh instance
+	plain := shared.NewRepository(db, &shared.User{})
+	x, _ := plain.Get(anna.ID)
+	y, _ := plain.Get(anna.ID)
+	fmt.Printf("without a session: same instance %v, name %q\n", x == y, x.(*shared.User).Name)
+
+	// --- one session per transaction ---
+	err = shared.Transaction(context.Background(), db, func(tx ormcore.ORM) error {
+		users := shared.NewSession(tx).Repository(&shared.User{})
+		u, err := users.Get(anna.ID)
+		if err != nil {
+			return err
+		}
+		u.(*shared.User).Age++
+		if err := users.Update(u); err != nil {
+			return err
+		}
+		again, _ := users.Get(anna.ID)
+		fmt.Println("in the transaction, age seen by a later Get:", again.(*shared.User).Age)
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("transaction: %v", err)
+	}
+}
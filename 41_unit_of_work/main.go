@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"go-orm-demo/shared"
+
+	"github.com/ESGI-M2/GO/orm/builder"
+)
+
+// Customer -> Order -> OrderLine, with foreign key constraints between the tables
+type Customer struct {
+	ID     int     `table:"customers" orm:"pk,auto"`
+	Email  string  `orm:"column:email,size:191,unique"`
+	Orders []Order `orm:"relation:one_to_many,fk:customer_id"`
+}
+
+type Order struct {
+	ID         int         `table:"orders" orm:"pk,auto"`
+	CustomerID int         `orm:"column:customer_id,fk:customers.id"`
+	Reference  string      `orm:"column:reference,size:40"`
+	Lines      []OrderLine `orm:"relation:one_to_many,fk:order_id"`
+}
+
+type OrderLine struct {
+	ID       int    `table:"order_lines" orm:"pk,auto"`
+	OrderID  int    `orm:"column:order_id,fk:orders.id"`
+	Product  string `orm:"column:product,size:80"`
+	Quantity int    `orm:"column:quantity"`
+}
+
+func main() {
+	cfg := builder.NewConfigBuilder().
+		WithDialect(shared.DialectFromEnv()).
+		WithEnvFile("../shared/env.sample").
+		FromEnv()
+
+	// The models are not registered here: ORM.Migrate would emit the library DDL,
+	// whose inline foreign key is invalid on MySQL
+	orm := shared.NewSimpleORM().WithConfigBuilder(cfg)
+	if err := orm.Connect(); err != nil {
+		log.Fatalf("connect: %v", err)
+	}
+	defer orm.Close()
+	db := orm.GetORM()
+
+	// Recreate tables for a clean run, children first
+	_ = db.DropTable(&OrderLine{})
+	_ = db.DropTable(&Order{})
+	_ = db.DropTable(&Customer{})
+	if err := shared.Migrate(db, &Customer{}, &Order{}, &OrderLine{}); err != nil {
+		log.Fatalf("migrate: %v", err)
+	}
+
+	// Print the writes of each flush in the order they ran
+	var writes []string
+	record := func(e shared.Event) {
+		writes = append(writes, fmt.Sprintf("%s %s #%v", e.Type, e.Table, e.Key))
+	}
+	bus := shared.Events(db)
+	bus.Subscribe(shared.EventCreated, nil, record)
+	bus.Subscribe(shared.EventUpdated, nil, record)
+	bus.Subscribe(shared.EventDeleted, nil, record)
+	flush := func(label string, sess *shared.Session) error {
+		writes = nil
+		err := sess.Flush()
+		if len(writes) == 0 {
+			writes = []string{"nothing committed"}
+		}
+		fmt.Printf("%s:\n  %s\n", label, strings.Join(writes, "\n  "))
+		return err
+	}
+
+	// --- an import job builds the graph, then writes it in one transaction ---
+	sess := shared.NewSession(db)
+	ann := &Customer{Email: "ann@example.com", Orders: []Order{
+		{Reference: "A-1", Lines: []OrderLine{{Product: "pen", Quantity: 3}, {Product: "ink", Quantity: 1}}},
+	}}
+	bob := &Customer{Email: "bob@example.com", Orders: []Order{
+		{Reference: "B-1", Lines: []OrderLine{{Product: "paper", Quantity: 500}}},
+	}}
+	// A line added before the customers is still written after its order
+	bob.Orders[0].Lines = append(bob.Orders[0].Lines, OrderLine{Product: "stapler", Quantity: 1})
+	sess.Add(&bob.Orders[0].Lines[1], ann, bob)
+	if err := flush("import", sess); err != nil {
+		log.Fatalf("flush: %v", err)
+	}
+	fmt.Printf("bob's order %d belongs to customer %d\n", bob.Orders[0].ID, bob.Orders[0].CustomerID)
+
+	// --- changes to loaded entities and removals ---
+	sess = shared.NewSession(db)
+	customers := sess.Repository(&Customer{})
+	c, err := customers.Get(ann.ID)
+	if err != nil {
+		log.Fatalf("get: %v", err)
+	}
+	c.(*Customer).Email = "ann@example.org" // tracked, written by Flush
+	order := ann.Orders[0]
+	// Removing the order before its lines still deletes the lines first
+	sess.Remove(&order, &order.Lines[0], &order.Lines[1])
+	if err := flush("update and remove", sess); err != nil {
+		log.Fatalf("flush: %v", err)
+	}
+
+	// --- a failing flush rolls everything back ---
+	sess = shared.NewSession(db)
+	eve := &Customer{Email: "eve@example.com", Orders: []Order{{Reference: "E-1"}}}
+	dup := &Customer{Email: "bob@example.com"}
+	sess.Add(eve, dup)
+	err = flush("duplicate email", sess)
+	fmt.Println("  error:", err != nil)
+	fmt.Printf("  eve and her order have their zero ids back: %d, %d\n", eve.ID, eve.Orders[0].ID)
+	n, _ := shared.NewRepository(db, &Customer{}).Count()
+	fmt.Println("  customers in the table:", n)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"go-orm-demo/shared"
+
+	ormcore "github.com/ESGI-M2/GO/orm"
+	"github.com/ESGI-M2/GO/orm/builder"
+)
+
+// Playlist -> Track; tracks are hard deleted when they leave the slice
+type Playlist struct {
+	ID     int     `table:"playlists" orm:"pk,auto"`
+	Name   string  `orm:"column:name,size:80"`
+	Tracks []Track `orm:"relation:one_to_many,fk:playlist_id"`
+}
+
+type Track struct {
+	ID         int    `table:"tracks" orm:"pk,auto"`
+	PlaylistID int    `orm:"column:playlist_id,fk:playlists.id"`
+	Title      string `orm:"column:title,size:120"`
+}
+
+// BeforeSave rejects untitled tracks, to show a rollback
+func (t *Track) BeforeSave(ctx context.Context, tx ormcore.ORM) error {
+	if strings.TrimSpace(t.Title) == "" {
+		return errors.New("a track needs a title")
+	}
+	return nil
+}
+
+func main() {
+	cfg := builder.NewConfigBuilder().
+		WithDialect(shared.DialectFromEnv()).
+		WithEnvFile("../shared/env.sample").
+		FromEnv()
+
+	// The models are not registered here: ORM.Migrate would emit the library DDL,
+	// whose inline foreign key is invalid on MySQL
+	orm := shared.NewSimpleORM().WithConfigBuilder(cfg)
+	if err := orm.Connect(); err != nil {
+		log.Fatalf("connect: %v", err)
+	}
+	defer orm.Close()
+	db := orm.GetORM()
+
+	// Recreate tables for a clean run, children first
+	_ = db.DropTable(&Track{})
+	_ = db.DropTable(&Playlist{})
+	if err := shared.Migrate(db, &Playlist{}, &Track{}); err != nil {
+		log.Fatalf("migrate: %v", err)
+	}
+
+	playlists := shared.NewRepository(db, &Playlist{}).WithAssociations()
+	tracks := shared.NewRepository(db, &Track{})
+	show := func(label string, id int) {
+		rows, _ := tracks.Query().Where("playlist_id", "=", id).OrderBy("id", "ASC").Find()
+		titles := make([]string, 0, len(rows))
+		for _, row := range rows {
+			titles = append(titles, fmt.Sprintf("#%v %v", row["id"], row["title"]))
+		}
+		fmt.Printf("%s: [%s]\n", label, strings.Join(titles, ", "))
+	}
+
+	// --- insert: the playlist's new id goes into every track ---
+	p := &Playlist{Name: "Road trip", Tracks: []Track{{Title: "Intro"}, {Title: "Highway"}, {Title: "Sunset"}}}
+	if err := playlists.Save(p); err != nil {
+		log.Fatalf("save: %v", err)
+	}
+	fmt.Printf("playlist %d, track playlist_id %d\n", p.ID, p.Tracks[0].PlaylistID)
+	show("created", p.ID)
+
+	// --- sync: rename one, drop one, add one ---
+	p.Tracks[0].Title = "Intro (live)"
+	p.Tracks = append([]Track{p.Tracks[0], p.Tracks[2]}, Track{Title: "Encore"})
+	if err := playlists.Save(p); err != nil {
+		log.Fatalf("save: %v", err)
+	}
+	show("synced", p.ID)
+
+	// --- a nil slice leaves the tracks alone, an empty one removes them ---
+	renamed := &Playlist{ID: p.ID, Name: "Road trip 2"}
+	_ = playlists.Save(renamed)
+	show("nil slice", p.ID)
+
+	// --- a failing child rolls the whole graph back ---
+	bad := &Playlist{Name: "Broken", Tracks: []Track{{Title: "Fine"}, {Title: " "}}}
+	err := playlists.Save(bad)
+	fmt.Println("invalid track:", err)
+	n, _ := shared.NewRepository(db, &Playlist{}).Count()
+	fmt.Printf("  playlists in the table: %d, ids back to zero: %d, %d\n", n, bad.ID, bad.Tracks[0].ID)
+
+	renamed.Tracks = []Track{}
+	_ = playlists.Save(renamed)
+	show("empty slice", p.ID)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go-orm-demo/shared"
+
+	"github.com/ESGI-M2/GO/orm/builder"
+)
+
+// Team has many projects and shares members with other teams through team_members,
+// which also holds each member's role
+type Team struct {
+	ID       int       `table:"teams" orm:"pk,auto"`
+	Name     string    `orm:"column:name,size:80"`
+	Projects []Project `orm:"relation:one_to_many,fk:team_id"`
+	Members  []Member  `orm:"relation:many_to_many,fk:team_id" join_table:"team_members" referenced_key:"member_id"`
+}
+
+type Project struct {
+	ID        int        `table:"projects" orm:"pk,auto"`
+	TeamID    int        `orm:"column:team_id"`
+	Name      string     `orm:"column:name,size:80"`
+	DeletedAt *time.Time `orm:"column:deleted_at,soft"`
+}
+
+type Member struct {
+	ID   int    `table:"members" orm:"pk,auto"`
+	Name string `orm:"column:name,size:80"`
+}
+
+func main() {
+	cfg := builder.NewConfigBuilder().
+		WithDialect(shared.DialectFromEnv()).
+		WithEnvFile("../shared/env.sample").
+		FromEnv()
+
+	orm := shared.NewSimpleORM().
+		WithConfigBuilder(cfg).
+		RegisterModels(&Team{}, &Project{}, &Member{})
+	if err := orm.Connect(); err != nil {
+		log.Fatalf("connect: %v", err)
+	}
+	defer orm.Close()
+	db := orm.GetORM()
+
+	// Recreate tables for a clean run; the join table has no model
+	d := db.GetDialect()
+	_, _ = d.Exec("DROP TABLE IF EXISTS team_members")
+	for _, m := range []interface{}{&Project{}, &Member{}, &Team{}} {
+		_ = db.DropTable(m)
+		_ = db.CreateTable(m)
+	}
+	if _, err := d.Exec(`CREATE TABLE team_members (
+		team_id INT NOT NULL,
+		member_id INT NOT NULL,
+		role VARCHAR(20),
+		PRIMARY KEY (team_id, member_id))`); err != nil {
+		log.Fatalf("create team_members: %v", err)
+	}
+
+	team := &Team{Name: "Platform"}
+	if err := shared.NewRepository(db, &Team{}).Save(team); err != nil {
+		log.Fatalf("save team: %v", err)
+	}
+	projects := shared.Association(db, team, "Projects")
+	members := shared.Association(db, team, "Members")
+
+	showProjects := func(label string) {
+		var list []Project
+		if err := projects.Query().OrderBy("id", "ASC").Into(&list); err != nil {
+			log.Fatalf("projects: %v", err)
+		}
+		names := []string{}
+		for _, p := range list {
+			names = append(names, p.Name)
+		}
+		n, _ := projects.Count()
+		fmt.Printf("%s: %d [%s]\n", label, n, strings.Join(names, ", "))
+	}
+	showMembers := func(label string) {
+		pivots, err := members.Pivots()
+		if err != nil {
+			log.Fatalf("pivots: %v", err)
+		}
+		rows := []string{}
+		for _, p := range pivots {
+			rows = append(rows, fmt.Sprintf("member %v as %v", p["member_id"], p["role"]))
+		}
+		fmt.Printf("%s: [%s]\n", label, strings.Join(rows, ", "))
+	}
+
+	// --- one-to-many: the team's key goes into each project ---
+	site, api := &Project{Name: "Site"}, &Project{Name: "API"}
+	if err := projects.Append(site, api); err != nil {
+		log.Fatalf("append: %v", err)
+	}
+	fmt.Println("api belongs to team", api.TeamID)
+	showProjects("appended")
+
+	// Projects have a soft column, so removed projects are soft deleted
+	_ = projects.Delete(api)
+	showProjects("deleted API")
+	_ = projects.Replace(site, &Project{Name: "App"})
+	showProjects("replaced")
+	_ = projects.Clear()
+	showProjects("cleared")
+
+	// --- many-to-many: rows of the join table, with their extra columns ---
+	ann, bob, cy := &Member{Name: "Ann"}, &Member{Name: "Bob"}, &Member{Name: "Cy"}
+	memberRepo := shared.NewRepository(db, &Member{})
+	for _, m := range []*Member{ann, bob, cy} {
+		_ = memberRepo.Save(m)
+	}
+	if err := members.AttachWith(shared.Pivot{"role": "owner"}, ann); err != nil {
+		log.Fatalf("attach: %v", err)
+	}
+	_ = members.Attach(bob.ID, ann.ID) // ann is already attached and keeps her role
+	showMembers("attached")
+
+	// Sync detaches Ann, updates Bob's role and attaches Cy
+	err := members.SyncWith(map[interface{}]shared.Pivot{
+		bob.ID: {"role": "editor"},
+		cy.ID:  {"role": "viewer"},
+	})
+	if err != nil {
+		log.Fatalf("sync: %v", err)
+	}
+	showMembers("synced")
+
+	// Append saves new members before attaching them
+	dee := &Member{Name: "Dee"}
+	_ = members.Append(dee)
+	_ = members.Detach(cy)
+	var list []Member
+	_ = members.Query().OrderBy("id", "ASC").Into(&list)
+	names := []string{}
+	for _, m := range list {
+		names = append(names, m.Name)
+	}
+	fmt.Printf("members after appending Dee and detaching Cy: [%s]\n", strings.Join(names, ", "))
+	n, _ := memberRepo.Count()
+	fmt.Println("members kept in their table:", n)
+
+	// --- misuse is reported, not ignored ---
+	fmt.Println("attach on a one-to-many:", projects.Attach(1))
+	fmt.Println("unsaved team:", shared.Association(db, &Team{}, "Members").Attach(ann))
+	fmt.Println("unknown relation:", shared.Association(db, team, "Owners").Clear())
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go-orm-demo/shared"
+
+	"github.com/ESGI-M2/GO/orm/builder"
+	"github.com/ESGI-M2/GO/orm/core/interfaces"
+)
+
+type Author struct {
+	ID    int    `table:"authors" orm:"pk,auto"`
+	Name  string `orm:"column:name,size:80"`
+	Books []Book `orm:"relation:one_to_many,fk:author_id"`
+}
+
+type Book struct {
+	ID        int        `table:"books" orm:"pk,auto"`
+	AuthorID  int        `orm:"column:author_id"`
+	Title     string     `orm:"column:title,size:120"`
+	Pages     int        `orm:"column:pages"`
+	DeletedAt *time.Time `orm:"column:deleted_at,soft"`
+	Genres    []Genre    `orm:"relation:many_to_many,fk:book_id" join_table:"book_genres" referenced_key:"genre_id"`
+}
+
+type Genre struct {
+	ID   int    `table:"genres" orm:"pk,auto"`
+	Name string `orm:"column:name,size:80"`
+}
+
+func main() {
+	cfg := builder.NewConfigBuilder().
+		WithDialect(shared.DialectFromEnv()).
+		WithEnvFile("../shared/env.sample").
+		FromEnv()
+
+	orm := shared.NewSimpleORM().
+		WithConfigBuilder(cfg).
+		RegisterModels(&Author{}, &Book{}, &Genre{})
+	if err := orm.Connect(); err != nil {
+		log.Fatalf("connect: %v", err)
+	}
+	defer orm.Close()
+	db := orm.GetORM()
+
+	// Recreate tables for a clean run; the join table has no model
+	d := db.GetDialect()
+	_, _ = d.Exec("DROP TABLE IF EXISTS book_genres")
+	for _, m := range []interface{}{&Book{}, &Genre{}, &Author{}} {
+		_ = db.DropTable(m)
+		_ = db.CreateTable(m)
+	}
+	if _, err := d.Exec(`CREATE TABLE book_genres (
+		book_id INT NOT NULL,
+		genre_id INT NOT NULL,
+		PRIMARY KEY (book_id, genre_id))`); err != nil {
+		log.Fatalf("create book_genres: %v", err)
+	}
+
+	// Ann writes about Go, Bob about databases, Cy's only book is in the trash, Dee
+	// has written nothing yet
+	genres := map[string]*Genre{"Programming": {Name: "Programming"}, "Databases": {Name: "Databases"}}
+	for _, g := range genres {
+		_ = shared.NewRepository(db, &Genre{}).Save(g)
+	}
+	seed := []struct {
+		author string
+		books  []Book
+		genres []string
+	}{
+		{"Ann", []Book{{Title: "Learning Go", Pages: 320}, {Title: "Go in Practice", Pages: 280}}, []string{"Programming"}},
+		{"Bob", []Book{{Title: "SQL Basics", Pages: 150}, {Title: "Indexing", Pages: 90}}, []string{"Programming", "Databases"}},
+		{"Cy", []Book{{Title: "Old Go Notes", Pages: 40}}, nil},
+		{"Dee", nil, nil},
+	}
+	authors := shared.NewRepository(db, &Author{})
+	books := shared.NewRepository(db, &Book{})
+	for _, s := range seed {
+		a := &Author{Name: s.author, Books: s.books}
+		if err := authors.WithAssociations().Save(a); err != nil {
+			log.Fatalf("seed: %v", err)
+		}
+		for i := range a.Books {
+			for _, name := range s.genres {
+				_ = shared.Association(db, &a.Books[i], "Genres").Attach(genres[name])
+			}
+		}
+		if s.author == "Cy" {
+			_ = books.SoftDelete(&a.Books[0])
+		}
+	}
+
+	names := func(q *shared.ModelQuery, column string) string {
+		rows, err := q.OrderBy("id", "ASC").Find()
+		if err != nil {
+			log.Fatalf("query: %v", err)
+		}
+		out := []string{}
+		for _, row := range rows {
+			out = append(out, fmt.Sprint(row[column]))
+		}
+		return strings.Join(out, ", ")
+	}
+	titleLike := func(pattern string) shared.Expr {
+		return func(b interfaces.QueryBuilder) interfaces.QueryBuilder {
+			return b.Where("title", "LIKE", pattern)
+		}
+	}
+
+	// --- filtering by related rows, compiled to EXISTS subqueries ---
+	aboutGo := authors.Query().WhereHas("Books", titleLike("%Go%"))
+	fmt.Println("SQL:", aboutGo.Builder().GetSQL())
+	fmt.Println("authors of a Go book:", names(aboutGo, "name"))
+	fmt.Println("authors without books:", names(authors.Query().WhereDoesntHave("Books"), "name"))
+	fmt.Println("  (Cy's only book is soft deleted, so Cy has none)")
+
+	// Conditions before and after the subquery keep their arguments in order
+	q := authors.Query().Where("name", "<>", "Ann").WhereHas("Books", titleLike("%Index%"))
+	fmt.Println("other authors of an indexing book:", names(q, "name"))
+
+	// Many-to-many relations go through their join table
+	dbBooks := books.Query().WhereHas("Genres", func(b interfaces.QueryBuilder) interfaces.QueryBuilder {
+		return b.Where("name", "=", "Databases")
+	})
+	fmt.Println("books about databases:", names(dbBooks, "title"))
+
+	// --- aggregates of the related rows ---
+	rows, err := authors.Query().
+		WithCount("Books").
+		WithSum("Books", "pages").
+		WithMax("Books", "pages").
+		WithCount("Books as go_books", titleLike("%Go%")).
+		OrderBy("id", "ASC").
+		Find()
+	if err != nil {
+		log.Fatalf("aggregates: %v", err)
+	}
+	fmt.Println("author  books  about Go  pages  longest")
+	for _, row := range rows {
+		fmt.Printf("%-6v  %5v  %8v  %5v  %7v\n", row["name"], row["books_count"], row["go_books_count"], row["books_sum_pages"], row["books_max_pages"])
+	}
+	row, _ := books.Query().WithCount("Genres").Where("title", "=", "SQL Basics").FindOne()
+	fmt.Printf("%q has %v genres\n", row["title"], row["genres_count"])
+
+	// --- an unknown relation fails the query ---
+	_, err = authors.Query().WhereHas("Reviews").Find()
+	fmt.Println("unknown relation:", err)
+}
@@ -0,0 +1,597 @@
+package shared
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	ormcore "github.com/ESGI-M2/GO/orm"
+	"github.com/ESGI-M2/GO/orm/core/interfaces"
+)
+
+// Pivot holds the extra columns of a join table row, e.g. Pivot{"role": "editor"}
+type Pivot map[string]interface{}
+
+// Associations manages the rows related to one saved entity through one of its
+// relations, declared as for FindWithRelations:
+//
+//	Posts []Post `orm:"relation:one_to_many,fk:user_id"`
+//	Tags  []Tag  `orm:"relation:many_to_many,fk:post_id" join_table:"post_tags" referenced_key:"tag_id"`
+//
+// For a many-to-many relation, fk is the join table column holding the entity's key
+// and referenced_key the one holding the related model's key. Each call runs in one
+// transaction, or in the repository's own. The entity's relation field is left as it
+// is; reload it with FindWithRelations or read the rows through Query.
+type Associations struct {
+	owner  *Repository
+	entity interface{}
+	name   string
+	rel    *interfaces.Relation
+	target *Repository
+	err    error
+}
+
+// Association returns the Associations of an entity's relation
+//
+//	err := shared.Association(db, user, "Posts").Append(&Post{Title: "Hello"})
+//	err = shared.Association(db, post, "Tags").Sync(1, 2, 3)
+func Association(orm ormcore.ORM, entity interface{}, relation string) *Associations {
+	return NewRepository(orm, entity).Association(entity, relation)
+}
+
+// Association is the package function for an entity of the repository's model, through
+// the repository's transaction, context and session
+func (r *Repository) Association(entity interface{}, relation string) *Associations {
+	a := &Associations{owner: r, entity: entity, name: relation, err: r.err}
+	if a.err != nil {
+		return a
+	}
+	rel, ok := r.meta.Relations[relation]
+	if !ok {
+		a.err = fmt.Errorf("%s has no relation %s", r.meta.TableName, relation)
+		return a
+	}
+	meta, model, ok := r.relationTarget(relation)
+	if !ok {
+		a.err = fmt.Errorf("relation %s points to an unknown model", relation)
+		return a
+	}
+	switch {
+	case rel.Type == interfaces.ManyToMany:
+		if a.err = checkJoinTable(relation, rel); a.err != nil {
+			return a
+		}
+	case !childRelation(r.meta, meta, rel):
+		a.err = fmt.Errorf("relation %s holds no children: %s is not a column of %s", relation, rel.ForeignKey, meta.TableName)
+		return a
+	}
+	a.rel = rel
+	a.target = NewRepository(r.orm, model, WithClock(r.now)).WithContext(r.ctx)
+	a.target.session = r.session
+	return a
+}
+
+func (a *Associations) manyToMany() bool {
+	return a.rel.Type == interfaces.ManyToMany
+}
+
+// key returns the entity's primary key, which must be set
+func (a *Associations) key() (interface{}, error) {
+	pk, err := a.owner.primaryKey(a.entity)
+	if err != nil {
+		return nil, err
+	}
+	if pk.IsZero() {
+		return nil, fmt.Errorf("%T must be saved before its %s", a.entity, a.name)
+	}
+	return pk.Interface(), nil
+}
+
+// Query returns a query on the related rows, with the related model's scopes
+//
+//	var posts []Post
+//	err := shared.Association(db, user, "Posts").Query().OrderBy("id", "ASC").Into(&posts)
+func (a *Associations) Query() *ModelQuery {
+	err := a.err
+	var key interface{}
+	if err == nil {
+		key, err = a.key()
+	}
+	if err != nil {
+		failed := *a.owner
+		failed.err = err
+		return failed.Query()
+	}
+	if a.manyToMany() {
+		return a.target.Query().WhereRaw(fmt.Sprintf("%s IN (SELECT %s FROM %s WHERE %s = ?)",
+			a.target.meta.PrimaryKey, a.rel.ReferencedKey, a.rel.JoinTable, a.rel.ForeignKey), key)
+	}
+	return a.target.Query().Where(a.rel.ForeignKey, "=", key)
+}
+
+// Count counts the related rows
+func (a *Associations) Count() (int64, error) {
+	return a.Query().Count()
+}
+
+// Append saves the given entities as related rows: children get the entity's key in
+// their foreign key, and many-to-many entities are attached once saved
+func (a *Associations) Append(entities ...interface{}) error {
+	return a.run(func(tx *Associations) error {
+		return tx.append(entities)
+	})
+}
+
+// Replace makes the given entities the only related rows: they are appended, other
+// children are deleted, or soft deleted, as with WithAssociations, and other
+// many-to-many rows are detached
+func (a *Associations) Replace(entities ...interface{}) error {
+	return a.run(func(tx *Associations) error {
+		if err := tx.append(entities); err != nil {
+			return err
+		}
+		keys, err := tx.keys(entities)
+		if err != nil {
+			return err
+		}
+		return tx.keepOnly(keys)
+	})
+}
+
+// Delete removes the given entities from the relation: children are deleted, or soft
+// deleted, and many-to-many rows are detached but kept
+func (a *Associations) Delete(entities ...interface{}) error {
+	return a.run(func(tx *Associations) error {
+		if a.manyToMany() {
+			keys, err := tx.keys(entities)
+			if err != nil {
+				return err
+			}
+			return tx.detach(keys)
+		}
+		key, err := tx.key()
+		if err != nil {
+			return err
+		}
+		for _, e := range entities {
+			if err := tx.checkType(e); err != nil {
+				return err
+			}
+			v, _ := structValue(e)
+			fk, ok := fieldByColumn(v, a.rel.ForeignKey)
+			if !ok || fmt.Sprint(fk.Interface()) != fmt.Sprint(key) {
+				return fmt.Errorf("%T is not one of the %s of %s %v", e, a.name, a.owner.meta.TableName, key)
+			}
+			if err := tx.target.remove(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Clear removes every related row, as Delete does
+func (a *Associations) Clear() error {
+	return a.run(func(tx *Associations) error {
+		return tx.keepOnly(nil)
+	})
+}
+
+// Attach adds join table rows for the given keys or saved entities of a many-to-many
+// relation. Rows already attached are left as they are.
+func (a *Associations) Attach(ids ...interface{}) error {
+	return a.AttachWith(nil, ids...)
+}
+
+// AttachWith is Attach with values for the join table's extra columns
+//
+//	err := shared.Association(db, project, "Members").AttachWith(shared.Pivot{"role": "owner"}, ann)
+func (a *Associations) AttachWith(pivot Pivot, ids ...interface{}) error {
+	pivots := make(map[string]Pivot, len(ids))
+	var keys []string
+	values := map[string]interface{}{}
+	for _, id := range ids {
+		key, err := a.keyOf(id)
+		if err != nil {
+			return err
+		}
+		k := fmt.Sprint(key)
+		if _, ok := values[k]; !ok {
+			keys = append(keys, k)
+		}
+		values[k], pivots[k] = key, pivot
+	}
+	return a.pivotRun(func(tx *Associations) error {
+		attached, err := tx.attached()
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if _, ok := attached[k]; !ok {
+				if err := tx.insertPivot(values[k], pivots[k]); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Detach removes the join table rows of the given keys or entities of a many-to-many
+// relation, or all of them when none are given. The related rows are kept.
+func (a *Associations) Detach(ids ...interface{}) error {
+	var keys []interface{}
+	for _, id := range ids {
+		key, err := a.keyOf(id)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+	if len(ids) == 0 {
+		return a.pivotRun(func(tx *Associations) error {
+			return tx.keepOnly(nil)
+		})
+	}
+	return a.pivotRun(func(tx *Associations) error {
+		return tx.detach(keys)
+	})
+}
+
+// Sync makes the given keys or entities the only ones attached in a many-to-many
+// relation, attaching the missing ones and detaching the others
+func (a *Associations) Sync(ids ...interface{}) error {
+	pivots := make(map[interface{}]Pivot, len(ids))
+	for _, id := range ids {
+		pivots[id] = nil
+	}
+	return a.SyncWith(pivots)
+}
+
+// SyncWith is Sync with the extra columns of each join table row. They are written to
+// the rows it attaches and to the rows already attached; a nil Pivot leaves an
+// attached row as it is.
+//
+//	err := shared.Association(db, project, "Members").SyncWith(map[interface{}]shared.Pivot{
+//		ann.ID: {"role": "owner"},
+//		bob.ID: {"role": "viewer"},
+//	})
+func (a *Associations) SyncWith(pivots map[interface{}]Pivot) error {
+	keys := make([]string, 0, len(pivots))
+	values := map[string]interface{}{}
+	byKey := map[string]Pivot{}
+	for id, pivot := range pivots {
+		key, err := a.keyOf(id)
+		if err != nil {
+			return err
+		}
+		k := fmt.Sprint(key)
+		keys = append(keys, k)
+		values[k], byKey[k] = key, pivot
+	}
+	sort.Strings(keys)
+
+	return a.pivotRun(func(tx *Associations) error {
+		attached, err := tx.attached()
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			_, ok := attached[k]
+			switch {
+			case !ok:
+				err = tx.insertPivot(values[k], byKey[k])
+			case len(byKey[k]) > 0:
+				err = tx.updatePivot(values[k], byKey[k])
+			}
+			if err != nil {
+				return err
+			}
+		}
+		keep := make([]interface{}, 0, len(keys))
+		for _, k := range keys {
+			keep = append(keep, values[k])
+		}
+		return tx.keepOnly(keep)
+	})
+}
+
+// Pivots returns the join table rows of a many-to-many relation, with their extra
+// columns, in the order of the related keys
+func (a *Associations) Pivots() ([]Pivot, error) {
+	if err := a.needPivot(); err != nil {
+		return nil, err
+	}
+	key, err := a.key()
+	if err != nil {
+		return nil, err
+	}
+	d := a.owner.orm.GetDialect()
+	rows, err := d.Query(fmt.Sprintf("SELECT * FROM %s WHERE %s = %s ORDER BY %s",
+		a.rel.JoinTable, a.rel.ForeignKey, d.GetPlaceholder(0), a.rel.ReferencedKey), key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", a.rel.JoinTable, err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var pivots []Pivot
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", a.rel.JoinTable, err)
+		}
+		pivot := Pivot{}
+		for i, col := range columns {
+			if b, ok := values[i].([]byte); ok {
+				values[i] = string(b)
+			}
+			pivot[col] = values[i]
+		}
+		pivots = append(pivots, pivot)
+	}
+	return pivots, rows.Err()
+}
+
+// run runs fn in a transaction on copies of the associations bound to it. The
+// entities inserted by a failed call get their zero key back as the transaction rolls
+// back.
+func (a *Associations) run(fn func(tx *Associations) error) error {
+	if a.err != nil {
+		return a.err
+	}
+	return a.owner.atomically(true, func(r *Repository) error {
+		tx := *a
+		tx.owner = r
+		tx.target = a.target.WithTx(r.orm)
+		return fn(&tx)
+	})
+}
+
+// pivotRun is run for the calls only many-to-many relations have
+func (a *Associations) pivotRun(fn func(tx *Associations) error) error {
+	if err := a.needPivot(); err != nil {
+		return err
+	}
+	return a.run(fn)
+}
+
+func (a *Associations) needPivot() error {
+	if a.err != nil {
+		return a.err
+	}
+	if !a.manyToMany() {
+		return fmt.Errorf("relation %s has no join table", a.name)
+	}
+	return nil
+}
+
+// append saves the entities as related rows
+func (a *Associations) append(entities []interface{}) error {
+	key, err := a.key()
+	if err != nil {
+		return err
+	}
+	for _, e := range entities {
+		if err := a.checkType(e); err != nil {
+			return err
+		}
+		if !a.manyToMany() {
+			pk, _ := a.owner.primaryKey(a.entity)
+			if err := setForeignKey(e, a.rel.ForeignKey, pk); err != nil {
+				return err
+			}
+		}
+		if err := a.target.Save(e); err != nil {
+			return fmt.Errorf("failed to save %s of %s %v: %w", a.name, a.owner.meta.TableName, key, err)
+		}
+	}
+	if !a.manyToMany() {
+		return nil
+	}
+	attached, err := a.attached()
+	if err != nil {
+		return err
+	}
+	for _, e := range entities {
+		pk, _ := a.target.primaryKey(e)
+		k := fmt.Sprint(pk.Interface())
+		if _, ok := attached[k]; !ok {
+			if err := a.insertPivot(pk.Interface(), nil); err != nil {
+				return err
+			}
+			attached[k] = pk.Interface()
+		}
+	}
+	return nil
+}
+
+// keepOnly removes the related rows whose key is not in keys
+func (a *Associations) keepOnly(keys []interface{}) error {
+	key, err := a.key()
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		keep[fmt.Sprint(k)] = true
+	}
+	if !a.manyToMany() {
+		return a.target.deleteOthers(a.rel.ForeignKey, key, keep)
+	}
+	attached, err := a.attached()
+	if err != nil {
+		return err
+	}
+	var stale []interface{}
+	for k, value := range attached {
+		if !keep[k] {
+			stale = append(stale, value)
+		}
+	}
+	return a.detach(stale)
+}
+
+// attached returns the keys of the rows attached in the join table, as text, mapped
+// to the stored values
+func (a *Associations) attached() (map[string]interface{}, error) {
+	key, err := a.key()
+	if err != nil {
+		return nil, err
+	}
+	d := a.owner.orm.GetDialect()
+	rows, err := d.Query(fmt.Sprintf("SELECT %s FROM %s WHERE %s = %s",
+		a.rel.ReferencedKey, a.rel.JoinTable, a.rel.ForeignKey, d.GetPlaceholder(0)), key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", a.rel.JoinTable, err)
+	}
+	defer rows.Close()
+	attached := map[string]interface{}{}
+	for rows.Next() {
+		var value interface{}
+		if err := rows.Scan(&value); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", a.rel.JoinTable, err)
+		}
+		if b, ok := value.([]byte); ok {
+			value = string(b)
+		}
+		attached[fmt.Sprint(value)] = value
+	}
+	return attached, rows.Err()
+}
+
+func (a *Associations) insertPivot(id interface{}, pivot Pivot) error {
+	key, err := a.key()
+	if err != nil {
+		return err
+	}
+	d := a.owner.orm.GetDialect()
+	names := []string{a.rel.ForeignKey, a.rel.ReferencedKey}
+	args := []interface{}{key, id}
+	for _, col := range pivotColumns(pivot) {
+		names = append(names, col)
+		args = append(args, pivot[col])
+	}
+	marks := make([]string, len(args))
+	for i := range marks {
+		marks[i] = d.GetPlaceholder(i)
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", a.rel.JoinTable, strings.Join(names, ", "), strings.Join(marks, ", "))
+	if _, err := d.Exec(query, args...); err != nil {
+		return fmt.Errorf("failed to attach %s %v: %w", a.name, id, err)
+	}
+	a.invalidate()
+	return nil
+}
+
+func (a *Associations) updatePivot(id interface{}, pivot Pivot) error {
+	key, err := a.key()
+	if err != nil {
+		return err
+	}
+	d := a.owner.orm.GetDialect()
+	var sets []string
+	var args []interface{}
+	for _, col := range pivotColumns(pivot) {
+		sets = append(sets, fmt.Sprintf("%s = %s", col, d.GetPlaceholder(len(args))))
+		args = append(args, pivot[col])
+	}
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s = %s AND %s = %s", a.rel.JoinTable, strings.Join(sets, ", "),
+		a.rel.ForeignKey, d.GetPlaceholder(len(args)), a.rel.ReferencedKey, d.GetPlaceholder(len(args)+1))
+	if _, err := d.Exec(query, append(args, key, id)...); err != nil {
+		return fmt.Errorf("failed to update %s %v: %w", a.rel.JoinTable, id, err)
+	}
+	a.invalidate()
+	return nil
+}
+
+func (a *Associations) detach(ids []interface{}) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	key, err := a.key()
+	if err != nil {
+		return err
+	}
+	d := a.owner.orm.GetDialect()
+	args := []interface{}{key}
+	marks := make([]string, len(ids))
+	for i, id := range ids {
+		marks[i] = d.GetPlaceholder(len(args))
+		args = append(args, id)
+	}
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s = %s AND %s IN (%s)", a.rel.JoinTable,
+		a.rel.ForeignKey, d.GetPlaceholder(0), a.rel.ReferencedKey, strings.Join(marks, ", "))
+	if _, err := d.Exec(query, args...); err != nil {
+		return fmt.Errorf("failed to detach %s: %w", a.name, err)
+	}
+	a.invalidate()
+	return nil
+}
+
+// invalidate drops the cached queries that may read the join table
+func (a *Associations) invalidate() {
+	a.owner.invalidateTables(a.rel.JoinTable, a.target.meta.TableName)
+}
+
+// keys returns the primary keys of saved entities of the related model
+func (a *Associations) keys(entities []interface{}) ([]interface{}, error) {
+	keys := make([]interface{}, 0, len(entities))
+	for _, e := range entities {
+		if err := a.checkType(e); err != nil {
+			return nil, err
+		}
+		key, err := a.keyOf(e)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// keyOf returns the primary key of a saved entity of the related model, or the value
+// given when it is not such an entity
+func (a *Associations) keyOf(id interface{}) (interface{}, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+	if id == nil {
+		return nil, fmt.Errorf("nil key for %s", a.name)
+	}
+	if modelType(id) != a.target.meta.Type {
+		return id, nil
+	}
+	pk, err := a.target.primaryKey(id)
+	if err != nil {
+		return nil, err
+	}
+	if pk.IsZero() {
+		return nil, fmt.Errorf("%T must be saved before it is attached", id)
+	}
+	return pk.Interface(), nil
+}
+
+func (a *Associations) checkType(entity interface{}) error {
+	if v := reflect.ValueOf(entity); v.Kind() != reflect.Ptr || v.IsNil() || modelType(entity) != a.target.meta.Type {
+		return fmt.Errorf("%s holds *%s, got %T", a.name, a.target.meta.Type.Name(), entity)
+	}
+	return nil
+}
+
+// pivotColumns returns the columns of a Pivot in a stable order
+func pivotColumns(pivot Pivot) []string {
+	columns := make([]string, 0, len(pivot))
+	for col := range pivot {
+		columns = append(columns, col)
+	}
+	sort.Strings(columns)
+	return columns
+}
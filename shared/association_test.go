@@ -0,0 +1,233 @@
+package shared
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	ormcore "github.com/ESGI-M2/GO/orm"
+)
+
+type assocTeam struct {
+	ID       int            `table:"assoc_teams" orm:"pk,auto"`
+	Name     string         `orm:"column:name"`
+	Projects []assocProject `orm:"relation:one_to_many,fk:team_id"`
+	Members  []assocMember  `orm:"relation:many_to_many,fk:team_id" join_table:"assoc_team_members" referenced_key:"member_id"`
+}
+
+type assocProject struct {
+	ID        int        `table:"assoc_projects" orm:"pk,auto"`
+	TeamID    int        `orm:"column:team_id"`
+	Name      string     `orm:"column:name"`
+	DeletedAt *time.Time `orm:"column:deleted_at,soft"`
+}
+
+type assocMember struct {
+	ID   int    `table:"assoc_members" orm:"pk,auto"`
+	Name string `orm:"column:name"`
+}
+
+// assocORM opens a database with a saved team and its join table
+func assocORM(t *testing.T) (ormcore.ORM, *assocTeam) {
+	t.Helper()
+	db, err := NewSQLiteORM(":memory:", &assocTeam{}, &assocProject{}, &assocMember{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { Close(db) })
+	if _, err := db.GetDialect().Exec(`CREATE TABLE assoc_team_members (
+		team_id INT NOT NULL,
+		member_id INT NOT NULL,
+		role VARCHAR(20),
+		PRIMARY KEY (team_id, member_id))`); err != nil {
+		t.Fatal(err)
+	}
+	team := &assocTeam{Name: "Platform"}
+	if err := NewRepository(db, &assocTeam{}).Save(team); err != nil {
+		t.Fatal(err)
+	}
+	return db, team
+}
+
+func TestAssociationsOneToMany(t *testing.T) {
+	tests := []struct {
+		name    string
+		act     func(projects *Associations, site, api *assocProject) error
+		live    []string
+		trashed int64
+	}{
+		{
+			name: "Append sets the foreign key",
+			act:  func(*Associations, *assocProject, *assocProject) error { return nil },
+			live: []string{"Site", "API"},
+		},
+		{
+			name:    "Delete soft deletes a child",
+			act:     func(p *Associations, _, api *assocProject) error { return p.Delete(api) },
+			live:    []string{"Site"},
+			trashed: 1,
+		},
+		{
+			name:    "Replace keeps only the given children",
+			act:     func(p *Associations, site, _ *assocProject) error { return p.Replace(site, &assocProject{Name: "App"}) },
+			live:    []string{"Site", "App"},
+			trashed: 1,
+		},
+		{
+			name:    "Clear removes every child",
+			act:     func(p *Associations, _, _ *assocProject) error { return p.Clear() },
+			live:    []string{},
+			trashed: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, team := assocORM(t)
+			projects := Association(db, team, "Projects")
+			site, api := &assocProject{Name: "Site"}, &assocProject{Name: "API"}
+			if err := projects.Append(site, api); err != nil {
+				t.Fatal(err)
+			}
+			if api.TeamID != team.ID {
+				t.Fatalf("appended project has team %d, want %d", api.TeamID, team.ID)
+			}
+			if err := tt.act(projects, site, api); err != nil {
+				t.Fatal(err)
+			}
+
+			var list []assocProject
+			if err := projects.Query().OrderBy("id", "ASC").Into(&list); err != nil {
+				t.Fatal(err)
+			}
+			names := []string{}
+			for _, p := range list {
+				names = append(names, p.Name)
+			}
+			if !reflect.DeepEqual(names, tt.live) {
+				t.Errorf("projects %v, want %v", names, tt.live)
+			}
+			if n, _ := projects.Count(); n != int64(len(tt.live)) {
+				t.Errorf("Count = %d, want %d", n, len(tt.live))
+			}
+			trashed, _ := NewRepository(db, &assocProject{}).Query().OnlyTrashed().Count()
+			if trashed != tt.trashed {
+				t.Errorf("%d trashed projects, want %d", trashed, tt.trashed)
+			}
+		})
+	}
+}
+
+func TestAssociationsManyToMany(t *testing.T) {
+	tests := []struct {
+		name   string
+		act    func(members *Associations, ann, bob, cy *assocMember) error
+		pivots []string
+	}{
+		{
+			name: "AttachWith stores the extra columns",
+			act: func(m *Associations, ann, _, _ *assocMember) error {
+				return m.AttachWith(Pivot{"role": "owner"}, ann)
+			},
+			pivots: []string{"Ann:owner"},
+		},
+		{
+			name: "Attach keeps the rows already attached",
+			act: func(m *Associations, ann, bob, _ *assocMember) error {
+				if err := m.AttachWith(Pivot{"role": "owner"}, ann); err != nil {
+					return err
+				}
+				return m.Attach(bob.ID, ann.ID)
+			},
+			pivots: []string{"Ann:owner", "Bob:<nil>"},
+		},
+		{
+			name: "SyncWith detaches, updates and attaches",
+			act: func(m *Associations, ann, bob, cy *assocMember) error {
+				if err := m.Attach(ann, bob); err != nil {
+					return err
+				}
+				return m.SyncWith(map[interface{}]Pivot{bob.ID: {"role": "editor"}, cy.ID: {"role": "viewer"}})
+			},
+			pivots: []string{"Bob:editor", "Cy:viewer"},
+		},
+		{
+			name: "Detach removes the join rows only",
+			act: func(m *Associations, ann, bob, _ *assocMember) error {
+				if err := m.Attach(ann, bob); err != nil {
+					return err
+				}
+				return m.Detach(ann)
+			},
+			pivots: []string{"Bob:<nil>"},
+		},
+		{
+			name: "Append saves new entities before attaching them",
+			act: func(m *Associations, _, _, _ *assocMember) error {
+				return m.Append(&assocMember{Name: "Dee"})
+			},
+			pivots: []string{"Dee:<nil>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, team := assocORM(t)
+			repo := NewRepository(db, &assocMember{})
+			ann, bob, cy := &assocMember{Name: "Ann"}, &assocMember{Name: "Bob"}, &assocMember{Name: "Cy"}
+			for _, m := range []*assocMember{ann, bob, cy} {
+				if err := repo.Save(m); err != nil {
+					t.Fatal(err)
+				}
+			}
+			members := Association(db, team, "Members")
+			if err := tt.act(members, ann, bob, cy); err != nil {
+				t.Fatal(err)
+			}
+
+			pivots, err := members.Pivots()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var list []assocMember
+			if err := members.Query().Into(&list); err != nil {
+				t.Fatal(err)
+			}
+			names := map[string]string{}
+			for _, m := range list {
+				names[fmt.Sprint(m.ID)] = m.Name
+			}
+			got := []string{}
+			for _, p := range pivots {
+				got = append(got, fmt.Sprintf("%s:%v", names[keyText(p["member_id"])], p["role"]))
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.pivots) {
+				t.Errorf("pivots %v, want %v", got, tt.pivots)
+			}
+			if n, _ := repo.Count(); n < 3 {
+				t.Errorf("members left in their table: %d", n)
+			}
+		})
+	}
+}
+
+func TestAssociationsMisuse(t *testing.T) {
+	db, team := assocORM(t)
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"Attach on a one-to-many", Association(db, team, "Projects").Attach(1)},
+		{"unsaved entity", Association(db, &assocTeam{}, "Members").Attach(1)},
+		{"unknown relation", Association(db, team, "Owners").Clear()},
+		{"wrong model", Association(db, team, "Projects").Append(&assocMember{Name: "Ann"})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,343 @@
+package shared
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	ormcore "github.com/ESGI-M2/GO/orm"
+)
+
+// CacheStore stores query results for ModelQuery.Cache. Entries are tagged with the
+// tables they were read from, so they can be dropped together. Implementations must
+// be safe for concurrent use; LRUCache keeps entries in process and RedisCache shares
+// them between replicas.
+type CacheStore interface {
+	// Get returns the value stored under key, reporting false when it is missing or
+	// expired
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores a value for ttl, or without expiry when ttl is zero
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error
+	Delete(ctx context.Context, keys ...string) error
+	// DeleteByTag removes every entry stored with one of the tags
+	DeleteByTag(ctx context.Context, tags ...string) error
+}
+
+// caches holds the store of each ORM, keyed like the event buses (see ormKey)
+var caches sync.Map
+
+// UseCache makes the queries of an ORM that ask for it with ModelQuery.Cache use
+// store; a nil store turns caching off again. Without a store Cache does nothing, like
+// the library's QueryBuilder.Cache.
+//
+//	shared.UseCache(db, shared.NewLRUCache(1000, 64<<20))
+func UseCache(orm ormcore.ORM, store CacheStore) {
+	if store == nil {
+		caches.Delete(ormKey(orm))
+		return
+	}
+	caches.Store(ormKey(orm), store)
+}
+
+// cacheOf returns the store of an ORM, nil when it has none
+func cacheOf(orm ormcore.ORM) CacheStore {
+	if store, ok := caches.Load(ormKey(orm)); ok {
+		return store.(CacheStore)
+	}
+	return nil
+}
+
+func init() {
+	// Types found in the rows the dialects return, so gob can encode them as
+	// interface values
+	gob.Register(time.Time{})
+	gob.Register(map[string]interface{}{})
+	gob.Register([]map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
+// Cache caches the result of the query for ttl seconds in the store given to UseCache.
+// Results are keyed by the SQL and arguments of the query and its eager-loaded
+// relations, and tagged with their tables. Queries in a transaction are not cached.
+// The cache is best effort: when the store fails, the query runs against the database.
+func (q *ModelQuery) Cache(ttl int) *ModelQuery {
+	c := *q
+	c.cacheTTL = time.Duration(ttl) * time.Second
+	return &c
+}
+
+// WithoutCache runs the query against the database
+func (q *ModelQuery) WithoutCache() *ModelQuery {
+	c := *q
+	c.cacheTTL = 0
+	return &c
+}
+
+// cached returns the result of load for the query from the cache, storing it there
+// on a miss. kind tells apart the terminal methods run on the same query.
+func cached[T any](q *ModelQuery, kind string, load func() (T, error)) (T, error) {
+	r := q.repo
+	store := cacheOf(r.orm)
+	if store == nil || q.cacheTTL <= 0 || r.err != nil || r.inTransaction() {
+		return load()
+	}
+	key, tables := q.cacheKey(kind)
+
+	// Wrapped in a struct, a nil result stays nil: gob skips zero fields
+	var entry struct{ Value T }
+	if data, ok, err := store.Get(r.ctx, key); err == nil && ok {
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entry); err == nil {
+			return entry.Value, nil
+		}
+	}
+	v, err := load()
+	if err != nil {
+		return v, err
+	}
+	entry.Value = v
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&entry); err == nil {
+		_ = store.Set(r.ctx, key, buf.Bytes(), q.cacheTTL, tables...)
+	}
+	return v, nil
+}
+
+// cacheKey identifies the query by the statements it runs, and lists their tables
+func (q *ModelQuery) cacheKey(kind string) (string, []string) {
+	r := q.repo
+	b := q.Builder()
+	parts := []interface{}{kind, b.GetSQL(), b.GetArgs()}
+	tables := []string{r.meta.TableName}
+	for _, rel := range q.relations {
+		parts = append(parts, rel.name)
+		if meta, model, ok := r.relationTarget(rel.name); ok {
+			rb := q.relationScope(rel)(r.orm.Query(model))
+			parts = append(parts, rb.GetSQL(), rb.GetArgs())
+			tables = append(tables, meta.TableName)
+		}
+	}
+	for _, name := range q.filtered {
+		tables = append(tables, r.relationTables(name)...)
+	}
+	for _, agg := range q.aggregates {
+		parts = append(parts, agg.alias())
+		if ab, err := r.aggregateQuery(agg, nil); err == nil {
+			parts = append(parts, ab.GetSQL(), ab.GetArgs())
+		}
+		tables = append(tables, r.relationTables(agg.relation)...)
+	}
+	data, _ := json.Marshal(parts)
+	sum := sha256.Sum256(data)
+	return "query:" + hex.EncodeToString(sum[:]), tables
+}
+
+// FlushCache drops the cached queries that read the tables of the given models
+//
+//	err := shared.FlushCache(db, &User{})
+func FlushCache(orm ormcore.ORM, models ...interface{}) error {
+	store := cacheOf(orm)
+	if store == nil {
+		return nil
+	}
+	var tables []string
+	for _, model := range models {
+		meta, err := orm.GetMetadata(model)
+		if err != nil {
+			return err
+		}
+		tables = append(tables, meta.TableName)
+	}
+	return store.DeleteByTag(context.Background(), tables...)
+}
+
+// invalidateCache drops the cached queries that read the repository's table, after a
+// write. In a transaction started by Transaction they are dropped again once it
+// commits, as a query run in between caches the rows from before the write; in other
+// transactions only the first drop happens.
+func (r *Repository) invalidateCache() {
+	r.invalidateTables(r.meta.TableName)
+}
+
+// invalidateTables is invalidateCache for writes to other tables, e.g. a join table
+func (r *Repository) invalidateTables(tables ...string) {
+	store := cacheOf(r.orm)
+	if store == nil {
+		return
+	}
+	_ = store.DeleteByTag(r.ctx, tables...)
+	for _, table := range tables {
+		touch(r.orm, table)
+	}
+}
+
+// DeleteBy deletes the rows matching every criterion through the library, without
+// hooks or events
+func (r *Repository) DeleteBy(criteria map[string]interface{}) error {
+	if err := r.Repository.DeleteBy(criteria); err != nil {
+		return err
+	}
+	r.invalidateCache()
+	return nil
+}
+
+// Increment adds amount to a column of every row through the library
+func (r *Repository) Increment(field string, amount interface{}) error {
+	if err := r.Repository.Increment(field, amount); err != nil {
+		return err
+	}
+	r.invalidateCache()
+	return nil
+}
+
+// Decrement subtracts amount from a column of every row through the library
+func (r *Repository) Decrement(field string, amount interface{}) error {
+	if err := r.Repository.Decrement(field, amount); err != nil {
+		return err
+	}
+	r.invalidateCache()
+	return nil
+}
+
+// LRUCache is an in-process CacheStore that evicts the least recently used entries
+// beyond a number of entries or a total size
+type LRUCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int64
+	now        func() time.Time
+
+	order *list.List // front is the most recently used
+	items map[string]*list.Element
+	tags  map[string]map[string]struct{}
+	size  int64
+	stats CacheStats
+}
+
+// CacheStats counts the activity of an LRUCache
+type CacheStats struct {
+	Hits, Misses, Evictions int64
+	Entries                 int
+	Bytes                   int64
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+	tags    []string
+}
+
+// NewLRUCache creates an LRUCache holding at most maxEntries entries and maxBytes
+// bytes of values; zero means no limit
+func NewLRUCache(maxEntries int, maxBytes int64) *LRUCache {
+	return &LRUCache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		now:        time.Now,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+		tags:       make(map[string]map[string]struct{}),
+	}
+}
+
+func (c *LRUCache) Get(_ context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if ok && c.expired(el.Value.(*lruEntry)) {
+		c.remove(el)
+		ok = false
+	}
+	if !ok {
+		c.stats.Misses++
+		return nil, false, nil
+	}
+	c.stats.Hits++
+	c.order.MoveToFront(el)
+	return el.Value.(*lruEntry).value, true, nil
+}
+
+// Set stores a value, evicting the least recently used entries to stay within the
+// limits. A value larger than the size limit is not stored.
+func (c *LRUCache) Set(_ context.Context, key string, value []byte, ttl time.Duration, tags ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	if c.maxBytes > 0 && int64(len(value)) > c.maxBytes {
+		return nil
+	}
+	e := &lruEntry{key: key, value: value, tags: tags}
+	if ttl > 0 {
+		e.expires = c.now().Add(ttl)
+	}
+	c.items[key] = c.order.PushFront(e)
+	c.size += int64(len(value))
+	for _, tag := range tags {
+		if c.tags[tag] == nil {
+			c.tags[tag] = make(map[string]struct{})
+		}
+		c.tags[tag][key] = struct{}{}
+	}
+	for (c.maxEntries > 0 && c.order.Len() > c.maxEntries) || (c.maxBytes > 0 && c.size > c.maxBytes) {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+	return nil
+}
+
+func (c *LRUCache) Delete(_ context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		if el, ok := c.items[key]; ok {
+			c.remove(el)
+		}
+	}
+	return nil
+}
+
+func (c *LRUCache) DeleteByTag(_ context.Context, tags ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, tag := range tags {
+		for key := range c.tags[tag] {
+			c.remove(c.items[key])
+		}
+	}
+	return nil
+}
+
+// Stats returns the counters and the current size of the cache
+func (c *LRUCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = c.order.Len()
+	stats.Bytes = c.size
+	return stats
+}
+
+func (c *LRUCache) expired(e *lruEntry) bool {
+	return !e.expires.IsZero() && !c.now().Before(e.expires)
+}
+
+// remove drops an entry and its tag memberships
+func (c *LRUCache) remove(el *list.Element) {
+	e := c.order.Remove(el).(*lruEntry)
+	delete(c.items, e.key)
+	c.size -= int64(len(e.value))
+	for _, tag := range e.tags {
+		delete(c.tags[tag], e.key)
+		if len(c.tags[tag]) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+}
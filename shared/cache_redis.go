@@ -0,0 +1,117 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisCache is a CacheStore on a Redis server, or anything speaking its protocol, so
+// the replicas of an application share cached results. Each tag is a sorted set of the
+// keys stored with it, scored by their expiry: expired keys are pruned from a tag when
+// it is written to, and the set itself expires with its last key.
+//
+// It takes a single-node *redis.Client: a key and its tags hash to different slots, so
+// the scripts keeping them in step cannot run on Redis Cluster.
+//
+//	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+//	shared.UseCache(db, shared.NewRedisCache(client, "myapp:"))
+type RedisCache struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisCache creates a RedisCache whose keys all start with prefix
+func NewRedisCache(client *redis.Client, prefix string) *RedisCache {
+	return &RedisCache{client: client, prefix: prefix}
+}
+
+// setTagged stores KEYS[1] for ARGV[2] milliseconds, or without expiry for 0, and adds
+// it to the tag sets in the other KEYS. Each tag set drops its expired keys and lives
+// as long as its longest-lived key.
+var setTagged = redis.NewScript(`
+local time = redis.call('TIME')
+local now = time[1] * 1000 + math.floor(time[2] / 1000)
+local ttl = tonumber(ARGV[2])
+local expiry = '+inf'
+if ttl > 0 then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ttl)
+	expiry = now + ttl
+else
+	redis.call('SET', KEYS[1], ARGV[1])
+end
+for i = 2, #KEYS do
+	local left = redis.call('PTTL', KEYS[i])
+	redis.call('ZREMRANGEBYSCORE', KEYS[i], '-inf', now)
+	redis.call('ZADD', KEYS[i], expiry, KEYS[1])
+	if ttl == 0 then
+		redis.call('PERSIST', KEYS[i])
+	elseif left == -2 or (left >= 0 and left < ttl) then
+		redis.call('PEXPIRE', KEYS[i], ttl)
+	end
+end
+return 1
+`)
+
+// deleteTagged removes the keys of a tag set and the set itself atomically, so a key
+// stored concurrently is either removed or kept with its tag
+var deleteTagged = redis.NewScript(`
+local keys = redis.call('ZRANGE', KEYS[1], 0, -1)
+for i = 1, #keys, 500 do
+	redis.call('DEL', unpack(keys, i, math.min(i + 499, #keys)))
+end
+return redis.call('DEL', KEYS[1])
+`)
+
+func (c *RedisCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := c.client.Get(ctx, c.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (c *RedisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error {
+	keys := make([]string, 0, len(tags)+1)
+	keys = append(keys, c.prefix+key)
+	for _, tag := range tags {
+		keys = append(keys, c.tagKey(tag))
+	}
+	ms := ttl.Milliseconds()
+	if ttl > 0 && ms == 0 {
+		ms = 1
+	}
+	return setTagged.Run(ctx, c.client, keys, value, strconv.FormatInt(ms, 10)).Err()
+}
+
+func (c *RedisCache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = c.prefix + key
+	}
+	return c.client.Del(ctx, prefixed...).Err()
+}
+
+// DeleteByTag removes the entries of each tag. Expired keys left in a tag set are
+// dropped along with it.
+func (c *RedisCache) DeleteByTag(ctx context.Context, tags ...string) error {
+	for _, tag := range tags {
+		if err := deleteTagged.Run(ctx, c.client, []string{c.tagKey(tag)}).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *RedisCache) tagKey(tag string) string {
+	return c.prefix + "tag:" + tag
+}
@@ -0,0 +1,89 @@
+package shared
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisCacheTagSets(t *testing.T) {
+	ctx := context.Background()
+	type set struct {
+		key string
+		ttl time.Duration
+	}
+	tests := []struct {
+		name string
+		// each step stores the keys, then moves the clock forward
+		steps   [][]set
+		advance time.Duration
+		members []string
+		ttl     time.Duration // of the tag set, zero when it does not expire
+	}{
+		{
+			name:    "the tag lives as long as its longest key",
+			steps:   [][]set{{{"a", time.Minute}, {"b", time.Hour}, {"c", time.Second}}},
+			members: []string{"test:a", "test:b", "test:c"},
+			ttl:     time.Hour,
+		},
+		{
+			name:    "a key without expiry keeps the tag",
+			steps:   [][]set{{{"a", time.Minute}, {"b", 0}}, {{"c", time.Second}}},
+			members: []string{"test:a", "test:b", "test:c"},
+		},
+		{
+			name:    "expired keys are pruned when the tag is written to",
+			steps:   [][]set{{{"a", time.Minute}, {"b", time.Hour}}, {{"c", time.Minute}}},
+			advance: 2 * time.Minute,
+			members: []string{"test:b", "test:c"},
+			ttl:     time.Hour - 2*time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			now := time.Now()
+			mr.SetTime(now)
+			client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			defer client.Close()
+			c := NewRedisCache(client, "test:")
+
+			for i, step := range tt.steps {
+				if i > 0 && tt.advance > 0 {
+					now = now.Add(tt.advance)
+					mr.SetTime(now)
+					mr.FastForward(tt.advance)
+				}
+				for _, s := range step {
+					if err := c.Set(ctx, s.key, []byte("v"), s.ttl, "users"); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			members, err := mr.ZMembers("test:tag:users")
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(members)
+			if !reflect.DeepEqual(members, tt.members) {
+				t.Errorf("tag members %v, want %v", members, tt.members)
+			}
+			if ttl := mr.TTL("test:tag:users"); ttl != tt.ttl {
+				t.Errorf("tag ttl %v, want %v", ttl, tt.ttl)
+			}
+
+			if err := c.DeleteByTag(ctx, "users"); err != nil {
+				t.Fatal(err)
+			}
+			if keys := mr.Keys(); len(keys) != 0 {
+				t.Errorf("keys left after DeleteByTag: %v", keys)
+			}
+		})
+	}
+}
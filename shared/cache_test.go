@@ -0,0 +1,241 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	ormcore "github.com/ESGI-M2/GO/orm"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// testStores returns each CacheStore with a function moving its clock forward
+func testStores(t *testing.T) map[string]func() (CacheStore, func(time.Duration)) {
+	return map[string]func() (CacheStore, func(time.Duration)){
+		"lru": func() (CacheStore, func(time.Duration)) {
+			now := time.Now()
+			c := NewLRUCache(0, 0)
+			c.now = func() time.Time { return now }
+			return c, func(d time.Duration) { now = now.Add(d) }
+		},
+		"redis": func() (CacheStore, func(time.Duration)) {
+			mr := miniredis.RunT(t)
+			now := time.Now()
+			mr.SetTime(now)
+			client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			t.Cleanup(func() { client.Close() })
+			return NewRedisCache(client, "test:"), func(d time.Duration) {
+				now = now.Add(d)
+				mr.SetTime(now)
+				mr.FastForward(d)
+			}
+		},
+	}
+}
+
+func TestCacheStores(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		act  func(s CacheStore, advance func(time.Duration)) error
+		// present lists the keys expected to be found afterwards among a, b and c
+		present map[string]bool
+	}{
+		{
+			name:    "Set then Get",
+			act:     func(CacheStore, func(time.Duration)) error { return nil },
+			present: map[string]bool{"a": true, "b": true, "c": true},
+		},
+		{
+			name:    "Delete",
+			act:     func(s CacheStore, _ func(time.Duration)) error { return s.Delete(ctx, "a", "missing") },
+			present: map[string]bool{"b": true, "c": true},
+		},
+		{
+			name:    "DeleteByTag drops every key of the tag",
+			act:     func(s CacheStore, _ func(time.Duration)) error { return s.DeleteByTag(ctx, "users") },
+			present: map[string]bool{"c": true},
+		},
+		{
+			name:    "DeleteByTag of an unknown tag",
+			act:     func(s CacheStore, _ func(time.Duration)) error { return s.DeleteByTag(ctx, "orders") },
+			present: map[string]bool{"a": true, "b": true, "c": true},
+		},
+		{
+			name: "entries expire after their ttl",
+			act: func(_ CacheStore, advance func(time.Duration)) error {
+				advance(2 * time.Minute)
+				return nil
+			},
+			// c was stored without expiry
+			present: map[string]bool{"b": true, "c": true},
+		},
+	}
+	for storeName, newStore := range testStores(t) {
+		for _, tt := range tests {
+			t.Run(storeName+"/"+tt.name, func(t *testing.T) {
+				s, advance := newStore()
+				must := func(err error) {
+					t.Helper()
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+				must(s.Set(ctx, "a", []byte("1"), time.Minute, "users"))
+				must(s.Set(ctx, "b", []byte("2"), time.Hour, "users", "posts"))
+				must(s.Set(ctx, "c", []byte("3"), 0, "posts"))
+				must(tt.act(s, advance))
+
+				for _, key := range []string{"a", "b", "c"} {
+					value, ok, err := s.Get(ctx, key)
+					must(err)
+					if ok != tt.present[key] {
+						t.Errorf("Get(%s) found %v, want %v", key, ok, tt.present[key])
+					}
+					if ok && len(value) != 1 {
+						t.Errorf("Get(%s) = %q", key, value)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestLRUCacheLimits(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name       string
+		maxEntries int
+		maxBytes   int64
+		values     map[string]int // key to value size, stored in key order
+		present    []string
+		evictions  int64
+	}{
+		{"entry limit evicts the least recently used", 2, 0, map[string]int{"a": 1, "b": 1, "c": 1}, []string{"a", "c"}, 1},
+		{"size limit", 0, 10, map[string]int{"a": 4, "b": 4, "c": 4}, []string{"a", "c"}, 1},
+		{"a value over the size limit is not stored", 0, 10, map[string]int{"a": 4, "b": 11}, []string{"a"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewLRUCache(tt.maxEntries, tt.maxBytes)
+			for _, key := range []string{"a", "b", "c"} {
+				size, ok := tt.values[key]
+				if !ok {
+					continue
+				}
+				_ = c.Set(ctx, key, make([]byte, size), 0)
+				if key == "b" {
+					// a becomes the most recently used
+					_, _, _ = c.Get(ctx, "a")
+				}
+			}
+			for _, key := range tt.present {
+				if _, ok, _ := c.Get(ctx, key); !ok {
+					t.Errorf("%s was evicted", key)
+				}
+			}
+			stats := c.Stats()
+			if stats.Entries != len(tt.present) || stats.Evictions != tt.evictions {
+				t.Errorf("stats %+v, want %d entries and %d evictions", stats, len(tt.present), tt.evictions)
+			}
+		})
+	}
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		act  func(db ormcore.ORM, users *Repository, ann, bob *User) error
+		want string
+	}{
+		{"Save", func(_ ormcore.ORM, users *Repository, _, _ *User) error {
+			return users.Save(&User{Name: "Cy", Email: "cy@example.com", Age: 40})
+		}, "Ann:20,Bob:30,Cy:40"},
+		{"Update", func(_ ormcore.ORM, users *Repository, _, bob *User) error {
+			bob.Age = 31
+			return users.Update(bob)
+		}, "Ann:20,Bob:31"},
+		{"UpdateFields", func(_ ormcore.ORM, users *Repository, _, bob *User) error {
+			bob.Age = 32
+			return users.UpdateFields(bob, "age")
+		}, "Ann:20,Bob:32"},
+		{"Delete", func(_ ormcore.ORM, users *Repository, ann, _ *User) error {
+			return users.Delete(ann)
+		}, "Bob:30"},
+		{"SoftDelete", func(_ ormcore.ORM, users *Repository, ann, _ *User) error {
+			return users.SoftDelete(ann)
+		}, "Bob:30"},
+		{"BatchCreate", func(_ ormcore.ORM, users *Repository, _, _ *User) error {
+			return users.BatchCreate([]interface{}{&User{Name: "Cy", Email: "cy@example.com", Age: 40}})
+		}, "Ann:20,Bob:30,Cy:40"},
+		{"DeleteBy", func(_ ormcore.ORM, users *Repository, _, _ *User) error {
+			return users.DeleteBy(map[string]interface{}{"name": "Ann"})
+		}, "Bob:30"},
+		{"Increment", func(_ ormcore.ORM, users *Repository, _, _ *User) error {
+			return users.Increment("age", 1)
+		}, "Ann:21,Bob:31"},
+		{"Decrement", func(_ ormcore.ORM, users *Repository, _, _ *User) error {
+			return users.Decrement("age", 1)
+		}, "Ann:19,Bob:29"},
+		{"write in a transaction", func(db ormcore.ORM, _ *Repository, _, _ *User) error {
+			return Transaction(ctx, db, func(tx ormcore.ORM) error {
+				return NewRepository(tx, &User{}).Save(&User{Name: "Cy", Email: "cy@example.com", Age: 40})
+			})
+		}, "Ann:20,Bob:30,Cy:40"},
+		{"raw SQL stays cached", func(db ormcore.ORM, _ *Repository, _, _ *User) error {
+			_, err := db.GetDialect().Exec("UPDATE users SET age = 50")
+			return err
+		}, "Ann:20,Bob:30"},
+		{"FlushCache after raw SQL", func(db ormcore.ORM, _ *Repository, _, _ *User) error {
+			if _, err := db.GetDialect().Exec("UPDATE users SET age = 50"); err != nil {
+				return err
+			}
+			return FlushCache(db, &User{})
+		}, "Ann:50,Bob:50"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := NewSQLiteORM(":memory:", &User{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer Close(db)
+			UseCache(db, NewLRUCache(100, 0))
+			users := NewRepository(db, &User{})
+			ann := &User{Name: "Ann", Email: "ann@example.com", Age: 20}
+			bob := &User{Name: "Bob", Email: "bob@example.com", Age: 30}
+			for _, u := range []*User{ann, bob} {
+				if err := users.Save(u); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cached := users.Query().OrderBy("id", "ASC").Cache(60)
+			list := func() string {
+				t.Helper()
+				rows, err := cached.Find()
+				if err != nil {
+					t.Fatal(err)
+				}
+				out := []string{}
+				for _, row := range rows {
+					out = append(out, fmt.Sprintf("%v:%v", row["name"], row["age"]))
+				}
+				return strings.Join(out, ",")
+			}
+			if got := list(); got != "Ann:20,Bob:30" {
+				t.Fatalf("before: %s", got)
+			}
+			if err := tt.act(db, users, ann, bob); err != nil {
+				t.Fatal(err)
+			}
+			if got := list(); got != tt.want {
+				t.Fatalf("after %s: %s, want %s", tt.name, got, tt.want)
+			}
+		})
+	}
+}
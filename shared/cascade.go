@@ -0,0 +1,136 @@
+package shared
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// softCascade is a relation tagged `cascade:soft`: its rows are soft deleted and
+// restored together with their parent
+//
+//	Posts []Post `orm:"relation:one_to_many,fk:user_id,cascade:soft"`
+type softCascade struct {
+	name       string
+	model      interface{}
+	foreignKey string
+}
+
+// softCascades lists the cascade:soft relations of an entity
+func softCascades(entity interface{}) []softCascade {
+	v, ok := structValue(entity)
+	if !ok {
+		return nil
+	}
+	var cascades []softCascade
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := parseTag(field)
+		if !tag.has("relation") || tag["cascade"] != "soft" {
+			continue
+		}
+		target := field.Type
+		for target.Kind() == reflect.Slice || target.Kind() == reflect.Ptr {
+			target = target.Elem()
+		}
+		fk := tag["fk"]
+		if fk == "" {
+			fk = tag["foreign_key"]
+		}
+		cascades = append(cascades, softCascade{field.Name, reflect.New(target).Interface(), fk})
+	}
+	return cascades
+}
+
+// cascadeSoft soft deletes the live children of the entity's cascade:soft relations
+// at the same instant as the parent, or, when at is zero, restores the children that
+// were deleted together with the parent at deletedAt. Children deleted on their own
+// before stay deleted. The children's hooks and events run, and their own cascades.
+func (r *Repository) cascadeSoft(entity interface{}, at, deletedAt time.Time) error {
+	cascades := softCascades(entity)
+	if len(cascades) == 0 {
+		return nil
+	}
+	pk, err := r.primaryKey(entity)
+	if err != nil {
+		return err
+	}
+	for _, c := range cascades {
+		if c.foreignKey == "" {
+			return fmt.Errorf("relation %s needs an fk to cascade soft deletes", c.name)
+		}
+		children := NewRepository(r.orm, c.model, WithClock(r.now)).WithContext(r.ctx)
+		if children.err != nil {
+			return children.err
+		}
+
+		q := children.Query().Where(c.foreignKey, "=", pk.Interface())
+		if at.IsZero() {
+			q = q.OnlyTrashed()
+		}
+		rows, err := q.Find()
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %w", c.name, err)
+		}
+		for _, row := range rows {
+			child := reflect.New(children.meta.Type).Interface()
+			if err := Decode(row, child); err != nil {
+				return err
+			}
+			if !at.IsZero() {
+				err = children.setDeletedAt(child, "SoftDelete", at)
+			} else if children.deletedAt(child).Equal(deletedAt) {
+				err = children.setDeletedAt(child, "Restore", time.Time{})
+			}
+			if err != nil {
+				return fmt.Errorf("failed to cascade to %s: %w", c.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// storedDeletedAt returns the soft-delete time stored for an entity about to be
+// restored with cascades, so its children deleted at that instant can be found.
+// The row is always read back, as the column may keep less precision than the
+// entity's field, e.g. whole seconds in a MySQL TIMESTAMP.
+func (r *Repository) storedDeletedAt(entity interface{}, at time.Time) (time.Time, error) {
+	if !at.IsZero() || len(softCascades(entity)) == 0 {
+		return time.Time{}, nil
+	}
+	pk, err := r.primaryKey(entity)
+	if err != nil {
+		return time.Time{}, err
+	}
+	row, err := r.Query().WithTrashed().Where(r.meta.PrimaryKey, "=", pk.Interface()).FindOne()
+	if err != nil || row == nil {
+		return time.Time{}, err
+	}
+	stored := reflect.New(r.meta.Type).Interface()
+	if err := Decode(row, stored); err != nil {
+		return time.Time{}, err
+	}
+	return r.deletedAt(stored), nil
+}
+
+// deletedAt returns the soft-delete time of an entity, zero when it is not deleted
+func (r *Repository) deletedAt(entity interface{}) time.Time {
+	v, ok := structValue(entity)
+	if !ok {
+		return time.Time{}
+	}
+	field, ok := fieldByColumn(v, r.meta.DeletedAt)
+	if !ok {
+		return time.Time{}
+	}
+	switch t := field.Interface().(type) {
+	case time.Time:
+		return t
+	case *time.Time:
+		if t != nil {
+			return *t
+		}
+	}
+	return time.Time{}
+}
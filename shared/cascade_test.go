@@ -0,0 +1,170 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	ormcore "github.com/ESGI-M2/GO/orm"
+)
+
+type cascadeAuthor struct {
+	ID        int              `table:"cascade_authors" orm:"pk,auto"`
+	Name      string           `orm:"column:name"`
+	DeletedAt *time.Time       `orm:"column:deleted_at,soft"`
+	Articles  []cascadeArticle `orm:"relation:one_to_many,fk:author_id,cascade:soft"`
+}
+
+type cascadeArticle struct {
+	ID        int              `table:"cascade_articles" orm:"pk,auto"`
+	AuthorID  int              `orm:"column:author_id"`
+	Title     string           `orm:"column:title"`
+	DeletedAt *time.Time       `orm:"column:deleted_at,soft"`
+	Comments  []cascadeComment `orm:"relation:one_to_many,fk:article_id,cascade:soft"`
+}
+
+var errLockedArticle = errors.New("article is locked")
+
+func (a *cascadeArticle) BeforeSoftDelete(ctx context.Context, tx ormcore.ORM) error {
+	if a.Title == "locked" {
+		return errLockedArticle
+	}
+	return nil
+}
+
+type cascadeComment struct {
+	ID        int        `table:"cascade_comments" orm:"pk,auto"`
+	ArticleID int        `orm:"column:article_id"`
+	Body      string     `orm:"column:body"`
+	DeletedAt *time.Time `orm:"column:deleted_at,soft"`
+}
+
+func TestCascadeSoft(t *testing.T) {
+	tests := []struct {
+		name    string
+		titles  []string
+		act     func(authors, articles *Repository, author *cascadeAuthor, first *cascadeArticle) error
+		wantErr error
+		live    []string // titles of the live articles afterwards
+		trashed []string
+		// comments counts the live comments, one per article
+		comments int64
+	}{
+		{
+			name:   "soft delete cascades to the children",
+			titles: []string{"Hello", "Again"},
+			act: func(authors, _ *Repository, author *cascadeAuthor, _ *cascadeArticle) error {
+				return authors.SoftDelete(author)
+			},
+			live:     []string{},
+			trashed:  []string{"Hello", "Again"},
+			comments: 0,
+		},
+		{
+			name:   "restore brings back the children deleted with the parent",
+			titles: []string{"Hello", "Again"},
+			act: func(authors, articles *Repository, author *cascadeAuthor, first *cascadeArticle) error {
+				if err := articles.SoftDelete(first); err != nil {
+					return err
+				}
+				// the second delete must fall on a later instant than the first
+				time.Sleep(time.Millisecond)
+				if err := authors.SoftDelete(author); err != nil {
+					return err
+				}
+				return authors.Restore(&cascadeAuthor{ID: author.ID})
+			},
+			live:     []string{"Again"},
+			trashed:  []string{"Hello"},
+			comments: 1,
+		},
+		{
+			name:   "restore matches the stored time, not the entity's",
+			titles: []string{"Hello", "Again"},
+			act: func(authors, _ *Repository, author *cascadeAuthor, _ *cascadeArticle) error {
+				if err := authors.SoftDelete(author); err != nil {
+					return err
+				}
+				// as a MySQL TIMESTAMP would, the rows keep whole seconds only
+				seconds := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+				for _, table := range []string{"cascade_authors", "cascade_articles", "cascade_comments"} {
+					if _, err := authors.orm.GetDialect().Exec("UPDATE "+table+" SET deleted_at = ?", seconds); err != nil {
+						return err
+					}
+				}
+				return authors.Restore(author)
+			},
+			live:     []string{"Hello", "Again"},
+			trashed:  []string{},
+			comments: 2,
+		},
+		{
+			name:   "a failing child hook rolls the parent back",
+			titles: []string{"Hello", "locked"},
+			act: func(authors, _ *Repository, author *cascadeAuthor, _ *cascadeArticle) error {
+				return authors.SoftDelete(author)
+			},
+			wantErr:  errLockedArticle,
+			live:     []string{"Hello", "locked"},
+			trashed:  []string{},
+			comments: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := NewSQLiteORM(":memory:", &cascadeAuthor{}, &cascadeArticle{}, &cascadeComment{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer Close(db)
+			authors := NewRepository(db, &cascadeAuthor{})
+			articles := NewRepository(db, &cascadeArticle{})
+			comments := NewRepository(db, &cascadeComment{})
+
+			author := &cascadeAuthor{Name: "Anna"}
+			if err := authors.Save(author); err != nil {
+				t.Fatal(err)
+			}
+			var first *cascadeArticle
+			for _, title := range tt.titles {
+				a := &cascadeArticle{AuthorID: author.ID, Title: title}
+				if err := articles.Save(a); err != nil {
+					t.Fatal(err)
+				}
+				if err := comments.Save(&cascadeComment{ArticleID: a.ID, Body: "nice"}); err != nil {
+					t.Fatal(err)
+				}
+				if first == nil {
+					first = a
+				}
+			}
+
+			err = tt.act(authors, articles, author, first)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error %v, want %v", err, tt.wantErr)
+			}
+			titles := func(q *ModelQuery) []string {
+				var list []cascadeArticle
+				if err := q.OrderBy("id", "ASC").Into(&list); err != nil {
+					t.Fatal(err)
+				}
+				out := []string{}
+				for _, a := range list {
+					out = append(out, a.Title)
+				}
+				return out
+			}
+			if got := titles(articles.Query()); !reflect.DeepEqual(got, tt.live) {
+				t.Errorf("live articles %v, want %v", got, tt.live)
+			}
+			if got := titles(articles.Query().OnlyTrashed()); !reflect.DeepEqual(got, tt.trashed) {
+				t.Errorf("trashed articles %v, want %v", got, tt.trashed)
+			}
+			if n, err := comments.Query().Count(); err != nil || n != tt.comments {
+				t.Errorf("live comments %d (%v), want %d", n, err, tt.comments)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package shared
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Decode copies a result row, as returned by Find/FindOne, into the struct pointed to by
+// dst. Columns are matched through the orm tags; fields implementing sql.Scanner (such
+// as JSON) decode themselves, and columns missing from the row are left untouched.
+func Decode(row map[string]interface{}, dst interface{}) error {
+	v, ok := structValue(dst)
+	if !ok || !v.CanSet() {
+		return fmt.Errorf("decode target must be a pointer to a struct, got %T", dst)
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		col, ok := columnName(t.Field(i))
+		if !ok {
+			continue
+		}
+		value, exists := row[col]
+		if !exists {
+			continue
+		}
+		if err := assign(v.Field(i), value); err != nil {
+			return fmt.Errorf("failed to decode column %s: %w", col, err)
+		}
+	}
+	return nil
+}
+
+// DecodeAll decodes every row into a new element of the slice pointed to by dst
+func DecodeAll(rows []map[string]interface{}, dst interface{}) error {
+	sv := reflect.ValueOf(dst)
+	if sv.Kind() != reflect.Ptr || sv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("decode target must be a pointer to a slice, got %T", dst)
+	}
+	slice := sv.Elem()
+	elemType := slice.Type().Elem()
+
+	for _, row := range rows {
+		elem := reflect.New(elemType)
+		if elemType.Kind() == reflect.Ptr {
+			elem.Elem().Set(reflect.New(elemType.Elem()))
+			if err := Decode(row, elem.Elem().Interface()); err != nil {
+				return err
+			}
+		} else if err := Decode(row, elem.Interface()); err != nil {
+			return err
+		}
+		slice.Set(reflect.Append(slice, elem.Elem()))
+	}
+	return nil
+}
+
+// assign stores a driver value into a struct field, converting between the
+// representations the MySQL, Postgres and SQLite drivers return
+func assign(field reflect.Value, value interface{}) error {
+	if scanner, ok := field.Addr().Interface().(sql.Scanner); ok {
+		return scanner.Scan(value)
+	}
+
+	if value == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+
+	if field.Kind() == reflect.Ptr {
+		target := reflect.New(field.Type().Elem())
+		if err := assign(target.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(target)
+		return nil
+	}
+
+	if b, ok := value.([]byte); ok {
+		value = string(b)
+	}
+
+	if field.Type() == reflect.TypeOf(time.Time{}) {
+		switch v := value.(type) {
+		case time.Time:
+			field.Set(reflect.ValueOf(v))
+		case string:
+			parsed, err := parseTime(v)
+			if err != nil {
+				return err
+			}
+			field.Set(reflect.ValueOf(parsed))
+		default:
+			return fmt.Errorf("cannot convert %T to time.Time", value)
+		}
+		return nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if s, ok := value.(string); ok {
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return err
+			}
+			field.SetInt(n)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if s, ok := value.(string); ok {
+			n, err := strconv.ParseUint(s, 10, 64)
+			if err != nil {
+				return err
+			}
+			field.SetUint(n)
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if s, ok := value.(string); ok {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return err
+			}
+			field.SetFloat(f)
+			return nil
+		}
+	case reflect.String:
+		// a number stored in a text column comes back as a number from SQLite; Convert
+		// would turn it into the rune with that code point
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			field.SetString(strconv.FormatInt(rv.Int(), 10))
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			field.SetString(strconv.FormatUint(rv.Uint(), 10))
+			return nil
+		case reflect.Float32, reflect.Float64:
+			field.SetString(strconv.FormatFloat(rv.Float(), 'f', -1, 64))
+			return nil
+		case reflect.Bool:
+			field.SetString(strconv.FormatBool(rv.Bool()))
+			return nil
+		}
+	case reflect.Bool:
+		switch v := value.(type) {
+		case int64:
+			field.SetBool(v != 0)
+			return nil
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return err
+			}
+			field.SetBool(b)
+			return nil
+		}
+	}
+
+	if rv.Type().ConvertibleTo(field.Type()) && rv.Kind() != reflect.String && field.Kind() != reflect.String || rv.Type() == field.Type() {
+		field.Set(rv.Convert(field.Type()))
+		return nil
+	}
+	if rv.Kind() == reflect.String && field.Kind() == reflect.String {
+		field.SetString(rv.String())
+		return nil
+	}
+	return fmt.Errorf("cannot convert %T to %s", value, field.Type())
+}
+
+// timeLayouts are the textual timestamp formats drivers hand back
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// parseTime parses a textual timestamp in any of the known driver formats
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q as time", s)
+}
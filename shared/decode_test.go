@@ -0,0 +1,119 @@
+package shared
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type decodeProfile struct {
+	Plan  string `json:"plan"`
+	Seats int    `json:"seats"`
+}
+
+type decodeTarget struct {
+	ID       int                 `orm:"pk,auto"`
+	Name     string              `orm:"column:name"`
+	Code     string              `orm:"column:code"`
+	Score    float64             `orm:"column:score"`
+	Active   bool                `orm:"column:active"`
+	Seen     time.Time           `orm:"column:seen"`
+	Deleted  *time.Time          `orm:"column:deleted"`
+	Profile  JSON[decodeProfile] `orm:"column:profile"`
+	Untagged string
+}
+
+func TestDecode(t *testing.T) {
+	seen := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	tests := []struct {
+		name  string
+		row   map[string]interface{}
+		check func(d decodeTarget) bool
+	}{
+		{"int64 into int", map[string]interface{}{"id": int64(7)}, func(d decodeTarget) bool { return d.ID == 7 }},
+		{"bytes into int", map[string]interface{}{"id": []byte("42")}, func(d decodeTarget) bool { return d.ID == 42 }},
+		{"bytes into string", map[string]interface{}{"name": []byte("Ann")}, func(d decodeTarget) bool { return d.Name == "Ann" }},
+		{"int64 into string", map[string]interface{}{"code": int64(65)}, func(d decodeTarget) bool { return d.Code == "65" }},
+		{"float into string", map[string]interface{}{"code": 1.5}, func(d decodeTarget) bool { return d.Code == "1.5" }},
+		{"bool into string", map[string]interface{}{"code": true}, func(d decodeTarget) bool { return d.Code == "true" }},
+		{"text into float", map[string]interface{}{"score": "2.25"}, func(d decodeTarget) bool { return d.Score == 2.25 }},
+		{"int64 into bool", map[string]interface{}{"active": int64(1)}, func(d decodeTarget) bool { return d.Active }},
+		{"text into bool", map[string]interface{}{"active": "true"}, func(d decodeTarget) bool { return d.Active }},
+		{"time into time", map[string]interface{}{"seen": seen}, func(d decodeTarget) bool { return d.Seen.Equal(seen) }},
+		{"text into time", map[string]interface{}{"seen": "2024-05-06 07:08:09"}, func(d decodeTarget) bool { return d.Seen.Equal(seen) }},
+		{"text into time pointer", map[string]interface{}{"deleted": "2024-05-06T07:08:09Z"}, func(d decodeTarget) bool { return d.Deleted != nil && d.Deleted.Equal(seen) }},
+		{"nil into time pointer", map[string]interface{}{"deleted": nil}, func(d decodeTarget) bool { return d.Deleted == nil }},
+		{"JSON text", map[string]interface{}{"profile": `{"plan":"pro","seats":3}`}, func(d decodeTarget) bool {
+			return d.Profile.Data == decodeProfile{Plan: "pro", Seats: 3}
+		}},
+		{"JSON bytes", map[string]interface{}{"profile": []byte(`{"plan":"team"}`)}, func(d decodeTarget) bool { return d.Profile.Data.Plan == "team" }},
+		{"missing columns are left alone", map[string]interface{}{}, func(d decodeTarget) bool { return d.Name == "kept" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := decodeTarget{Name: "kept"}
+			if err := Decode(tt.row, &d); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if !tt.check(d) {
+				t.Fatalf("unexpected result %+v", d)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		row  map[string]interface{}
+		dst  interface{}
+	}{
+		{"not a pointer", map[string]interface{}{}, decodeTarget{}},
+		{"not a struct", map[string]interface{}{}, new(int)},
+		{"text into int", map[string]interface{}{"id": "seven"}, &decodeTarget{}},
+		{"unparsable time", map[string]interface{}{"seen": "yesterday"}, &decodeTarget{}},
+		{"bad JSON", map[string]interface{}{"profile": "{"}, &decodeTarget{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Decode(tt.row, tt.dst); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestDecodeAllFromSQLite(t *testing.T) {
+	type row struct {
+		ID   int    `table:"decode_rows" orm:"pk,auto"`
+		Code string `orm:"column:code"`
+	}
+	orm, err := NewSQLiteORM(":memory:", &row{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer orm.Close()
+	// SQLite hands back a number stored in a text column as a number
+	if _, err := orm.GetDialect().Exec("INSERT INTO decode_rows (code) VALUES (123), ('abc')"); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := orm.Query(&row{}).OrderBy("id", "ASC").Find()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dst := range []interface{}{&[]row{}, &[]*row{}} {
+		if err := DecodeAll(rows, dst); err != nil {
+			t.Fatalf("DecodeAll(%T): %v", dst, err)
+		}
+		got := reflect.ValueOf(dst).Elem()
+		if got.Len() != 2 {
+			t.Fatalf("DecodeAll(%T) decoded %d rows", dst, got.Len())
+		}
+		first := reflect.Indirect(got.Index(0)).FieldByName("Code").String()
+		second := reflect.Indirect(got.Index(1)).FieldByName("Code").String()
+		if first != "123" || second != "abc" {
+			t.Fatalf("DecodeAll(%T) codes %q, %q", dst, first, second)
+		}
+	}
+}
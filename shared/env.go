@@ -0,0 +1,128 @@
+package shared
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	ormcore "github.com/ESGI-M2/GO/orm"
+	"github.com/ESGI-M2/GO/orm/builder"
+	"github.com/ESGI-M2/GO/orm/factory"
+	"github.com/joho/godotenv"
+)
+
+// SQLite names the in-tree SQLite dialect, which the library's factory does not know
+const SQLite factory.DialectType = "sqlite"
+
+// DialectFromEnv returns the dialect named by DB_DIALECT, defaulting to MySQL.
+// Variables already set in the shell win over the sample env file.
+func DialectFromEnv() factory.DialectType {
+	_ = godotenv.Load("../shared/env.sample")
+
+	switch strings.ToLower(os.Getenv("DB_DIALECT")) {
+	case "postgres", "postgresql":
+		return factory.Postgres
+	case "sqlite", "sqlite3":
+		return SQLite
+	default:
+		return factory.MySQL
+	}
+}
+
+// SimpleORM is the library's builder.SimpleORM for the dialects of DialectFromEnv:
+// with DB_DIALECT=sqlite it opens the SQLite database named by SQLITE_DATABASE
+// (":memory:" by default) instead, so the demos run without a database server.
+// factory.Mock opens a fresh ":memory:" SQLite database, where the library's mock
+// dialect returns no rows at all.
+//
+//	orm := shared.NewSimpleORM().WithConfigBuilder(cfg).RegisterModels(&shared.User{})
+//	if err := orm.Connect(); err != nil { ... }
+//	defer orm.Close()
+type SimpleORM struct {
+	simple      *builder.SimpleORM
+	dialectType factory.DialectType
+	models      []interface{}
+	orm         ormcore.ORM
+}
+
+// NewSimpleORM creates a SimpleORM
+func NewSimpleORM() *SimpleORM {
+	return &SimpleORM{simple: builder.NewSimpleORM(), dialectType: factory.MySQL}
+}
+
+// WithConfigBuilder uses a config builder; only its dialect matters for SQLite
+func (s *SimpleORM) WithConfigBuilder(cb *builder.ConfigBuilder) *SimpleORM {
+	s.dialectType = cb.GetDialectType()
+	if !s.inProcess() {
+		s.simple.WithConfigBuilder(cb)
+	}
+	return s
+}
+
+// WithDialect sets the dialect, e.g. factory.Mock, which needs no other configuration
+func (s *SimpleORM) WithDialect(dialectType factory.DialectType) *SimpleORM {
+	s.dialectType = dialectType
+	if !s.inProcess() {
+		s.simple.WithDialect(dialectType)
+	}
+	return s
+}
+
+// RegisterModels registers models, whose tables Connect creates
+func (s *SimpleORM) RegisterModels(models ...interface{}) *SimpleORM {
+	s.models = append(s.models, models...)
+	s.simple.RegisterModels(models...)
+	return s
+}
+
+// Connect opens the database, registers the models and creates their tables
+func (s *SimpleORM) Connect() error {
+	if s.orm != nil {
+		return nil
+	}
+	if !s.inProcess() {
+		if err := s.simple.Connect(); err != nil {
+			return err
+		}
+		s.orm = s.simple.GetORM()
+		return nil
+	}
+	path := os.Getenv("SQLITE_DATABASE")
+	if path == "" || s.dialectType == factory.Mock {
+		path = ":memory:"
+	}
+	orm, err := NewSQLiteORM(path, s.models...)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	s.orm = orm
+	return nil
+}
+
+// GetORM returns the connected ORM, or nil before Connect
+func (s *SimpleORM) GetORM() ormcore.ORM {
+	return s.orm
+}
+
+// GetDialectType returns the dialect the ORM connects to
+func (s *SimpleORM) GetDialectType() factory.DialectType {
+	return s.dialectType
+}
+
+// Close closes the database connection and, like shared.Close, drops the ORM's event
+// bus and cache store
+func (s *SimpleORM) Close() error {
+	if s.orm == nil {
+		return nil
+	}
+	if !s.inProcess() {
+		release(s.orm)
+		return s.simple.Close()
+	}
+	return Close(s.orm)
+}
+
+// inProcess reports whether the dialect is served by SQLite rather than the factory
+func (s *SimpleORM) inProcess() bool {
+	return s.dialectType == SQLite || s.dialectType == factory.Mock
+}
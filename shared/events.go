@@ -0,0 +1,384 @@
+package shared
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	ormcore "github.com/ESGI-M2/GO/orm"
+	"github.com/ESGI-M2/GO/orm/core/connection"
+)
+
+// EventType names a change published on an EventBus
+type EventType string
+
+const (
+	EventCreated  EventType = "created"
+	EventUpdated  EventType = "updated"
+	EventDeleted  EventType = "deleted"
+	EventRestored EventType = "restored"
+	// EventCommitted is published once per model after a commit, with every change
+	// the transaction made to that model in Changes
+	EventCommitted EventType = "committed"
+)
+
+// Event describes a change written through a shared.Repository. Before and After map
+// columns to values and are copies, so asynchronous handlers can read them safely;
+// Entity is the caller's pointer and may have changed since.
+type Event struct {
+	Type   EventType
+	Model  reflect.Type
+	Table  string
+	Key    interface{}
+	Entity interface{}
+	// Soft is set on EventDeleted when the row was soft deleted
+	Soft bool
+	// Before is nil for EventCreated, After for a hard EventDeleted
+	Before  map[string]interface{}
+	After   map[string]interface{}
+	Changes []Event
+}
+
+// Changed lists, sorted, the columns whose value differs between Before and After
+func (e Event) Changed() []string {
+	var changed []string
+	for col, after := range e.After {
+		if before, ok := e.Before[col]; !ok || !sameValue(before, after) {
+			changed = append(changed, col)
+		}
+	}
+	for col := range e.Before {
+		if _, ok := e.After[col]; !ok {
+			changed = append(changed, col)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// sameValue compares column values, times by instant rather than representation
+func sameValue(a, b interface{}) bool {
+	if ta, ok := a.(time.Time); ok {
+		tb, ok := b.(time.Time)
+		return ok && ta.Equal(tb)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// EventHandler receives published events
+type EventHandler func(Event)
+
+// SubscribeOption configures a subscription
+type SubscribeOption func(*subscription)
+
+// Async runs the handler in its own goroutine; EventBus.Wait waits for it
+func Async() SubscribeOption {
+	return func(s *subscription) {
+		s.async = true
+	}
+}
+
+type subscription struct {
+	id      int
+	event   EventType
+	model   reflect.Type
+	handler EventHandler
+	async   bool
+}
+
+// EventBus delivers the changes made through shared.Repository to subscribers, after
+// the transaction that made them commits. Changes of a rolled back transaction are
+// never delivered.
+type EventBus struct {
+	mu     sync.RWMutex
+	subs   []subscription
+	nextID int
+	wg     sync.WaitGroup
+}
+
+// pendingTx collects the writes of a transaction started by Transaction until it ends
+type pendingTx struct {
+	mu     sync.Mutex
+	events []Event
+	// tables whose cached queries are dropped again once the transaction commits
+	tables []string
+	// undo puts back the entities written in the transaction if it rolls back
+	undo []func()
+}
+
+// pending holds the pendingTx of each transaction started by Transaction, keyed by its
+// transaction-scoped ORM, while the transaction runs
+var pending sync.Map
+
+// pendingOf returns the pendingTx of a transaction-scoped ORM, or nil when the ORM is
+// not in a transaction started by Transaction
+func pendingOf(orm ormcore.ORM) *pendingTx {
+	if p, ok := pending.Load(orm); ok {
+		return p.(*pendingTx)
+	}
+	return nil
+}
+
+// buses holds the bus of each ORM, keyed by ormKey
+var buses sync.Map
+
+// ormKey identifies an ORM together with the transaction-scoped ORMs started from it,
+// which share its metadata manager
+func ormKey(orm ormcore.ORM) interface{} {
+	if impl, ok := orm.(*connection.ORMImpl); ok && impl.MetadataManager != nil {
+		return impl.MetadataManager
+	}
+	return orm
+}
+
+// Events returns the event bus of an ORM, creating it on first use
+func Events(orm ormcore.ORM) *EventBus {
+	bus, _ := buses.LoadOrStore(ormKey(orm), &EventBus{})
+	return bus.(*EventBus)
+}
+
+// Close closes an ORM and drops what this package holds for it: its event bus, with
+// the subscribers, and its cache store
+func Close(orm ormcore.ORM) error {
+	release(orm)
+	return orm.Close()
+}
+
+func release(orm ormcore.ORM) {
+	buses.Delete(ormKey(orm))
+	caches.Delete(ormKey(orm))
+}
+
+// eventsOf returns the bus of an ORM, or nil when nothing ever subscribed to it
+func eventsOf(orm ormcore.ORM) *EventBus {
+	if bus, ok := buses.Load(ormKey(orm)); ok {
+		return bus.(*EventBus)
+	}
+	return nil
+}
+
+// Subscribe registers a handler for an event on a model, or on every model when model
+// is nil. Handlers run synchronously after the commit unless Async is given. The
+// returned function removes the subscription.
+func (b *EventBus) Subscribe(event EventType, model interface{}, handler EventHandler, opts ...SubscribeOption) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextID++
+	sub := subscription{id: b.nextID, event: event, handler: handler}
+	if model != nil {
+		sub.model = modelType(model)
+	}
+	for _, opt := range opts {
+		opt(&sub)
+	}
+	b.subs = append(b.subs, sub)
+
+	id := sub.id
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for i, s := range b.subs {
+			if s.id == id {
+				b.subs = append(b.subs[:i], b.subs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Wait blocks until every asynchronous handler started so far has returned
+func (b *EventBus) Wait() {
+	b.wg.Wait()
+}
+
+// Transaction runs fn in a transaction and publishes the changes made through
+// repositories bound to tx (see Repository.WithTx) once it commits. The cached
+// queries on the tables they wrote to are dropped then too (see UseCache). If it rolls
+// back, the entities they wrote get back the primary key, version and snapshot they
+// had before.
+func Transaction(ctx context.Context, orm ormcore.ORM, fn func(tx ormcore.ORM) error) error {
+	p := &pendingTx{}
+	committed := false
+	// deferred, so that a panicking fn puts the entities back too
+	defer func() {
+		if !committed {
+			p.rollback()
+		}
+	}()
+	err := orm.TransactionWithContext(ctx, func(tx ormcore.ORM) error {
+		pending.Store(tx, p)
+		defer pending.Delete(tx)
+		return fn(tx)
+	})
+	if err != nil {
+		return err
+	}
+	committed = true
+	if store := cacheOf(orm); store != nil && len(p.tables) > 0 {
+		_ = store.DeleteByTag(ctx, p.tables...)
+	}
+	if bus := eventsOf(orm); bus != nil {
+		bus.dispatch(p.events)
+	}
+	return nil
+}
+
+// onRollback registers fn to run if the transaction rolls back
+func (p *pendingTx) onRollback(fn func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.undo = append(p.undo, fn)
+}
+
+// rollback runs the undo functions, the last registered first
+func (p *pendingTx) rollback() {
+	p.mu.Lock()
+	undo := p.undo
+	p.undo = nil
+	p.mu.Unlock()
+	for i := len(undo) - 1; i >= 0; i-- {
+		undo[i]()
+	}
+}
+
+// subscribed reports whether any handler listens to changes of the model
+func (b *EventBus) subscribed(model reflect.Type) bool {
+	if b == nil {
+		return false
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, s := range b.subs {
+		if s.model == nil || s.model == model {
+			return true
+		}
+	}
+	return false
+}
+
+// enqueue holds an event until the transaction it was written in commits. Outside a
+// transaction the write is already committed and the event is delivered at once.
+// Inside a transaction not started by Transaction its outcome is unknown, so the event
+// is dropped.
+func (b *EventBus) enqueue(orm ormcore.ORM, e Event) {
+	if p := pendingOf(orm); p != nil {
+		p.mu.Lock()
+		p.events = append(p.events, e)
+		p.mu.Unlock()
+		return
+	}
+	if _, inTx := orm.GetDialect().(*connection.TransactionDialect); inTx {
+		return
+	}
+	b.dispatch([]Event{e})
+}
+
+// touch records a write to a table in a transaction started by Transaction
+func touch(orm ormcore.ORM, table string) {
+	p := pendingOf(orm)
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !contains(p.tables, table) {
+		p.tables = append(p.tables, table)
+	}
+}
+
+// dispatch delivers committed events, then one EventCommitted per model
+func (b *EventBus) dispatch(events []Event) {
+	var models []reflect.Type
+	changes := map[reflect.Type][]Event{}
+	for _, e := range events {
+		b.deliver(e)
+		if _, ok := changes[e.Model]; !ok {
+			models = append(models, e.Model)
+		}
+		changes[e.Model] = append(changes[e.Model], e)
+	}
+	for _, model := range models {
+		first := changes[model][0]
+		b.deliver(Event{Type: EventCommitted, Model: model, Table: first.Table, Changes: changes[model]})
+	}
+}
+
+func (b *EventBus) deliver(e Event) {
+	b.mu.RLock()
+	var subs []subscription
+	for _, s := range b.subs {
+		if s.event == e.Type && (s.model == nil || s.model == e.Model) {
+			subs = append(subs, s)
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, s := range subs {
+		if s.async {
+			b.wg.Add(1)
+			go func(h EventHandler) {
+				defer b.wg.Done()
+				h(e)
+			}(s.handler)
+			continue
+		}
+		s.handler(e)
+	}
+}
+
+// modelType returns the struct type of a model or model pointer
+func modelType(model interface{}) reflect.Type {
+	t := reflect.TypeOf(model)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// previous returns the column values the entity's row holds before a write, when
+// anyone listens: the tracked snapshot if there is one, otherwise the stored row
+func (r *Repository) previous(entity interface{}) map[string]interface{} {
+	if !eventsOf(r.orm).subscribed(r.meta.Type) {
+		return nil
+	}
+	if snapshot, ok := r.tracked.get(entity); ok {
+		values := make(map[string]interface{}, len(snapshot))
+		for col, value := range snapshot {
+			values[col] = value
+		}
+		return values
+	}
+	pk, err := r.primaryKey(entity)
+	if err != nil {
+		return nil
+	}
+	row, err := r.orm.Query(r.model).Where(r.meta.PrimaryKey, "=", pk.Interface()).FindOne()
+	if err != nil || row == nil {
+		return nil
+	}
+	stored := reflect.New(r.meta.Type)
+	if err := Decode(row, stored.Interface()); err != nil {
+		return nil
+	}
+	return columnValues(stored.Interface())
+}
+
+// publish hands a change of the entity to the ORM's event bus, if any
+func (r *Repository) publish(e Event, entity interface{}) {
+	bus := eventsOf(r.orm)
+	if !bus.subscribed(r.meta.Type) {
+		return
+	}
+	e.Model = r.meta.Type
+	e.Table = r.meta.TableName
+	e.Entity = entity
+	if pk, err := r.primaryKey(entity); err == nil {
+		e.Key = pk.Interface()
+	}
+	if e.Type != EventDeleted || e.Soft {
+		e.After = columnValues(entity)
+	}
+	bus.enqueue(r.orm, e)
+}
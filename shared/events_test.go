@@ -0,0 +1,165 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	ormcore "github.com/ESGI-M2/GO/orm"
+)
+
+func TestEventBus(t *testing.T) {
+	ctx := context.Background()
+	errAbort := errors.New("abort")
+	newUser := func(name string) *User {
+		return &User{Name: name, Email: name + "@example.com"}
+	}
+	tests := []struct {
+		name    string
+		act     func(db ormcore.ORM, delivered *[]string) error
+		wantErr error
+		events  []string
+	}{
+		{
+			name: "outside a transaction",
+			act: func(db ormcore.ORM, _ *[]string) error {
+				return NewRepository(db, &User{}).Save(newUser("ann"))
+			},
+			events: []string{"created users", "committed users"},
+		},
+		{
+			name: "after the commit of Transaction",
+			act: func(db ormcore.ORM, delivered *[]string) error {
+				return Transaction(ctx, db, func(tx ormcore.ORM) error {
+					users := NewRepository(tx, &User{})
+					if err := users.Save(newUser("ann")); err != nil {
+						return err
+					}
+					if err := users.Save(newUser("bob")); err != nil {
+						return err
+					}
+					if len(*delivered) != 0 {
+						return fmt.Errorf("delivered before the commit: %v", *delivered)
+					}
+					return nil
+				})
+			},
+			events: []string{"created users", "created users", "committed users"},
+		},
+		{
+			name: "never after a rollback",
+			act: func(db ormcore.ORM, _ *[]string) error {
+				return Transaction(ctx, db, func(tx ormcore.ORM) error {
+					if err := NewRepository(tx, &User{}).Save(newUser("ann")); err != nil {
+						return err
+					}
+					return errAbort
+				})
+			},
+			wantErr: errAbort,
+			events:  []string{},
+		},
+		{
+			name: "writes in a transaction the bus did not start publish nothing",
+			act: func(db ormcore.ORM, _ *[]string) error {
+				return db.Transaction(func(tx ormcore.ORM) error {
+					return NewRepository(tx, &User{}).Save(newUser("ann"))
+				})
+			},
+			events: []string{},
+		},
+		{
+			name: "subscriptions to another model",
+			act: func(db ormcore.ORM, _ *[]string) error {
+				// the library's repository publishes nothing
+				author := newUser("ann")
+				if err := db.Repository(&User{}).Save(author); err != nil {
+					return err
+				}
+				return NewRepository(db, &Post{}).Save(&Post{Title: "Hello", UserID: author.ID})
+			},
+			events: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := NewSQLiteORM(":memory:", &User{}, &Post{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer Close(db)
+			delivered := []string{}
+			record := func(e Event) { delivered = append(delivered, fmt.Sprintf("%s %s", e.Type, e.Table)) }
+			for _, event := range []EventType{EventCreated, EventUpdated, EventDeleted, EventCommitted} {
+				Events(db).Subscribe(event, &User{}, record)
+			}
+
+			if err := tt.act(db, &delivered); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(delivered, tt.events) {
+				t.Errorf("events %v, want %v", delivered, tt.events)
+			}
+			if n, _ := NewRepository(db, &User{}).Count(); tt.wantErr != nil && n != 0 {
+				t.Errorf("%d users left by a failed transaction", n)
+			}
+			if n := pendingCount(); n != 0 {
+				t.Errorf("%d transactions left pending", n)
+			}
+		})
+	}
+}
+
+func TestEventBusLifecycle(t *testing.T) {
+	db, err := NewSQLiteORM(":memory:", &User{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bus := Events(db)
+	count := 0
+	unsubscribe := bus.Subscribe(EventCreated, nil, func(Event) { count++ })
+	users := NewRepository(db, &User{})
+
+	t.Run("a panicking transaction is not left pending", func(t *testing.T) {
+		func() {
+			defer func() { _ = recover() }()
+			_ = Transaction(context.Background(), db, func(tx ormcore.ORM) error {
+				panic("boom")
+			})
+		}()
+		if n := pendingCount(); n != 0 {
+			t.Fatalf("%d transactions left pending", n)
+		}
+	})
+	t.Run("unsubscribe", func(t *testing.T) {
+		if err := users.Save(&User{Name: "ann", Email: "ann@example.com"}); err != nil {
+			t.Fatal(err)
+		}
+		unsubscribe()
+		if err := users.Save(&User{Name: "bob", Email: "bob@example.com"}); err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Fatalf("handler ran %d times, want 1", count)
+		}
+	})
+	t.Run("Close releases the bus", func(t *testing.T) {
+		if err := Close(db); err != nil {
+			t.Fatal(err)
+		}
+		if eventsOf(db) != nil {
+			t.Fatal("the bus outlived its ORM")
+		}
+	})
+}
+
+func pendingCount() int {
+	n := 0
+	pending.Range(func(_, _ interface{}) bool {
+		n++
+		return true
+	})
+	return n
+}
@@ -0,0 +1,195 @@
+package shared
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ESGI-M2/GO/dialect"
+	"github.com/ESGI-M2/GO/orm/core/connection"
+	"github.com/ESGI-M2/GO/orm/core/interfaces"
+	"github.com/ESGI-M2/GO/orm/core/query"
+)
+
+// Expr is a reusable query fragment, shaped like the functions in ModelMetadata.Scopes.
+type Expr func(interfaces.QueryBuilder) interfaces.QueryBuilder
+
+// Apply applies the expressions to the query builder in order.
+func Apply(q interfaces.QueryBuilder, exprs ...Expr) interfaces.QueryBuilder {
+	for _, expr := range exprs {
+		q = expr(q)
+	}
+	return q
+}
+
+// Expressions renders dialect-specific SQL for the helpers the Dialect interface only
+// exposes as bare strings (GetRandomFunction, GetJSONExtract, FullTextSearch, ...).
+// Every user value is bound as a query argument.
+type Expressions struct {
+	kind string
+}
+
+const (
+	kindMySQL    = "mysql"
+	kindPostgres = "postgres"
+	kindSQLite   = "sqlite"
+)
+
+// ExprFor returns the expression helpers for the given dialect.
+func ExprFor(d interfaces.Dialect) Expressions {
+	return Expressions{kind: dialectKind(d)}
+}
+
+// dialectKinds maps the dialect types to their SQL flavour
+var dialectKinds = map[reflect.Type]string{
+	reflect.TypeOf(&dialect.PostgresDialect{}): kindPostgres,
+	reflect.TypeOf(&SQLiteDialect{}):           kindSQLite,
+	reflect.TypeOf(&dialect.MySQLDialect{}):    kindMySQL,
+}
+
+// dialectKind identifies the SQL flavour behind d. Transaction-scoped ORMs wrap their
+// dialect in an unexported field, whose type is still readable through reflection;
+// other wrappers and SQLMockDialect, which has both styles, fall back to the
+// placeholder style.
+func dialectKind(d interfaces.Dialect) string {
+	if d == nil {
+		return kindMySQL
+	}
+	if kind, ok := dialectKinds[reflect.TypeOf(d)]; ok {
+		return kind
+	}
+	if td, ok := d.(*connection.TransactionDialect); ok {
+		inner := reflect.ValueOf(td).Elem().FieldByName("dialect")
+		if inner.IsValid() && !inner.IsNil() {
+			if kind, ok := dialectKinds[inner.Elem().Type()]; ok {
+				return kind
+			}
+		}
+	}
+	if d.GetPlaceholder(0) == "$1" {
+		return kindPostgres
+	}
+	return kindMySQL
+}
+
+// comparisonOperators are the operators accepted by the Where* helpers
+var comparisonOperators = map[string]bool{
+	"=": true, "!=": true, "<>": true, "<": true, "<=": true, ">": true, ">=": true,
+	"LIKE": true, "NOT LIKE": true,
+}
+
+// OrderByRandom orders rows randomly.
+func (e Expressions) OrderByRandom() Expr {
+	fn := "RAND()"
+	if e.kind != kindMySQL {
+		fn = "RANDOM()"
+	}
+	return func(q interfaces.QueryBuilder) interfaces.QueryBuilder {
+		return q.OrderBy(fn, "")
+	}
+}
+
+// WhereILike matches a pattern whatever the case: ILIKE on Postgres, whose LIKE is
+// case-sensitive, and LOWER on both sides elsewhere, whatever the column's collation.
+func (e Expressions) WhereILike(column, pattern string) Expr {
+	return func(q interfaces.QueryBuilder) interfaces.QueryBuilder {
+		if e.kind == kindPostgres {
+			return q.WhereRaw(column+" ILIKE ?", pattern)
+		}
+		return q.WhereRaw(fmt.Sprintf("LOWER(%s) LIKE LOWER(?)", column), pattern)
+	}
+}
+
+// WhereJSON compares the value found at a JSON path such as "$.plan.tier" in column.
+func (e Expressions) WhereJSON(column, path, operator string, value interface{}) Expr {
+	return func(q interfaces.QueryBuilder) interfaces.QueryBuilder {
+		if !comparisonOperators[strings.ToUpper(operator)] {
+			return withError(q, fmt.Errorf("unsupported operator %q", operator))
+		}
+		switch e.kind {
+		case kindPostgres:
+			keys := jsonPathKeys(path)
+			if len(keys) == 0 {
+				return q.WhereRaw(fmt.Sprintf("%s::jsonb #>> '{}' %s ?", column, operator), value)
+			}
+			marks := strings.TrimSuffix(strings.Repeat("?, ", len(keys)), ", ")
+			args := make([]interface{}, 0, len(keys)+1)
+			for _, key := range keys {
+				args = append(args, key)
+			}
+			args = append(args, value)
+			return q.WhereRaw(fmt.Sprintf("jsonb_extract_path_text(%s::jsonb, %s) %s ?", column, marks, operator), args...)
+		case kindSQLite:
+			return q.WhereRaw(fmt.Sprintf("json_extract(%s, ?) %s ?", column, operator), path, value)
+		default:
+			return q.WhereRaw(fmt.Sprintf("JSON_UNQUOTE(JSON_EXTRACT(%s, ?)) %s ?", column, operator), path, value)
+		}
+	}
+}
+
+// WhereFullText matches rows whose columns contain the search terms.
+func (e Expressions) WhereFullText(columns []string, search string) Expr {
+	return func(q interfaces.QueryBuilder) interfaces.QueryBuilder {
+		switch e.kind {
+		case kindPostgres:
+			return q.WhereRaw(fmt.Sprintf("%s @@ plainto_tsquery('english', ?)", tsVector(columns)), search)
+		case kindSQLite:
+			conds := make([]string, len(columns))
+			args := make([]interface{}, len(columns))
+			for i, col := range columns {
+				conds[i] = col + " LIKE ?"
+				args[i] = "%" + search + "%"
+			}
+			return q.WhereRaw("("+strings.Join(conds, " OR ")+")", args...)
+		default:
+			return q.WhereRaw(fmt.Sprintf("MATCH(%s) AGAINST(? IN BOOLEAN MODE)", strings.Join(columns, ", ")), search)
+		}
+	}
+}
+
+// WhereDate compares the calendar date of a timestamp column with the date of d.
+// Times are stored in UTC, so d is converted to UTC first; a calendar date is
+// written time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC).
+func (e Expressions) WhereDate(column, operator string, d time.Time) Expr {
+	return func(q interfaces.QueryBuilder) interfaces.QueryBuilder {
+		if !comparisonOperators[strings.ToUpper(operator)] {
+			return withError(q, fmt.Errorf("unsupported operator %q", operator))
+		}
+		expr := fmt.Sprintf("DATE(%s)", column)
+		switch e.kind {
+		case kindPostgres:
+			expr = fmt.Sprintf("CAST(%s AS DATE)", column)
+		case kindSQLite:
+			expr = fmt.Sprintf("date(%s)", column)
+		}
+		return q.WhereRaw(fmt.Sprintf("%s %s ?", expr, operator), d.UTC().Format("2006-01-02"))
+	}
+}
+
+// withError makes the query fail on execution, the way the ORM reports builder errors
+func withError(q interfaces.QueryBuilder, err error) interfaces.QueryBuilder {
+	if b, ok := q.(*query.BuilderImpl); ok && b.Err == nil {
+		b.Err = err
+	}
+	return q
+}
+
+// tsVector builds the Postgres document expression for a set of columns. It only uses
+// immutable functions so that MigrateFullTextIndexes can index the same expression.
+func tsVector(columns []string) string {
+	parts := make([]string, len(columns))
+	for i, col := range columns {
+		parts[i] = fmt.Sprintf("coalesce(%s, '')", col)
+	}
+	return fmt.Sprintf("to_tsvector('english', %s)", strings.Join(parts, " || ' ' || "))
+}
+
+// jsonPathKeys turns "$.a.b" into the key list Postgres path functions expect
+func jsonPathKeys(path string) []string {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
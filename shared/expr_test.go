@@ -0,0 +1,176 @@
+package shared
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ESGI-M2/GO/dialect"
+	ormcore "github.com/ESGI-M2/GO/orm"
+	"github.com/ESGI-M2/GO/orm/core/interfaces"
+)
+
+// exprORMs opens one ORM per SQL flavour; none of them is queried
+func exprORMs(t *testing.T) map[string]ormcore.ORM {
+	t.Helper()
+	mysql, _, err := NewSQLMockORM(&User{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	postgres, _, err := NewPostgresSQLMockORM(&User{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlite, err := NewSQLiteORM(":memory:", &User{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		mysql.Close()
+		postgres.Close()
+		sqlite.Close()
+	})
+	return map[string]ormcore.ORM{kindMySQL: mysql, kindPostgres: postgres, kindSQLite: sqlite}
+}
+
+func TestExpressionsSQL(t *testing.T) {
+	day := time.Date(2024, 5, 6, 23, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		expr func(e Expressions) Expr
+		sql  map[string]string
+		args []interface{}
+	}{
+		{
+			name: "OrderByRandom",
+			expr: func(e Expressions) Expr { return e.OrderByRandom() },
+			sql: map[string]string{
+				kindMySQL:    "SELECT * FROM users ORDER BY RAND() ASC",
+				kindPostgres: "SELECT * FROM users ORDER BY RANDOM() ASC",
+				kindSQLite:   "SELECT * FROM users ORDER BY RANDOM() ASC",
+			},
+		},
+		{
+			name: "WhereILike",
+			expr: func(e Expressions) Expr { return e.WhereILike("name", "%ann%") },
+			sql: map[string]string{
+				kindMySQL:    "SELECT * FROM users WHERE LOWER(name) LIKE LOWER(?)",
+				kindPostgres: "SELECT * FROM users WHERE name ILIKE $1",
+				kindSQLite:   "SELECT * FROM users WHERE LOWER(name) LIKE LOWER(?)",
+			},
+			args: []interface{}{"%ann%"},
+		},
+		{
+			name: "WhereJSON",
+			expr: func(e Expressions) Expr { return e.WhereJSON("profile", "$.plan.tier", "=", "pro") },
+			sql: map[string]string{
+				kindMySQL:    "SELECT * FROM users WHERE JSON_UNQUOTE(JSON_EXTRACT(profile, ?)) = ?",
+				kindPostgres: "SELECT * FROM users WHERE jsonb_extract_path_text(profile::jsonb, $1, $2) = $3",
+				kindSQLite:   "SELECT * FROM users WHERE json_extract(profile, ?) = ?",
+			},
+		},
+		{
+			name: "WhereFullText",
+			expr: func(e Expressions) Expr { return e.WhereFullText([]string{"title", "content"}, "go orm") },
+			sql: map[string]string{
+				kindMySQL:    "SELECT * FROM users WHERE MATCH(title, content) AGAINST(? IN BOOLEAN MODE)",
+				kindPostgres: "SELECT * FROM users WHERE to_tsvector('english', coalesce(title, '') || ' ' || coalesce(content, '')) @@ plainto_tsquery('english', $1)",
+				kindSQLite:   "SELECT * FROM users WHERE (title LIKE ? OR content LIKE ?)",
+			},
+		},
+		{
+			name: "WhereDate",
+			expr: func(e Expressions) Expr { return e.WhereDate("created_at", ">=", day) },
+			sql: map[string]string{
+				kindMySQL:    "SELECT * FROM users WHERE DATE(created_at) >= ?",
+				kindPostgres: "SELECT * FROM users WHERE CAST(created_at AS DATE) >= $1",
+				kindSQLite:   "SELECT * FROM users WHERE date(created_at) >= ?",
+			},
+			args: []interface{}{"2024-05-06"},
+		},
+		{
+			// the same instant, already May 7 in Paris, is compared in UTC like the stored times
+			name: "WhereDate in another location",
+			expr: func(e Expressions) Expr {
+				return e.WhereDate("created_at", ">=", day.In(time.FixedZone("CEST", 2*60*60)))
+			},
+			sql: map[string]string{
+				kindMySQL:    "SELECT * FROM users WHERE DATE(created_at) >= ?",
+				kindPostgres: "SELECT * FROM users WHERE CAST(created_at AS DATE) >= $1",
+				kindSQLite:   "SELECT * FROM users WHERE date(created_at) >= ?",
+			},
+			args: []interface{}{"2024-05-06"},
+		},
+	}
+	orms := exprORMs(t)
+	for _, tt := range tests {
+		for kind, orm := range orms {
+			t.Run(tt.name+"/"+kind, func(t *testing.T) {
+				b := Apply(orm.Query(&User{}), tt.expr(ExprFor(orm.GetDialect())))
+				if got := b.GetSQL(); got != tt.sql[kind] {
+					t.Errorf("SQL\n got %q\nwant %q", got, tt.sql[kind])
+				}
+				if tt.args != nil && !reflect.DeepEqual(b.GetArgs(), tt.args) {
+					t.Errorf("args %v, want %v", b.GetArgs(), tt.args)
+				}
+			})
+		}
+	}
+}
+
+func TestExpressionsRejectOperators(t *testing.T) {
+	e := ExprFor(nil)
+	for name, expr := range map[string]Expr{
+		"WhereJSON": e.WhereJSON("profile", "$.plan", "; DROP", 1),
+		"WhereDate": e.WhereDate("created_at", "OR 1=1 --", time.Now()),
+	} {
+		t.Run(name, func(t *testing.T) {
+			orm, err := NewSQLiteORM(":memory:", &User{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer orm.Close()
+			if _, err := Apply(orm.Query(&User{}), expr).Find(); err == nil {
+				t.Fatal("expected the operator to be rejected")
+			}
+		})
+	}
+}
+
+func TestDialectKind(t *testing.T) {
+	orms := exprORMs(t)
+	tests := []struct {
+		name string
+		d    interfaces.Dialect
+		want string
+	}{
+		{"nil", nil, kindMySQL},
+		{"library mysql", dialect.NewMySQLDialect(), kindMySQL},
+		{"library postgres", dialect.NewPostgresDialect(), kindPostgres},
+		{"sqlite", orms[kindSQLite].GetDialect(), kindSQLite},
+		{"sqlmock", orms[kindMySQL].GetDialect(), kindMySQL},
+		{"postgres sqlmock", orms[kindPostgres].GetDialect(), kindPostgres},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dialectKind(tt.d); got != tt.want {
+				t.Fatalf("dialectKind = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// a transaction wraps the dialect in connection.TransactionDialect
+	t.Run("sqlite transaction", func(t *testing.T) {
+		var got string
+		err := orms[kindSQLite].Transaction(func(tx ormcore.ORM) error {
+			got = dialectKind(tx.GetDialect())
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != kindSQLite {
+			t.Fatalf("dialectKind in a transaction = %s, want %s", got, kindSQLite)
+		}
+	})
+}
@@ -0,0 +1,384 @@
+package shared
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ESGI-M2/GO/orm/core/interfaces"
+)
+
+// Add schedules entities to be saved by Flush: inserted when their primary key is zero,
+// updated otherwise. The children in their relation fields whose foreign key is a
+// column of the child, e.g. Posts []Post `orm:"relation:one_to_many,fk:user_id"`, are
+// saved too, with the foreign key set to the parent's key once it is inserted.
+func (s *Session) Add(entities ...interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range entities {
+		s.removed = without(s.removed, e)
+		if !containsEntity(s.added, e) {
+			s.added = append(s.added, e)
+		}
+	}
+}
+
+// Remove schedules entities to be deleted by Flush, like Repository.Delete
+func (s *Session) Remove(entities ...interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range entities {
+		s.added = without(s.added, e)
+		if !containsEntity(s.removed, e) {
+			s.removed = append(s.removed, e)
+		}
+	}
+}
+
+// flushItem is an entity to save, with the parent whose key goes in its foreign key
+type flushItem struct {
+	entity     interface{}
+	parent     interface{}
+	foreignKey string
+}
+
+// Flush writes the scheduled changes in one transaction, or in the session's own
+// transaction when it was started on one: the added entities and their children, the
+// entities loaded or written through the session that changed since (see
+// Repository.Dirty), then the removed entities. Saves go parents first and deletes
+// children first, following the foreign keys between the models: relation fk keys and
+// `fk:table.column` columns. Hooks and events run as for the repository methods. If
+// anything fails, the transaction is rolled back, the inserted entities get their zero
+// key back, the updated ones their version and snapshot, and the changes stay
+// scheduled, so Flush can be called again. In a session started on a transaction, the
+// entities are only put back when that transaction was started by Transaction.
+func (s *Session) Flush() error {
+	s.mu.Lock()
+	added := append([]interface{}(nil), s.added...)
+	removed := append([]interface{}(nil), s.removed...)
+	loaded := make([]interface{}, 0, len(s.identities))
+	for _, e := range s.identities {
+		loaded = append(loaded, e)
+	}
+	s.mu.Unlock()
+
+	var items []flushItem
+	seen := map[interface{}]int{} // index in items
+	for _, e := range added {
+		if err := s.collect(e, nil, "", seen, &items); err != nil {
+			return err
+		}
+	}
+	for _, e := range loaded {
+		// an instance the session wrote without loading it has no snapshot to compare
+		// with, so it is written in full once and tracked from then on
+		if _, ok := seen[e]; !ok && !containsEntity(removed, e) {
+			seen[e] = len(items)
+			items = append(items, flushItem{entity: e})
+		}
+	}
+	if len(items) == 0 && len(removed) == 0 {
+		return nil
+	}
+
+	var models []reflect.Type
+	for _, e := range append(entitiesOf(items), removed...) {
+		if t := modelType(e); !containsType(models, t) {
+			models = append(models, t)
+		}
+	}
+	order, err := s.dependencyOrder(models)
+	if err != nil {
+		return err
+	}
+
+	first := NewRepository(s.orm, reflect.New(order[0]).Interface())
+	first.session = s
+	err = first.atomically(true, func(r *Repository) error {
+		repos := map[reflect.Type]*Repository{}
+		repo := func(t reflect.Type) *Repository {
+			if repos[t] == nil {
+				repos[t] = NewRepository(r.orm, reflect.New(t).Interface()).WithContext(r.ctx)
+				repos[t].session = s
+				repos[t].tracked = s.tracked
+				repos[t].tracking = true
+			}
+			return repos[t]
+		}
+
+		for _, t := range order {
+			for _, item := range items {
+				if modelType(item.entity) != t {
+					continue
+				}
+				repo := repo(t)
+				if repo.err != nil {
+					return repo.err
+				}
+				if item.parent != nil {
+					key, ok := s.primaryKey(item.parent)
+					if !ok {
+						return fmt.Errorf("no primary key on %T", item.parent)
+					}
+					if err := setForeignKey(item.entity, item.foreignKey, key); err != nil {
+						return err
+					}
+				}
+				isNew, err := repo.isNew(item.entity)
+				if err != nil {
+					return err
+				}
+				switch {
+				case isNew:
+					if err := repo.create(item.entity); err != nil {
+						return err
+					}
+					repo.Track(item.entity)
+				case !repo.tracked.has(item.entity) || len(repo.Dirty(item.entity)) > 0:
+					if err := repo.Update(item.entity); err != nil {
+						return err
+					}
+					repo.Track(item.entity)
+				}
+			}
+		}
+
+		for i := len(order) - 1; i >= 0; i-- {
+			for _, e := range removed {
+				if modelType(e) != order[i] {
+					continue
+				}
+				repo := repo(order[i])
+				if isNew, err := repo.isNew(e); err != nil || isNew {
+					continue
+				}
+				if err := repo.Delete(e); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.added = nil
+	s.removed = nil
+	s.mu.Unlock()
+	return nil
+}
+
+// collect adds an entity to save and, depth first, the children of its relations. A
+// child added on its own before its parent gets the parent when the parent reaches it.
+func (s *Session) collect(entity, parent interface{}, foreignKey string, seen map[interface{}]int, items *[]flushItem) error {
+	if i, ok := seen[entity]; ok {
+		if (*items)[i].parent == nil {
+			(*items)[i].parent, (*items)[i].foreignKey = parent, foreignKey
+		}
+		return nil
+	}
+	seen[entity] = len(*items)
+	*items = append(*items, flushItem{entity, parent, foreignKey})
+
+	meta, err := s.orm.GetMetadata(entity)
+	if err != nil {
+		return fmt.Errorf("failed to get metadata for %T: %w", entity, err)
+	}
+	v, _ := structValue(entity)
+	for _, name := range relationNames(meta) {
+		rel := meta.Relations[name]
+		childMeta, err := s.orm.GetMetadata(reflect.New(elemType(rel.TargetModel)).Interface())
+		if err != nil || !childRelation(meta, childMeta, rel) {
+			continue
+		}
+		for _, child := range relationEntities(v.FieldByName(name)) {
+			if err := s.collect(child, entity, rel.ForeignKey, seen, items); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// dependencyOrder sorts the models so that each comes after the models it references
+func (s *Session) dependencyOrder(models []reflect.Type) ([]reflect.Type, error) {
+	metas := map[reflect.Type]*interfaces.ModelMetadata{}
+	for _, t := range models {
+		meta, err := s.orm.GetMetadata(reflect.New(t).Interface())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get metadata for %s: %w", t, err)
+		}
+		metas[t] = meta
+	}
+
+	var order []reflect.Type
+	state := map[reflect.Type]int{} // 1 visiting, 2 done
+	var visit func(t reflect.Type, path []string) error
+	visit = func(t reflect.Type, path []string) error {
+		switch state[t] {
+		case 1:
+			return fmt.Errorf("foreign keys form a cycle: %s", strings.Join(append(path, metas[t].TableName), " -> "))
+		case 2:
+			return nil
+		}
+		state[t] = 1
+		for _, dep := range models {
+			if dep != t && references(metas[t], metas[dep]) {
+				if err := visit(dep, append(path, metas[t].TableName)); err != nil {
+					return err
+				}
+			}
+		}
+		state[t] = 2
+		order = append(order, t)
+		return nil
+	}
+	for _, t := range models {
+		if err := visit(t, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// references reports whether rows of a hold a foreign key to rows of b
+func references(a, b *interfaces.ModelMetadata) bool {
+	for i := 0; i < a.Type.NumField(); i++ {
+		tag := parseTag(a.Type.Field(i))
+		if ref := tag["fk"]; !tag.has("relation") && strings.HasPrefix(ref, b.TableName+".") {
+			return true
+		}
+	}
+	for _, rel := range a.Relations {
+		if elemType(rel.TargetModel) == b.Type && hasColumn(a, rel.ForeignKey) && !hasColumn(b, rel.ForeignKey) {
+			return true
+		}
+	}
+	for _, rel := range b.Relations {
+		if elemType(rel.TargetModel) == a.Type && hasColumn(a, rel.ForeignKey) && !hasColumn(b, rel.ForeignKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// primaryKey returns the primary key field of an entity of any model
+func (s *Session) primaryKey(entity interface{}) (reflect.Value, bool) {
+	meta, err := s.orm.GetMetadata(entity)
+	if err != nil {
+		return reflect.Value{}, false
+	}
+	v, ok := structValue(entity)
+	if !ok {
+		return reflect.Value{}, false
+	}
+	return fieldByColumn(v, meta.PrimaryKey)
+}
+
+// setForeignKey stores a parent's key in the foreign key column of a child
+func setForeignKey(child interface{}, column string, key reflect.Value) error {
+	v, _ := structValue(child)
+	field, ok := fieldByColumn(v, column)
+	if !ok {
+		return fmt.Errorf("no field of %T is stored in column %s", child, column)
+	}
+	if !key.Type().ConvertibleTo(field.Type()) {
+		return fmt.Errorf("cannot store a %s key in %T.%s", key.Type(), child, column)
+	}
+	field.Set(key.Convert(field.Type()))
+	return nil
+}
+
+// childRelation reports whether a relation holds children: its foreign key is a
+// column of the related model, not of the model declaring it
+func childRelation(meta, target *interfaces.ModelMetadata, rel *interfaces.Relation) bool {
+	return rel.ForeignKey != "" && hasColumn(target, rel.ForeignKey) && !hasColumn(meta, rel.ForeignKey)
+}
+
+// relationNames returns the relation names of a model in a stable order
+func relationNames(meta *interfaces.ModelMetadata) []string {
+	names := make([]string, 0, len(meta.Relations))
+	for name := range meta.Relations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// relationEntities returns pointers to the entities held by a relation field: the
+// elements of a slice, or the struct or pointer of a single relation
+func relationEntities(field reflect.Value) []interface{} {
+	var out []interface{}
+	switch field.Kind() {
+	case reflect.Slice:
+		for i := 0; i < field.Len(); i++ {
+			out = append(out, relationEntities(field.Index(i))...)
+		}
+	case reflect.Ptr:
+		if !field.IsNil() {
+			out = append(out, field.Interface())
+		}
+	case reflect.Struct:
+		if field.CanAddr() {
+			out = append(out, field.Addr().Interface())
+		}
+	}
+	return out
+}
+
+// elemType returns the struct type behind a relation's slice or pointer type
+func elemType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Slice || t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+func hasColumn(meta *interfaces.ModelMetadata, column string) bool {
+	for _, col := range meta.Columns {
+		if col.Name == column {
+			return true
+		}
+	}
+	return false
+}
+
+func entitiesOf(items []flushItem) []interface{} {
+	out := make([]interface{}, len(items))
+	for i, item := range items {
+		out[i] = item.entity
+	}
+	return out
+}
+
+func containsEntity(list []interface{}, e interface{}) bool {
+	for _, x := range list {
+		if x == e {
+			return true
+		}
+	}
+	return false
+}
+
+func containsType(list []reflect.Type, t reflect.Type) bool {
+	for _, x := range list {
+		if x == t {
+			return true
+		}
+	}
+	return false
+}
+
+// without returns the list minus the entity
+func without(list []interface{}, e interface{}) []interface{} {
+	out := list[:0]
+	for _, x := range list {
+		if x != e {
+			out = append(out, x)
+		}
+	}
+	return out
+}
@@ -0,0 +1,232 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	ormcore "github.com/ESGI-M2/GO/orm"
+)
+
+type flushCustomer struct {
+	ID     int          `table:"flush_customers" orm:"pk,auto"`
+	Email  string       `orm:"column:email,size:191,unique"`
+	Orders []flushOrder `orm:"relation:one_to_many,fk:customer_id"`
+}
+
+type flushOrder struct {
+	ID         int         `table:"flush_orders" orm:"pk,auto"`
+	CustomerID int         `orm:"column:customer_id,fk:flush_customers.id"`
+	Reference  string      `orm:"column:reference,size:40"`
+	Lines      []flushLine `orm:"relation:one_to_many,fk:order_id"`
+}
+
+type flushLine struct {
+	ID      int    `table:"flush_lines" orm:"pk,auto"`
+	OrderID int    `orm:"column:order_id,fk:flush_orders.id"`
+	Product string `orm:"column:product,size:80"`
+}
+
+type flushNote struct {
+	ID      int    `table:"flush_notes" orm:"pk,auto"`
+	Text    string `orm:"column:text,size:80"`
+	Version int    `orm:"column:version,version"`
+}
+
+var errLocked = errors.New("note is locked")
+
+func (n *flushNote) BeforeDelete(ctx context.Context, tx ormcore.ORM) error {
+	if n.Text == "locked" {
+		return errLocked
+	}
+	return nil
+}
+
+// flushORM opens a database with the flush models and records the writes in order
+func flushORM(t *testing.T) (ormcore.ORM, *[]string) {
+	t.Helper()
+	db, err := NewSQLiteORM(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { Close(db) })
+	if err := Migrate(db, &flushCustomer{}, &flushOrder{}, &flushLine{}, &flushNote{}); err != nil {
+		t.Fatal(err)
+	}
+	writes := &[]string{}
+	record := func(e Event) { *writes = append(*writes, fmt.Sprintf("%s %s", e.Type, e.Table)) }
+	for _, event := range []EventType{EventCreated, EventUpdated, EventDeleted} {
+		Events(db).Subscribe(event, nil, record)
+	}
+	return db, writes
+}
+
+func TestSessionFlush(t *testing.T) {
+	newGraph := func() *flushCustomer {
+		return &flushCustomer{Email: "ann@example.com", Orders: []flushOrder{
+			{Reference: "A-1", Lines: []flushLine{{Product: "pen"}, {Product: "ink"}}},
+		}}
+	}
+	tests := []struct {
+		name   string
+		seed   bool
+		act    func(t *testing.T, db ormcore.ORM, sess *Session, seeded *flushCustomer)
+		writes []string
+		check  func(t *testing.T, db ormcore.ORM, seeded *flushCustomer)
+	}{
+		{
+			name: "parents are inserted first",
+			act: func(t *testing.T, db ormcore.ORM, sess *Session, _ *flushCustomer) {
+				c := newGraph()
+				// the line is added before its customer, and still follows its order
+				sess.Add(&c.Orders[0].Lines[1], c)
+				if err := sess.Flush(); err != nil {
+					t.Fatal(err)
+				}
+				if c.Orders[0].CustomerID != c.ID || c.Orders[0].Lines[1].OrderID != c.Orders[0].ID {
+					t.Fatalf("foreign keys not set: %+v", c)
+				}
+			},
+			writes: []string{
+				"created flush_customers", "created flush_orders", "created flush_lines", "created flush_lines",
+			},
+		},
+		{
+			name: "loaded entities are written when changed",
+			seed: true,
+			act: func(t *testing.T, db ormcore.ORM, sess *Session, seeded *flushCustomer) {
+				customers, orders := sess.Repository(&flushCustomer{}), sess.Repository(&flushOrder{})
+				c, err := customers.Get(seeded.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := orders.Get(seeded.Orders[0].ID); err != nil {
+					t.Fatal(err)
+				}
+				c.(*flushCustomer).Email = "ann@example.org"
+				if err := sess.Flush(); err != nil {
+					t.Fatal(err)
+				}
+			},
+			writes: []string{"updated flush_customers"},
+			check: func(t *testing.T, db ormcore.ORM, seeded *flushCustomer) {
+				row, _ := db.Query(&flushCustomer{}).Where("id", "=", seeded.ID).FindOne()
+				if fmt.Sprint(row["email"]) != "ann@example.org" {
+					t.Fatalf("email not flushed: %v", row)
+				}
+			},
+		},
+		{
+			name: "entities written without loading are tracked",
+			act: func(t *testing.T, db ormcore.ORM, sess *Session, _ *flushCustomer) {
+				c := &flushCustomer{Email: "bob@example.com"}
+				if err := sess.Repository(&flushCustomer{}).Save(c); err != nil {
+					t.Fatal(err)
+				}
+				c.Email = "bob@example.org"
+				if err := sess.Flush(); err != nil {
+					t.Fatal(err)
+				}
+				// nothing changed since the last flush
+				if err := sess.Flush(); err != nil {
+					t.Fatal(err)
+				}
+				row, _ := db.Query(&flushCustomer{}).Where("id", "=", c.ID).FindOne()
+				if fmt.Sprint(row["email"]) != "bob@example.org" {
+					t.Fatalf("email not flushed: %v", row)
+				}
+			},
+			writes: []string{"created flush_customers", "updated flush_customers"},
+		},
+		{
+			name: "children are deleted first",
+			seed: true,
+			act: func(t *testing.T, db ormcore.ORM, sess *Session, seeded *flushCustomer) {
+				order := &seeded.Orders[0]
+				sess.Remove(order, &order.Lines[0], &order.Lines[1])
+				if err := sess.Flush(); err != nil {
+					t.Fatal(err)
+				}
+			},
+			writes: []string{"deleted flush_lines", "deleted flush_lines", "deleted flush_orders"},
+		},
+		{
+			name: "a failing flush rolls back",
+			seed: true,
+			act: func(t *testing.T, db ormcore.ORM, sess *Session, seeded *flushCustomer) {
+				eve := &flushCustomer{Email: "eve@example.com", Orders: []flushOrder{{Reference: "E-1"}}}
+				sess.Add(eve, &flushCustomer{Email: seeded.Email})
+				if err := sess.Flush(); err == nil {
+					t.Fatal("expected the duplicate email to fail the flush")
+				}
+				if eve.ID != 0 || eve.Orders[0].ID != 0 {
+					t.Fatalf("inserted keys not reset: %d, %d", eve.ID, eve.Orders[0].ID)
+				}
+			},
+			// the events of a rolled back transaction are never published
+			writes: []string{},
+			check: func(t *testing.T, db ormcore.ORM, _ *flushCustomer) {
+				if n, _ := NewRepository(db, &flushCustomer{}).Count(); n != 1 {
+					t.Fatalf("%d customers after the rollback, want 1", n)
+				}
+			},
+		},
+		{
+			name: "a failed flush can be retried",
+			act: func(t *testing.T, db ormcore.ORM, sess *Session, _ *flushCustomer) {
+				kept, locked := &flushNote{Text: "a"}, &flushNote{Text: "locked"}
+				if err := NewRepository(db, &flushNote{}).BatchCreate([]interface{}{kept, locked}); err != nil {
+					t.Fatal(err)
+				}
+				notes := sess.Repository(&flushNote{})
+				n, err := notes.Get(kept.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				note := n.(*flushNote)
+				note.Text = "b"
+				sess.Remove(locked)
+				if err := sess.Flush(); !errors.Is(err, errLocked) {
+					t.Fatalf("error %v, want %v", err, errLocked)
+				}
+				if dirty := notes.Dirty(note); note.Version != 1 || !reflect.DeepEqual(dirty, []string{"text"}) {
+					t.Fatalf("after the rollback: version %d, dirty %v", note.Version, dirty)
+				}
+
+				locked.Text = "unlocked"
+				if err := sess.Flush(); err != nil {
+					t.Fatal(err)
+				}
+				row, _ := db.Query(&flushNote{}).Where("id", "=", kept.ID).FindOne()
+				if got := fmt.Sprintf("%v:%v", row["text"], row["version"]); got != "b:2" || note.Version != 2 {
+					t.Fatalf("row %s, version %d after the retry", got, note.Version)
+				}
+			},
+			writes: []string{"created flush_notes", "created flush_notes", "updated flush_notes", "deleted flush_notes"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, writes := flushORM(t)
+			var seeded *flushCustomer
+			if tt.seed {
+				seeded = newGraph()
+				seeder := NewSession(db)
+				seeder.Add(seeded)
+				if err := seeder.Flush(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			*writes = []string{}
+			tt.act(t, db, NewSession(db), seeded)
+			if !reflect.DeepEqual(*writes, tt.writes) {
+				t.Errorf("writes %v, want %v", *writes, tt.writes)
+			}
+			if tt.check != nil {
+				tt.check(t, db, seeded)
+			}
+		})
+	}
+}
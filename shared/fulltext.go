@@ -0,0 +1,130 @@
+package shared
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	ormcore "github.com/ESGI-M2/GO/orm"
+	"github.com/ESGI-M2/GO/orm/core/interfaces"
+)
+
+// fullTextColumns returns the table of a model and its columns tagged `fulltext`
+func fullTextColumns(orm ormcore.ORM, model interface{}) (string, []string, error) {
+	meta, err := orm.GetMetadata(model)
+	if err != nil {
+		return "", nil, err
+	}
+	t := reflect.TypeOf(model)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var columns []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if col, ok := columnName(field); ok && parseTag(field).has("fulltext") {
+			columns = append(columns, col)
+		}
+	}
+	return meta.TableName, columns, nil
+}
+
+// fullTextIndexName is the name given to the full-text index of a table
+func fullTextIndexName(table string) string {
+	return "ft_" + table
+}
+
+// MigrateFullTextIndexes creates one full-text index per model over its columns tagged
+// `fulltext`: a FULLTEXT index on MySQL and a GIN index on the tsvector
+// ModelQuery.Search and WhereFullText query on Postgres. SQLite falls back to LIKE and needs no index.
+// Run it after the tables exist; existing indexes are left alone.
+func MigrateFullTextIndexes(orm ormcore.ORM, models ...interface{}) error {
+	d := orm.GetDialect()
+	kind := dialectKind(d)
+	if kind == kindSQLite {
+		return nil
+	}
+
+	for _, model := range models {
+		table, columns, err := fullTextColumns(orm, model)
+		if err != nil {
+			return err
+		}
+		if len(columns) == 0 {
+			continue
+		}
+		name := fullTextIndexName(table)
+
+		var stmt string
+		switch kind {
+		case kindPostgres:
+			stmt = fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s USING GIN (%s)", name, table, tsVector(columns))
+		default:
+			// MySQL has no CREATE INDEX IF NOT EXISTS
+			found, err := mysqlIndexExists(d, table, name)
+			if err != nil {
+				return err
+			}
+			if found {
+				continue
+			}
+			stmt = fmt.Sprintf("CREATE FULLTEXT INDEX %s ON %s (%s)", name, table, strings.Join(columns, ", "))
+		}
+		if _, err := d.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to create full-text index on %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// Search only matches the rows whose `fulltext` columns contain term. Each row gets a
+// "relevance" score and rows come back best match first, before any other OrderBy.
+// The scopes apply as to any query on the model, and conditions, limits and pagination
+// chain onto it:
+//
+//	rows, err := posts.Query().Search("demo").Where("views", ">", 10).Limit(5).Find()
+func (q *ModelQuery) Search(term string) *ModelQuery {
+	r := q.repo
+	c := *q
+	c.search = func(b interfaces.QueryBuilder) interfaces.QueryBuilder {
+		table, columns, err := fullTextColumns(r.orm, r.model)
+		if err == nil && len(columns) == 0 {
+			err = fmt.Errorf("model %T has no fulltext columns", r.model)
+		}
+		if err != nil {
+			return withError(b, err)
+		}
+
+		d := r.orm.GetDialect()
+		var score, match string
+		// Select cannot bind arguments, so the score's come first in the arguments of
+		// the match condition, which Builder adds before any other: the SELECT list
+		// precedes WHERE, and ? binds by position. Postgres reuses the match's $1.
+		var scoreArgs, matchArgs []interface{}
+		switch dialectKind(d) {
+		case kindPostgres:
+			score = fmt.Sprintf("ts_rank(%s, plainto_tsquery('english', %s))", tsVector(columns), d.GetPlaceholder(0))
+			match = fmt.Sprintf("%s @@ plainto_tsquery('english', %s)", tsVector(columns), d.GetPlaceholder(0))
+			matchArgs = []interface{}{term}
+		case kindSQLite:
+			// One point per column containing the term
+			hits := make([]string, len(columns))
+			for i, col := range columns {
+				hits[i] = fmt.Sprintf("(%s.%s LIKE ?)", table, col)
+				matchArgs = append(matchArgs, "%"+term+"%")
+			}
+			score = strings.Join(hits, " + ")
+			match = "(" + score + ") > 0"
+			scoreArgs = matchArgs
+		default:
+			against := fmt.Sprintf("MATCH(%s) AGAINST(? IN NATURAL LANGUAGE MODE)", strings.Join(columns, ", "))
+			score, match = against, against
+			scoreArgs, matchArgs = []interface{}{term}, []interface{}{term}
+		}
+		return b.Select(table+".*", score+" AS relevance").
+			WhereRaw(match, append(scoreArgs, matchArgs...)...).
+			OrderBy("relevance", "DESC")
+	}
+	return &c
+}
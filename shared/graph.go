@@ -0,0 +1,121 @@
+package shared
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// WithAssociations returns a repository whose Save also saves the children held in
+// the entity's relation fields, e.g. Posts []Post `orm:"relation:one_to_many,fk:user_id"`,
+// in one transaction with the entity:
+//
+//   - the entity is inserted or updated first, then its key is copied into each
+//     child's foreign key column
+//   - children with a zero key are inserted, the others updated, and so on down
+//     their own relations
+//   - stored children missing from the field are deleted, or soft deleted when their
+//     model has a `soft` column
+//
+// A nil slice or pointer means the relation was not loaded and leaves its rows alone;
+// an empty slice removes them all. If anything fails, the transaction is rolled back
+// and the inserted entities get their zero key back.
+//
+//	err := users.WithAssociations().Save(user)
+func (r *Repository) WithAssociations() *Repository {
+	c := *r
+	c.associations = true
+	return &c
+}
+
+// saveGraph saves an entity and its children for a WithAssociations repository
+func (r *Repository) saveGraph(entity interface{}) error {
+	plain := *r
+	plain.associations = false
+	return plain.atomically(true, func(tx *Repository) error {
+		return tx.saveTree(entity)
+	})
+}
+
+// saveTree saves an entity, then syncs the children of each of its relations
+func (r *Repository) saveTree(entity interface{}) error {
+	if err := r.Save(entity); err != nil {
+		return err
+	}
+
+	v, _ := structValue(entity)
+	for _, name := range relationNames(r.meta) {
+		rel := r.meta.Relations[name]
+		target, model, ok := r.relationTarget(name)
+		if !ok || !childRelation(r.meta, target, rel) {
+			continue
+		}
+		field := v.FieldByName(name)
+		if (field.Kind() == reflect.Slice || field.Kind() == reflect.Ptr) && field.IsNil() {
+			continue
+		}
+		if field.Kind() == reflect.Struct && field.IsZero() {
+			continue
+		}
+		children := NewRepository(r.orm, model, WithClock(r.now)).WithContext(r.ctx)
+		children.session = r.session
+		if err := r.syncChildren(entity, rel.ForeignKey, relationEntities(field), children); err != nil {
+			return fmt.Errorf("failed to save %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// syncChildren saves the given children of a parent and deletes its other stored ones
+func (r *Repository) syncChildren(parent interface{}, foreignKey string, list []interface{}, children *Repository) error {
+	if children.err != nil {
+		return children.err
+	}
+	pk, err := r.primaryKey(parent)
+	if err != nil {
+		return err
+	}
+	keep := map[string]bool{}
+	for _, child := range list {
+		if err := setForeignKey(child, foreignKey, pk); err != nil {
+			return err
+		}
+		if err := children.saveTree(child); err != nil {
+			return err
+		}
+		key, err := children.primaryKey(child)
+		if err != nil {
+			return err
+		}
+		keep[fmt.Sprint(key.Interface())] = true
+	}
+	return children.deleteOthers(foreignKey, pk.Interface(), keep)
+}
+
+// deleteOthers removes the stored children of a parent whose key is not in keep
+func (r *Repository) deleteOthers(foreignKey string, parent interface{}, keep map[string]bool) error {
+	rows, err := r.Query().Where(foreignKey, "=", parent).Find()
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if keep[fmt.Sprint(row[r.meta.PrimaryKey])] {
+			continue
+		}
+		stale := reflect.New(r.meta.Type).Interface()
+		if err := Decode(row, stale); err != nil {
+			return err
+		}
+		if err := r.remove(stale); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// remove soft deletes an entity of a model with a `soft` column and deletes it otherwise
+func (r *Repository) remove(entity interface{}) error {
+	if r.meta.SoftDeletes {
+		return r.SoftDelete(entity)
+	}
+	return r.Delete(entity)
+}
@@ -0,0 +1,134 @@
+package shared
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+type graphUser struct {
+	ID    int         `table:"graph_users" orm:"pk,auto"`
+	Name  string      `orm:"column:name"`
+	Posts []graphPost `orm:"relation:one_to_many,fk:user_id"`
+}
+
+type graphPost struct {
+	ID        int            `table:"graph_posts" orm:"pk,auto"`
+	UserID    int            `orm:"column:user_id"`
+	Title     string         `orm:"column:title,unique"`
+	DeletedAt *time.Time     `orm:"column:deleted_at,soft"`
+	Comments  []graphComment `orm:"relation:one_to_many,fk:post_id"`
+}
+
+type graphComment struct {
+	ID     int    `table:"graph_comments" orm:"pk,auto"`
+	PostID int    `orm:"column:post_id"`
+	Body   string `orm:"column:body"`
+}
+
+func TestWithAssociations(t *testing.T) {
+	tests := []struct {
+		name string
+		// act writes u, a new user with the posts "a" and "b", "a" having one comment
+		act     func(users *Repository, u *graphUser) error
+		wantErr bool
+		// the live and trashed post titles and the comments stored afterwards
+		live, trashed []string
+		comments      int64
+	}{
+		{"Save inserts the graph", func(users *Repository, u *graphUser) error {
+			return users.Save(u)
+		}, false, []string{"a", "b"}, []string{}, 1},
+		{"kept children are updated and missing ones removed", func(users *Repository, u *graphUser) error {
+			if err := users.Save(u); err != nil {
+				return err
+			}
+			u.Posts = []graphPost{u.Posts[0], {Title: "c"}}
+			u.Posts[0].Title = "a2"
+			u.Posts[0].Comments = append(u.Posts[0].Comments, graphComment{Body: "more"})
+			return users.Save(u)
+		}, false, []string{"a2", "c"}, []string{"b"}, 2},
+		{"a nil slice leaves the children alone", func(users *Repository, u *graphUser) error {
+			if err := users.Save(u); err != nil {
+				return err
+			}
+			u.Posts = nil
+			return users.Save(u)
+		}, false, []string{"a", "b"}, []string{}, 1},
+		{"an empty slice removes every child", func(users *Repository, u *graphUser) error {
+			if err := users.Save(u); err != nil {
+				return err
+			}
+			u.Posts[0].Comments = []graphComment{}
+			if err := users.Save(u); err != nil {
+				return err
+			}
+			u.Posts = []graphPost{}
+			return users.Save(u)
+		}, false, []string{}, []string{"a", "b"}, 0},
+		{"a failing child rolls the graph back", func(users *Repository, u *graphUser) error {
+			u.Posts[1].Title = "a"
+			return users.Save(u)
+		}, true, []string{}, []string{}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := NewSQLiteORM(":memory:", &graphUser{}, &graphPost{}, &graphComment{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer Close(db)
+			users := NewRepository(db, &graphUser{}).WithAssociations()
+			u := &graphUser{Name: "Ann", Posts: []graphPost{
+				{Title: "a", Comments: []graphComment{{Body: "nice"}}},
+				{Title: "b"},
+			}}
+
+			err = tt.act(users, u)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want an error: %v", err, tt.wantErr)
+			}
+			if err != nil {
+				// the entities inserted in the rolled back transaction lose their key
+				if u.ID != 0 || u.Posts[0].ID != 0 || u.Posts[0].Comments[0].ID != 0 {
+					t.Errorf("keys kept after the rollback: %+v", u)
+				}
+			} else {
+				for _, p := range u.Posts {
+					if p.ID == 0 || p.UserID != u.ID {
+						t.Errorf("post %q has id %d and user %d, want a key and user %d", p.Title, p.ID, p.UserID, u.ID)
+					}
+					for _, c := range p.Comments {
+						if c.PostID != p.ID {
+							t.Errorf("comment %q has post %d, want %d", c.Body, c.PostID, p.ID)
+						}
+					}
+				}
+			}
+
+			posts := NewRepository(db, &graphPost{})
+			titles := func(q *ModelQuery) []string {
+				rows, err := q.Find()
+				if err != nil {
+					t.Fatal(err)
+				}
+				out := []string{}
+				for _, row := range rows {
+					out = append(out, row["title"].(string))
+				}
+				sort.Strings(out)
+				return out
+			}
+			if got := titles(posts.Query()); !reflect.DeepEqual(got, tt.live) {
+				t.Errorf("live posts %v, want %v", got, tt.live)
+			}
+			if got := titles(posts.Query().OnlyTrashed()); !reflect.DeepEqual(got, tt.trashed) {
+				t.Errorf("trashed posts %v, want %v", got, tt.trashed)
+			}
+			if n, err := NewRepository(db, &graphComment{}).Count(); err != nil || n != tt.comments {
+				t.Errorf("%d comments (%v), want %d", n, err, tt.comments)
+			}
+		})
+	}
+}
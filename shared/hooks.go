@@ -0,0 +1,199 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+
+	ormcore "github.com/ESGI-M2/GO/orm"
+	"github.com/ESGI-M2/GO/orm/core/interfaces"
+)
+
+// Models take part in their own lifecycle by implementing any of the hook interfaces
+// below; Repository discovers them on each call. Hooks receive the repository context
+// and the transaction the write runs in, so rows they write through tx are committed or
+// rolled back together with the entity. Returning an error aborts the operation.
+//
+//	func (a *Account) AfterCreate(ctx context.Context, tx orm.ORM) error {
+//		return tx.Repository(&AuditEntry{}).Save(&AuditEntry{AccountID: a.ID, Action: "created"})
+//	}
+type (
+	BeforeCreateHook interface {
+		BeforeCreate(ctx context.Context, tx ormcore.ORM) error
+	}
+	AfterCreateHook interface {
+		AfterCreate(ctx context.Context, tx ormcore.ORM) error
+	}
+	BeforeSaveHook interface {
+		BeforeSave(ctx context.Context, tx ormcore.ORM) error
+	}
+	AfterSaveHook interface {
+		AfterSave(ctx context.Context, tx ormcore.ORM) error
+	}
+	BeforeUpdateHook interface {
+		BeforeUpdate(ctx context.Context, tx ormcore.ORM) error
+	}
+	AfterUpdateHook interface {
+		AfterUpdate(ctx context.Context, tx ormcore.ORM) error
+	}
+	BeforeDeleteHook interface {
+		BeforeDelete(ctx context.Context, tx ormcore.ORM) error
+	}
+	AfterDeleteHook interface {
+		AfterDelete(ctx context.Context, tx ormcore.ORM) error
+	}
+	BeforeSoftDeleteHook interface {
+		BeforeSoftDelete(ctx context.Context, tx ormcore.ORM) error
+	}
+	AfterSoftDeleteHook interface {
+		AfterSoftDelete(ctx context.Context, tx ormcore.ORM) error
+	}
+	BeforeRestoreHook interface {
+		BeforeRestore(ctx context.Context, tx ormcore.ORM) error
+	}
+	AfterRestoreHook interface {
+		AfterRestore(ctx context.Context, tx ormcore.ORM) error
+	}
+	// AfterFindHook runs after FindInto decoded a row; tx is the repository's ORM,
+	// which is only a transaction when the repository was bound to one
+	AfterFindHook interface {
+		AfterFind(ctx context.Context, tx ormcore.ORM) error
+	}
+)
+
+// modelHooks returns the hook method an entity implements for each event, or nil
+var modelHooks = map[string]func(entity interface{}) func(context.Context, ormcore.ORM) error{
+	"BeforeCreate": func(e interface{}) func(context.Context, ormcore.ORM) error {
+		if h, ok := e.(BeforeCreateHook); ok {
+			return h.BeforeCreate
+		}
+		return nil
+	},
+	"AfterCreate": func(e interface{}) func(context.Context, ormcore.ORM) error {
+		if h, ok := e.(AfterCreateHook); ok {
+			return h.AfterCreate
+		}
+		return nil
+	},
+	"BeforeSave": func(e interface{}) func(context.Context, ormcore.ORM) error {
+		if h, ok := e.(BeforeSaveHook); ok {
+			return h.BeforeSave
+		}
+		return nil
+	},
+	"AfterSave": func(e interface{}) func(context.Context, ormcore.ORM) error {
+		if h, ok := e.(AfterSaveHook); ok {
+			return h.AfterSave
+		}
+		return nil
+	},
+	"BeforeUpdate": func(e interface{}) func(context.Context, ormcore.ORM) error {
+		if h, ok := e.(BeforeUpdateHook); ok {
+			return h.BeforeUpdate
+		}
+		return nil
+	},
+	"AfterUpdate": func(e interface{}) func(context.Context, ormcore.ORM) error {
+		if h, ok := e.(AfterUpdateHook); ok {
+			return h.AfterUpdate
+		}
+		return nil
+	},
+	"BeforeDelete": func(e interface{}) func(context.Context, ormcore.ORM) error {
+		if h, ok := e.(BeforeDeleteHook); ok {
+			return h.BeforeDelete
+		}
+		return nil
+	},
+	"AfterDelete": func(e interface{}) func(context.Context, ormcore.ORM) error {
+		if h, ok := e.(AfterDeleteHook); ok {
+			return h.AfterDelete
+		}
+		return nil
+	},
+	"BeforeSoftDelete": func(e interface{}) func(context.Context, ormcore.ORM) error {
+		if h, ok := e.(BeforeSoftDeleteHook); ok {
+			return h.BeforeSoftDelete
+		}
+		return nil
+	},
+	"AfterSoftDelete": func(e interface{}) func(context.Context, ormcore.ORM) error {
+		if h, ok := e.(AfterSoftDeleteHook); ok {
+			return h.AfterSoftDelete
+		}
+		return nil
+	},
+	"BeforeRestore": func(e interface{}) func(context.Context, ormcore.ORM) error {
+		if h, ok := e.(BeforeRestoreHook); ok {
+			return h.BeforeRestore
+		}
+		return nil
+	},
+	"AfterRestore": func(e interface{}) func(context.Context, ormcore.ORM) error {
+		if h, ok := e.(AfterRestoreHook); ok {
+			return h.AfterRestore
+		}
+		return nil
+	},
+	"AfterFind": func(e interface{}) func(context.Context, ormcore.ORM) error {
+		if h, ok := e.(AfterFindHook); ok {
+			return h.AfterFind
+		}
+		return nil
+	},
+}
+
+// metadataHooks returns the functions registered in meta.Hooks for an event
+func metadataHooks(meta *interfaces.ModelMetadata, event string) []func(interface{}) error {
+	if meta == nil || meta.Hooks == nil {
+		return nil
+	}
+	switch event {
+	case "BeforeCreate":
+		return meta.Hooks.BeforeCreate
+	case "AfterCreate":
+		return meta.Hooks.AfterCreate
+	case "BeforeSave":
+		return meta.Hooks.BeforeSave
+	case "AfterSave":
+		return meta.Hooks.AfterSave
+	case "BeforeUpdate":
+		return meta.Hooks.BeforeUpdate
+	case "AfterUpdate":
+		return meta.Hooks.AfterUpdate
+	case "BeforeDelete":
+		return meta.Hooks.BeforeDelete
+	case "AfterDelete":
+		return meta.Hooks.AfterDelete
+	}
+	return nil
+}
+
+// callHook runs the metadata hooks for an event, then the entity's own hook method,
+// handing it the ORM the repository writes through
+func (r *Repository) callHook(event string, entity interface{}) error {
+	for _, hook := range metadataHooks(r.meta, event) {
+		if err := hook(entity); err != nil {
+			return fmt.Errorf("hook %s failed: %w", event, err)
+		}
+	}
+	if hook := modelHooks[event](entity); hook != nil {
+		if err := hook(r.ctx, r.orm); err != nil {
+			return fmt.Errorf("hook %s failed: %w", event, err)
+		}
+	}
+	return nil
+}
+
+// hasHooks reports whether any hook is registered for one of the events, either in the
+// metadata or as a method of the entity
+func (r *Repository) hasHooks(entity interface{}, events ...string) bool {
+	for _, event := range events {
+		if len(metadataHooks(r.meta, event)) > 0 {
+			return true
+		}
+		if modelHooks[event](entity) != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	ormcore "github.com/ESGI-M2/GO/orm"
+)
+
+type hookItem struct {
+	ID        int        `table:"hook_items" orm:"pk,auto"`
+	Name      string     `orm:"column:name"`
+	DeletedAt *time.Time `orm:"column:deleted_at,soft"`
+}
+
+type hookAudit struct {
+	ID     int `table:"hook_audits" orm:"pk,auto"`
+	ItemID int `orm:"column:item_id"`
+}
+
+var (
+	// hookCalls lists the hooks run by hookItem, in order; the one named by hookFails
+	// returns errHookFailed
+	hookCalls []string
+	hookFails string
+
+	errHookFailed = errors.New("hook failed")
+)
+
+func record(event string) error {
+	hookCalls = append(hookCalls, event)
+	if event == hookFails {
+		return errHookFailed
+	}
+	return nil
+}
+
+func (*hookItem) BeforeCreate(context.Context, ormcore.ORM) error { return record("BeforeCreate") }
+func (*hookItem) BeforeSave(context.Context, ormcore.ORM) error   { return record("BeforeSave") }
+func (*hookItem) AfterSave(context.Context, ormcore.ORM) error    { return record("AfterSave") }
+func (*hookItem) BeforeUpdate(context.Context, ormcore.ORM) error { return record("BeforeUpdate") }
+func (*hookItem) AfterUpdate(context.Context, ormcore.ORM) error  { return record("AfterUpdate") }
+func (*hookItem) BeforeDelete(context.Context, ormcore.ORM) error { return record("BeforeDelete") }
+func (*hookItem) AfterDelete(context.Context, ormcore.ORM) error  { return record("AfterDelete") }
+func (*hookItem) AfterFind(context.Context, ormcore.ORM) error    { return record("AfterFind") }
+
+func (*hookItem) BeforeSoftDelete(context.Context, ormcore.ORM) error {
+	return record("BeforeSoftDelete")
+}
+func (*hookItem) AfterSoftDelete(context.Context, ormcore.ORM) error {
+	return record("AfterSoftDelete")
+}
+func (*hookItem) BeforeRestore(context.Context, ormcore.ORM) error { return record("BeforeRestore") }
+func (*hookItem) AfterRestore(context.Context, ormcore.ORM) error  { return record("AfterRestore") }
+
+// AfterCreate writes an audit row through the transaction of the write
+func (h *hookItem) AfterCreate(ctx context.Context, tx ormcore.ORM) error {
+	if err := record("AfterCreate"); err != nil {
+		return err
+	}
+	return tx.Repository(&hookAudit{}).Save(&hookAudit{ItemID: h.ID})
+}
+
+func TestHooks(t *testing.T) {
+	tests := []struct {
+		name  string
+		fails string
+		// act runs next to a saved item, with the hooks of its save forgotten
+		act     func(items *Repository, saved *hookItem) error
+		calls   []string
+		wantErr bool
+		// the live items and audit rows afterwards
+		items, audits int64
+	}{
+		{"Save creates", "", func(items *Repository, _ *hookItem) error {
+			return items.Save(&hookItem{Name: "b"})
+		}, []string{"BeforeCreate", "BeforeSave", "AfterCreate", "AfterSave"}, false, 2, 2},
+		{"Save updates", "", func(items *Repository, saved *hookItem) error {
+			saved.Name = "b"
+			return items.Save(saved)
+		}, []string{"BeforeUpdate", "BeforeSave", "AfterUpdate", "AfterSave"}, false, 1, 1},
+		{"Delete", "", func(items *Repository, saved *hookItem) error {
+			return items.Delete(saved)
+		}, []string{"BeforeDelete", "AfterDelete"}, false, 0, 1},
+		{"SoftDelete", "", func(items *Repository, saved *hookItem) error {
+			return items.SoftDelete(saved)
+		}, []string{"BeforeSoftDelete", "AfterSoftDelete"}, false, 0, 1},
+		{"Restore", "", func(items *Repository, saved *hookItem) error {
+			if err := items.SoftDelete(saved); err != nil {
+				return err
+			}
+			hookCalls = nil
+			return items.Restore(saved)
+		}, []string{"BeforeRestore", "AfterRestore"}, false, 1, 1},
+		{"FindInto", "", func(items *Repository, saved *hookItem) error {
+			return items.FindInto(saved.ID, &hookItem{})
+		}, []string{"AfterFind"}, false, 1, 1},
+		{"a failing Before hook stops the write", "BeforeSave", func(items *Repository, _ *hookItem) error {
+			return items.Save(&hookItem{Name: "b"})
+		}, []string{"BeforeCreate", "BeforeSave"}, true, 1, 1},
+		{"a failing After hook rolls back the rows written before it", "AfterSave", func(items *Repository, _ *hookItem) error {
+			return items.Save(&hookItem{Name: "b"})
+		}, []string{"BeforeCreate", "BeforeSave", "AfterCreate", "AfterSave"}, true, 1, 1},
+		{"a failing soft delete hook keeps the row", "AfterSoftDelete", func(items *Repository, saved *hookItem) error {
+			return items.SoftDelete(saved)
+		}, []string{"BeforeSoftDelete", "AfterSoftDelete"}, true, 1, 1},
+	}
+	defer func() { hookCalls, hookFails = nil, "" }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := NewSQLiteORM(":memory:", &hookItem{}, &hookAudit{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer Close(db)
+			items := NewRepository(db, &hookItem{})
+			hookFails = ""
+			saved := &hookItem{Name: "a"}
+			if err := items.Save(saved); err != nil {
+				t.Fatal(err)
+			}
+
+			hookCalls, hookFails = nil, tt.fails
+			err = tt.act(items, saved)
+			if tt.wantErr && !errors.Is(err, errHookFailed) || !tt.wantErr && err != nil {
+				t.Fatalf("error %v, want a hook failure: %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(hookCalls, tt.calls) {
+				t.Errorf("hooks %v, want %v", hookCalls, tt.calls)
+			}
+			if n, err := items.Count(); err != nil || n != tt.items {
+				t.Errorf("%d items (%v), want %d", n, err, tt.items)
+			}
+			if n, err := NewRepository(db, &hookAudit{}).Count(); err != nil || n != tt.audits {
+				t.Errorf("%d audit rows (%v), want %d", n, err, tt.audits)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package shared
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	ormcore "github.com/ESGI-M2/GO/orm"
+)
+
+// JSON stores a Go value as a JSON document. It is marshalled on Save and unmarshalled
+// when a row is read back through Decode:
+//
+//	Settings shared.JSON[Settings] `orm:"column:settings,json"`
+type JSON[T any] struct {
+	Data T
+}
+
+// NewJSON wraps a value for storage in a JSON column
+func NewJSON[T any](data T) JSON[T] {
+	return JSON[T]{Data: data}
+}
+
+// Value marshals the wrapped value
+func (j JSON[T]) Value() (driver.Value, error) {
+	b, err := json.Marshal(j.Data)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan unmarshals a JSON document read from the database
+func (j *JSON[T]) Scan(src interface{}) error {
+	var zero T
+	j.Data = zero
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, &j.Data)
+	case string:
+		return json.Unmarshal([]byte(v), &j.Data)
+	default:
+		return fmt.Errorf("cannot scan %T into a JSON column", src)
+	}
+}
+
+// MarshalJSON renders the wrapped value directly, so Pretty shows the document itself
+func (j JSON[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(j.Data)
+}
+
+// UnmarshalJSON is the counterpart of MarshalJSON
+func (j *JSON[T]) UnmarshalJSON(b []byte) error {
+	return json.Unmarshal(b, &j.Data)
+}
+
+// MigrateJSONColumns turns the columns tagged `json` into native JSON columns: JSON on
+// MySQL and JSONB on Postgres. The ORM creates them as TEXT, which SQLite keeps since
+// its JSON functions work on text. Run it after the tables exist; it is idempotent.
+func MigrateJSONColumns(orm ormcore.ORM, models ...interface{}) error {
+	kind := dialectKind(orm.GetDialect())
+	if kind == kindSQLite {
+		return nil
+	}
+
+	for _, model := range models {
+		meta, err := orm.GetMetadata(model)
+		if err != nil {
+			return err
+		}
+		t := reflect.TypeOf(model)
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			col, ok := columnName(field)
+			if !ok || !parseTag(field).has("json") {
+				continue
+			}
+
+			stmt := fmt.Sprintf("ALTER TABLE %s MODIFY %s JSON", meta.TableName, col)
+			if kind == kindPostgres {
+				stmt = fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE JSONB USING %s::jsonb", meta.TableName, col, col)
+			}
+			if _, err := orm.GetDialect().Exec(stmt); err != nil {
+				return fmt.Errorf("failed to convert %s.%s to JSON: %w", meta.TableName, col, err)
+			}
+		}
+	}
+	return nil
+}
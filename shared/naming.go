@@ -0,0 +1,200 @@
+package shared
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	ormcore "github.com/ESGI-M2/GO/orm"
+	"github.com/ESGI-M2/GO/orm/core/connection"
+	"github.com/ESGI-M2/GO/orm/core/interfaces"
+)
+
+// NamingStrategy decides the table and column names of models that do not declare them
+type NamingStrategy interface {
+	// TableName derives the table of a struct without a TableName method or table tag
+	TableName(structName string) string
+	// ColumnName derives the column of a field without a column: tag
+	ColumnName(fieldName string) string
+	// Qualify is applied to every table name, declared or derived, e.g. a tenant prefix
+	Qualify(table string) string
+}
+
+// Tabler is implemented by models that choose their own table name
+type Tabler interface {
+	TableName() string
+}
+
+// DefaultNaming reproduces the library: lower-cased struct and field names
+type DefaultNaming struct{}
+
+func (DefaultNaming) TableName(structName string) string { return strings.ToLower(structName) }
+func (DefaultNaming) ColumnName(fieldName string) string { return strings.ToLower(fieldName) }
+func (DefaultNaming) Qualify(table string) string        { return table }
+
+// SnakeCaseNaming uses snake_case columns and pluralised snake_case tables, every
+// table being prefixed with TablePrefix (e.g. "tenant_a_")
+type SnakeCaseNaming struct {
+	TablePrefix    string
+	SingularTables bool
+}
+
+func (n SnakeCaseNaming) TableName(structName string) string {
+	name := toSnakeCase(structName)
+	if !n.SingularTables {
+		name = pluralize(name)
+	}
+	return name
+}
+
+func (n SnakeCaseNaming) ColumnName(fieldName string) string { return toSnakeCase(fieldName) }
+func (n SnakeCaseNaming) Qualify(table string) string        { return n.TablePrefix + table }
+
+// ApplyNaming registers the models and renames their tables: a TableName method wins,
+// then the table tag, then the strategy; Qualify applies to all of them. The resolved
+// name is stored in ModelMetadata.TableName, which queries, repositories and Migrate
+// read, so call it before the tables are created. Columns cannot be renamed since the
+// library matches columns to fields by name, so a field the strategy would name
+// differently must carry an explicit column: tag; ApplyNaming reports those.
+func ApplyNaming(orm ormcore.ORM, strategy NamingStrategy, models ...interface{}) error {
+	if strategy == nil {
+		strategy = DefaultNaming{}
+	}
+
+	for _, model := range models {
+		if err := orm.RegisterModel(model); err != nil {
+			return fmt.Errorf("failed to register model %T: %w", model, err)
+		}
+		meta, err := orm.GetMetadata(model)
+		if err != nil {
+			return err
+		}
+
+		t := meta.Type
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		var table string
+		if tabler, ok := model.(Tabler); ok {
+			table = tabler.TableName()
+		} else if t.NumField() > 0 && t.Field(0).Tag.Get("table") != "" {
+			table = t.Field(0).Tag.Get("table")
+		} else {
+			table = strategy.TableName(t.Name())
+		}
+		meta.TableName = strategy.Qualify(table)
+		renamed.Store(renamedKey{ormKey(orm), table}, meta.TableName)
+
+		var untagged []string
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			col, ok := columnName(field)
+			if !ok || parseTag(field)["column"] != "" {
+				continue
+			}
+			if want := strategy.ColumnName(field.Name); want != col {
+				untagged = append(untagged, fmt.Sprintf("%s (tag it column:%s)", field.Name, want))
+			}
+		}
+		if len(untagged) > 0 {
+			return fmt.Errorf("model %s: columns cannot be renamed, the library maps them to fields by name: %s",
+				t.Name(), strings.Join(untagged, ", "))
+		}
+	}
+	return nil
+}
+
+// renamedKey is a table name as declared or derived, before Qualify, on one ORM
+// (see ormKey)
+type renamedKey struct {
+	orm   interface{}
+	table string
+}
+
+// renamed maps each table ApplyNaming renamed to its resolved name
+var renamed sync.Map
+
+// tableOf resolves a table named in a tag, such as users in `fk:users.id`, to the
+// name ApplyNaming gave it; other names are returned as they are
+func tableOf(orm ormcore.ORM, table string) string {
+	if name, ok := renamed.Load(renamedKey{ormKey(orm), table}); ok {
+		return name.(string)
+	}
+	return table
+}
+
+// tablePlaceholder matches {{table User}} in raw SQL
+var tablePlaceholder = regexp.MustCompile(`\{\{\s*table\s+(\w+)\s*\}\}`)
+
+// Raw runs raw SQL after replacing each {{table Model}} with the table of the registered
+// model of that struct name, so raw queries follow TableName/table tags/ApplyNaming
+func Raw(orm ormcore.ORM, sql string, args ...interface{}) interfaces.QueryBuilder {
+	expanded, err := ExpandTables(orm, sql)
+	if err != nil {
+		return withError(orm.Raw(sql, args...), err)
+	}
+	return orm.Raw(expanded, args...)
+}
+
+// ExpandTables replaces each {{table Model}} in sql with the table name of the
+// registered model of that struct name
+func ExpandTables(orm ormcore.ORM, sql string) (string, error) {
+	impl, ok := orm.(*connection.ORMImpl)
+	if !ok {
+		return "", fmt.Errorf("cannot list the models registered on %T", orm)
+	}
+	tables := make(map[string]string, len(impl.Models))
+	for t, meta := range impl.Models {
+		tables[t.Name()] = meta.TableName
+	}
+
+	var missing []string
+	expanded := tablePlaceholder.ReplaceAllStringFunc(sql, func(m string) string {
+		name := tablePlaceholder.FindStringSubmatch(m)[1]
+		table, ok := tables[name]
+		if !ok {
+			missing = append(missing, name)
+			return m
+		}
+		return table
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("no registered model named %s", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+// toSnakeCase turns "CreatedAt" into "created_at" and "UserID" into "user_id"
+func toSnakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			prevLower := i > 0 && !unicode.IsUpper(runes[i-1])
+			nextLower := i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || nextLower {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// pluralize applies the regular English plural rules
+func pluralize(word string) string {
+	switch {
+	case strings.HasSuffix(word, "s"), strings.HasSuffix(word, "x"), strings.HasSuffix(word, "z"),
+		strings.HasSuffix(word, "ch"), strings.HasSuffix(word, "sh"):
+		return word + "es"
+	case strings.HasSuffix(word, "y") && len(word) > 1 && !strings.ContainsRune("aeiou", rune(word[len(word)-2])):
+		return word[:len(word)-1] + "ies"
+	default:
+		return word + "s"
+	}
+}
@@ -0,0 +1,38 @@
+package shared
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestForeignKeysFollowNaming(t *testing.T) {
+	tests := []struct {
+		name   string
+		naming NamingStrategy // nil when ApplyNaming is not called
+		want   string
+	}{
+		{"declared table", nil, "REFERENCES users(id)"},
+		{"prefixed table", SnakeCaseNaming{TablePrefix: "acme_"}, "REFERENCES acme_users(id)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := NewSQLiteORM(":memory:")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer Close(db)
+			if tt.naming != nil {
+				if err := ApplyNaming(db, tt.naming, &User{}, &Post{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			stmts, err := SchemaSQL(db, &Post{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(stmts[0], tt.want) {
+				t.Fatalf("%s\nhas no %s", stmts[0], tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package shared
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultPruneChunkSize is how many rows PruneTrashed deletes per transaction
+const DefaultPruneChunkSize = 500
+
+// PruneOption configures PruneTrashed
+type PruneOption func(*pruneConfig)
+
+type pruneConfig struct {
+	chunkSize int
+	progress  func(chunk, total int64)
+}
+
+// PruneChunkSize sets how many rows are deleted per transaction
+func PruneChunkSize(n int) PruneOption {
+	return func(c *pruneConfig) {
+		c.chunkSize = n
+	}
+}
+
+// PruneProgress calls fn after each chunk with its count and the running total
+func PruneProgress(fn func(chunk, total int64)) PruneOption {
+	return func(c *pruneConfig) {
+		c.progress = fn
+	}
+}
+
+// PruneTrashed permanently deletes the rows soft deleted more than olderThan ago, e.g.
+// to enforce a retention period, and returns how many were deleted. Rows go in chunks
+// of one transaction each, oldest first, through ForceDelete so the delete hooks and
+// events run; a failing chunk is rolled back and stops the pruning, the earlier chunks
+// stay deleted.
+//
+//	n, err := users.PruneTrashed(30*24*time.Hour, shared.PruneChunkSize(100))
+func (r *Repository) PruneTrashed(olderThan time.Duration, opts ...PruneOption) (int64, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if !r.meta.SoftDeletes {
+		return 0, fmt.Errorf("soft deletes not enabled for this model")
+	}
+	cfg := pruneConfig{chunkSize: DefaultPruneChunkSize}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.chunkSize <= 0 {
+		return 0, fmt.Errorf("prune chunk size must be positive, got %d", cfg.chunkSize)
+	}
+
+	cutoff := r.now().UTC().Add(-olderThan)
+	chunk := r.Query().OnlyTrashed().
+		Where(r.meta.DeletedAt, "<", cutoff).
+		OrderBy(r.meta.DeletedAt, "ASC").
+		OrderBy(r.meta.PrimaryKey, "ASC").
+		Limit(cfg.chunkSize)
+
+	var total int64
+	for {
+		n, err := chunk.ForceDelete()
+		total += n
+		if err != nil {
+			return total, fmt.Errorf("failed to prune %s: %w", r.meta.TableName, err)
+		}
+		if n > 0 && cfg.progress != nil {
+			cfg.progress(n, total)
+		}
+		if n < int64(cfg.chunkSize) {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	ormcore "github.com/ESGI-M2/GO/orm"
+)
+
+type pruneItem struct {
+	ID        int        `table:"prune_items" orm:"pk,auto"`
+	Name      string     `orm:"column:name"`
+	DeletedAt *time.Time `orm:"column:deleted_at,soft"`
+}
+
+var errPinned = errors.New("item is pinned")
+
+func (p *pruneItem) BeforeDelete(ctx context.Context, tx ormcore.ORM) error {
+	if p.Name == "pinned" {
+		return errPinned
+	}
+	return nil
+}
+
+func TestPruneTrashed(t *testing.T) {
+	day := 24 * time.Hour
+	type item struct {
+		name string
+		age  time.Duration // since its soft delete, zero for a live row
+	}
+	tests := []struct {
+		name      string
+		items     []item
+		opts      []PruneOption
+		wantErr   error
+		pruned    int64
+		remaining []string
+		progress  [][2]int64
+	}{
+		{
+			name:      "rows past the retention period",
+			items:     []item{{"old", 40 * day}, {"older", 35 * day}, {"recent", 10 * day}, {"live", 0}},
+			pruned:    2,
+			remaining: []string{"recent", "live"},
+			progress:  [][2]int64{{2, 2}},
+		},
+		{
+			name:      "chunks report their progress",
+			items:     []item{{"a", 50 * day}, {"b", 49 * day}, {"c", 48 * day}, {"d", 47 * day}, {"e", 46 * day}},
+			opts:      []PruneOption{PruneChunkSize(2)},
+			pruned:    5,
+			remaining: []string{},
+			progress:  [][2]int64{{2, 2}, {2, 4}, {1, 5}},
+		},
+		{
+			name:      "a failing hook stops at its chunk, oldest first",
+			items:     []item{{"a", 50 * day}, {"pinned", 45 * day}, {"c", 40 * day}},
+			opts:      []PruneOption{PruneChunkSize(1)},
+			wantErr:   errPinned,
+			pruned:    1,
+			remaining: []string{"pinned", "c"},
+			progress:  [][2]int64{{1, 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := NewSQLiteORM(":memory:", &pruneItem{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer Close(db)
+			now := time.Now()
+			for _, it := range tt.items {
+				e := &pruneItem{Name: it.name}
+				repo := NewRepository(db, &pruneItem{}, WithClock(func() time.Time { return now.Add(-it.age) }))
+				if err := repo.Save(e); err != nil {
+					t.Fatal(err)
+				}
+				if it.age > 0 {
+					if err := repo.SoftDelete(e); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			var progress [][2]int64
+			opts := append(tt.opts, PruneProgress(func(chunk, total int64) {
+				progress = append(progress, [2]int64{chunk, total})
+			}))
+			items := NewRepository(db, &pruneItem{}, WithClock(func() time.Time { return now }))
+			n, err := items.PruneTrashed(30*day, opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error %v, want %v", err, tt.wantErr)
+			}
+			if n != tt.pruned {
+				t.Errorf("pruned %d, want %d", n, tt.pruned)
+			}
+			if !reflect.DeepEqual(progress, tt.progress) {
+				t.Errorf("progress %v, want %v", progress, tt.progress)
+			}
+			var left []pruneItem
+			if err := items.Query().WithTrashed().OrderBy("id", "ASC").Into(&left); err != nil {
+				t.Fatal(err)
+			}
+			names := []string{}
+			for _, it := range left {
+				names = append(names, it.Name)
+			}
+			if !reflect.DeepEqual(names, tt.remaining) {
+				t.Errorf("remaining %v, want %v", names, tt.remaining)
+			}
+		})
+	}
+}
+
+func TestPruneTrashedErrors(t *testing.T) {
+	type plain struct {
+		ID int `table:"prune_plain" orm:"pk,auto"`
+	}
+	db, err := NewSQLiteORM(":memory:", &pruneItem{}, &plain{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(db)
+	tests := []struct {
+		name string
+		repo *Repository
+		opts []PruneOption
+	}{
+		{"no soft deletes", NewRepository(db, &plain{}), nil},
+		{"chunk size zero", NewRepository(db, &pruneItem{}), []PruneOption{PruneChunkSize(0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.repo.PruneTrashed(time.Hour, tt.opts...); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
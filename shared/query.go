@@ -0,0 +1,355 @@
+package shared
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ESGI-M2/GO/orm/core/interfaces"
+)
+
+// trashMode selects which rows of a soft-deleting model a query sees
+type trashMode int
+
+const (
+	withoutTrashed trashMode = iota
+	withTrashed
+	onlyTrashed
+)
+
+// ModelQuery is a query on the model of a Repository. It applies the repository's
+// global and local scopes when it runs, so WithTrashed and OnlyTrashed can be called
+// anywhere in the chain, and each method returns a new query.
+//
+//	trashed, err := repo.Query().OnlyTrashed().Where("age", ">", 30).OrderBy("id", "ASC").Find()
+//	n, err := repo.Query().OnlyTrashed().Where("email", "LIKE", "%@old.example").Restore()
+type ModelQuery struct {
+	repo      *Repository
+	mode      trashMode
+	exprs     []Expr
+	relations []relationLoad
+	cacheTTL  time.Duration
+
+	// filtered lists the relations of WhereHas and WhereDoesntHave, for the cache tags
+	filtered   []string
+	aggregates []relationAggregate
+	// search selects the relevance score and adds its condition first (see Search)
+	search Expr
+}
+
+type relationLoad struct {
+	name  string
+	exprs []Expr
+}
+
+// Query starts a query on the model
+func (r *Repository) Query() *ModelQuery {
+	return &ModelQuery{repo: r}
+}
+
+func (q *ModelQuery) with(expr Expr) *ModelQuery {
+	c := *q
+	c.exprs = append(append([]Expr(nil), q.exprs...), expr)
+	return &c
+}
+
+// WithTrashed includes soft-deleted rows, in the model and in eager-loaded relations
+func (q *ModelQuery) WithTrashed() *ModelQuery {
+	c := *q
+	c.mode = withTrashed
+	return &c
+}
+
+// OnlyTrashed only matches soft-deleted rows; eager-loaded relations include their
+// soft-deleted rows too
+func (q *ModelQuery) OnlyTrashed() *ModelQuery {
+	c := *q
+	c.mode = onlyTrashed
+	return &c
+}
+
+// Scope applies expressions, e.g. the parameterized scopes given to
+// Repository.WithScopes
+func (q *ModelQuery) Scope(exprs ...Expr) *ModelQuery {
+	c := *q
+	c.exprs = append(append([]Expr(nil), q.exprs...), exprs...)
+	return &c
+}
+
+// numbered adds a condition bound to args. The library numbers the $n of plain Where
+// conditions apart from the other arguments, and those of WhereIn from $1, so on
+// Postgres the condition goes through WhereRaw, which numbers its placeholders after
+// the arguments already bound.
+func (q *ModelQuery) numbered(plain Expr, condition string, args ...interface{}) *ModelQuery {
+	r := q.repo
+	return q.with(func(b interfaces.QueryBuilder) interfaces.QueryBuilder {
+		if dialectKind(r.orm.GetDialect()) == kindPostgres {
+			return b.WhereRaw(condition, args...)
+		}
+		return plain(b)
+	})
+}
+
+func (q *ModelQuery) Where(field, operator string, value interface{}) *ModelQuery {
+	plain := func(b interfaces.QueryBuilder) interfaces.QueryBuilder {
+		return b.Where(field, operator, value)
+	}
+	if value == nil {
+		return q.with(plain)
+	}
+	return q.numbered(plain, fmt.Sprintf("%s %s ?", field, operator), value)
+}
+
+func (q *ModelQuery) WhereIn(field string, values []interface{}) *ModelQuery {
+	plain := func(b interfaces.QueryBuilder) interfaces.QueryBuilder {
+		return b.WhereIn(field, values)
+	}
+	if len(values) == 0 {
+		return q.with(plain)
+	}
+	marks := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+	return q.numbered(plain, fmt.Sprintf("%s IN (%s)", field, marks), values...)
+}
+
+func (q *ModelQuery) WhereNull(field string) *ModelQuery {
+	return q.with(func(b interfaces.QueryBuilder) interfaces.QueryBuilder {
+		return b.WhereNull(field)
+	})
+}
+
+func (q *ModelQuery) WhereNotNull(field string) *ModelQuery {
+	return q.with(func(b interfaces.QueryBuilder) interfaces.QueryBuilder {
+		return b.WhereNotNull(field)
+	})
+}
+
+func (q *ModelQuery) WhereLike(field, pattern string) *ModelQuery {
+	return q.numbered(func(b interfaces.QueryBuilder) interfaces.QueryBuilder {
+		return b.WhereLike(field, pattern)
+	}, field+" LIKE ?", pattern)
+}
+
+// WhereILike matches a pattern whatever the case, through ILIKE on Postgres
+func (q *ModelQuery) WhereILike(field, pattern string) *ModelQuery {
+	r := q.repo
+	return q.with(func(b interfaces.QueryBuilder) interfaces.QueryBuilder {
+		return ExprFor(r.orm.GetDialect()).WhereILike(field, pattern)(b)
+	})
+}
+
+func (q *ModelQuery) WhereBetween(field string, min, max interface{}) *ModelQuery {
+	return q.with(func(b interfaces.QueryBuilder) interfaces.QueryBuilder {
+		return b.WhereBetween(field, min, max)
+	})
+}
+
+func (q *ModelQuery) WhereRaw(condition string, args ...interface{}) *ModelQuery {
+	return q.with(func(b interfaces.QueryBuilder) interfaces.QueryBuilder {
+		return b.WhereRaw(condition, args...)
+	})
+}
+
+func (q *ModelQuery) OrderBy(field, direction string) *ModelQuery {
+	return q.with(func(b interfaces.QueryBuilder) interfaces.QueryBuilder {
+		return b.OrderBy(field, direction)
+	})
+}
+
+func (q *ModelQuery) Limit(limit int) *ModelQuery {
+	return q.with(func(b interfaces.QueryBuilder) interfaces.QueryBuilder {
+		return b.Limit(limit)
+	})
+}
+
+func (q *ModelQuery) Offset(offset int) *ModelQuery {
+	return q.with(func(b interfaces.QueryBuilder) interfaces.QueryBuilder {
+		return b.Offset(offset)
+	})
+}
+
+func (q *ModelQuery) OffsetPaginate(page, perPage int) *ModelQuery {
+	return q.with(func(b interfaces.QueryBuilder) interfaces.QueryBuilder {
+		return b.OffsetPaginate(page, perPage)
+	})
+}
+
+// With eager loads a relation, applying the related model's global scopes (soft
+// deletes included, unless the query is WithTrashed or OnlyTrashed) and the given
+// expressions
+func (q *ModelQuery) With(relation string, exprs ...Expr) *ModelQuery {
+	c := *q
+	c.relations = append(append([]relationLoad(nil), q.relations...), relationLoad{relation, exprs})
+	return &c
+}
+
+// Builder returns the library query builder with every scope, condition and relation
+// applied, e.g. to call a method ModelQuery does not wrap
+func (q *ModelQuery) Builder() interfaces.QueryBuilder {
+	r := q.repo
+	b := r.orm.Query(r.model)
+	if r.err != nil {
+		return withError(b, r.err)
+	}
+	if q.search != nil {
+		b = q.search(b)
+	}
+	b = r.applyGlobalScopes(b, r.meta, q.mode)
+	b = Apply(b, r.scopes...)
+	for _, rel := range q.relations {
+		b = b.With(rel.name, q.relationScope(rel))
+	}
+	return Apply(b, q.exprs...)
+}
+
+// relationScope builds the callback the library runs on an eager-loaded relation
+func (q *ModelQuery) relationScope(rel relationLoad) func(interfaces.QueryBuilder) interfaces.QueryBuilder {
+	mode := withoutTrashed
+	if q.mode != withoutTrashed {
+		mode = withTrashed
+	}
+	r := q.repo
+	return func(b interfaces.QueryBuilder) interfaces.QueryBuilder {
+		if meta, _, ok := r.relationTarget(rel.name); ok {
+			b = r.applyGlobalScopes(b, meta, mode)
+		}
+		return Apply(b, rel.exprs...)
+	}
+}
+
+// relationTarget returns the metadata and a new instance of the model a relation
+// points to
+func (r *Repository) relationTarget(name string) (*interfaces.ModelMetadata, interface{}, bool) {
+	relation, ok := r.meta.Relations[name]
+	if !ok {
+		return nil, nil, false
+	}
+	target := relation.TargetModel
+	if target.Kind() == reflect.Slice {
+		target = target.Elem()
+	}
+	model := reflect.New(target).Interface()
+	meta, err := r.orm.GetMetadata(model)
+	if err != nil {
+		return nil, nil, false
+	}
+	return meta, model, true
+}
+
+// applyGlobalScopes applies the global scopes of a model that the repository did not
+// turn off, with the soft-delete scope replaced according to the trash mode
+func (r *Repository) applyGlobalScopes(b interfaces.QueryBuilder, meta *interfaces.ModelMetadata, mode trashMode) interfaces.QueryBuilder {
+	for _, s := range scopesOf(r.orm, meta) {
+		if contains(r.without, s.name) || (s.name == SoftDeleteScope && mode != withoutTrashed) {
+			continue
+		}
+		b = s.scope(r.ctx)(b)
+	}
+	if meta.SoftDeletes && mode == onlyTrashed {
+		b = b.WhereNotNull(meta.DeletedAt)
+	}
+	return b
+}
+
+// Find returns the matching rows
+func (q *ModelQuery) Find() ([]map[string]interface{}, error) {
+	return cached(q, "find", func() ([]map[string]interface{}, error) {
+		rows, err := q.Builder().Find()
+		if err != nil {
+			return nil, err
+		}
+		return q.addAggregates(rows)
+	})
+}
+
+// FindOne returns the first matching row, or nil
+func (q *ModelQuery) FindOne() (map[string]interface{}, error) {
+	return cached(q, "find_one", func() (map[string]interface{}, error) {
+		row, err := q.Builder().FindOne()
+		if err != nil || row == nil {
+			return row, err
+		}
+		rows, err := q.addAggregates([]map[string]interface{}{row})
+		if err != nil {
+			return nil, err
+		}
+		return rows[0], nil
+	})
+}
+
+// Into decodes the matching rows into a pointer to a slice of models
+func (q *ModelQuery) Into(dst interface{}) error {
+	rows, err := q.Find()
+	if err != nil {
+		return err
+	}
+	return DecodeAll(rows, dst)
+}
+
+// Count counts the matching rows
+func (q *ModelQuery) Count() (int64, error) {
+	return cached(q, "count", func() (int64, error) {
+		return q.Builder().Count()
+	})
+}
+
+// Exists reports whether any row matches
+func (q *ModelQuery) Exists() (bool, error) {
+	return cached(q, "exists", func() (bool, error) {
+		return q.Builder().Exists()
+	})
+}
+
+// Paginate returns one page of the matching rows with the totals
+func (q *ModelQuery) Paginate(page, perPage int) (*interfaces.PaginationResult, error) {
+	b, ok := q.Builder().(interface {
+		Paginate(page, perPage int) (*interfaces.PaginationResult, error)
+	})
+	if !ok {
+		return nil, fmt.Errorf("query builder does not support pagination")
+	}
+	return b.Paginate(page, perPage)
+}
+
+// Restore restores the matching soft-deleted rows one by one in a transaction, running
+// their hooks, and returns how many were restored
+func (q *ModelQuery) Restore() (int64, error) {
+	return q.OnlyTrashed().each((*Repository).Restore)
+}
+
+// ForceDelete permanently deletes the matching rows one by one in a transaction,
+// running their delete hooks, and returns how many were deleted
+func (q *ModelQuery) ForceDelete() (int64, error) {
+	return q.each((*Repository).ForceDelete)
+}
+
+// each loads the matching rows as models and applies op to them in one transaction
+func (q *ModelQuery) each(op func(*Repository, interface{}) error) (int64, error) {
+	if q.repo.err != nil {
+		return 0, q.repo.err
+	}
+	var n int64
+	err := q.repo.atomically(true, func(r *Repository) error {
+		bound := *q
+		bound.repo = r
+		rows, err := bound.Find()
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			entity := reflect.New(r.meta.Type).Interface()
+			if err := Decode(row, entity); err != nil {
+				return err
+			}
+			if err := op(r, entity); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,275 @@
+package shared
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+
+	"github.com/ESGI-M2/GO/orm/core/interfaces"
+)
+
+func TestModelQueryPlaceholders(t *testing.T) {
+	titleLike := func(b interfaces.QueryBuilder) interfaces.QueryBuilder {
+		return b.Where("title", "LIKE", "%Go%")
+	}
+	tests := []struct {
+		name     string
+		postgres bool
+		// model is the repository's, User unless set
+		model interface{}
+		query func(users *Repository) *ModelQuery
+		sql   string
+		args  []driver.Value
+	}{
+		{
+			name:     "ILIKE on postgres",
+			postgres: true,
+			query:    func(users *Repository) *ModelQuery { return users.Query().WhereILike("name", "%ann%") },
+			sql:      "SELECT * FROM users WHERE deleted_at IS NULL AND name ILIKE $1",
+			args:     []driver.Value{"%ann%"},
+		},
+		{
+			name:     "LOWER on mysql",
+			postgres: false,
+			query:    func(users *Repository) *ModelQuery { return users.Query().WhereILike("name", "%ann%") },
+			sql:      "SELECT * FROM users WHERE deleted_at IS NULL AND LOWER(name) LIKE LOWER(?)",
+			args:     []driver.Value{"%ann%"},
+		},
+		{
+			name:     "Where then WhereIn",
+			postgres: true,
+			query: func(users *Repository) *ModelQuery {
+				return users.Query().Where("age", ">", 30).WhereIn("name", []interface{}{"Ann", "Bob"})
+			},
+			sql:  "SELECT * FROM users WHERE deleted_at IS NULL AND age > $1 AND name IN ($2, $3)",
+			args: []driver.Value{int64(30), "Ann", "Bob"},
+		},
+		{
+			name:     "WhereIn then Where and WhereLike",
+			postgres: true,
+			query: func(users *Repository) *ModelQuery {
+				return users.Query().WhereIn("name", []interface{}{"Ann", "Bob"}).Where("age", ">", 30).WhereLike("email", "%@x")
+			},
+			sql:  "SELECT * FROM users WHERE deleted_at IS NULL AND name IN ($1, $2) AND age > $3 AND email LIKE $4",
+			args: []driver.Value{"Ann", "Bob", int64(30), "%@x"},
+		},
+		{
+			name:     "mysql keeps the library's conditions",
+			postgres: false,
+			query: func(users *Repository) *ModelQuery {
+				return users.Query().Where("age", ">", 30).WhereIn("name", []interface{}{"Ann", "Bob"})
+			},
+			sql:  "SELECT * FROM users WHERE deleted_at IS NULL AND age > ? AND name IN (?, ?)",
+			args: []driver.Value{int64(30), "Ann", "Bob"},
+		},
+		{
+			name:     "WhereHas renumbers its subquery",
+			postgres: true,
+			query: func(users *Repository) *ModelQuery {
+				return users.Query().Where("name", "<>", "Ann").WhereHas("Posts", titleLike).WhereIn("age", []interface{}{20, 30})
+			},
+			sql: "SELECT * FROM users WHERE deleted_at IS NULL AND name <> $1 AND " +
+				"EXISTS (SELECT 1 FROM post WHERE deleted_at IS NULL AND title LIKE $2 AND post.user_id = users.id) " +
+				"AND age IN ($3, $4)",
+			args: []driver.Value{"Ann", "%Go%", int64(20), int64(30)},
+		},
+		{
+			name:     "WhereHas after a raw condition",
+			postgres: true,
+			query: func(users *Repository) *ModelQuery {
+				return users.Query().WhereRaw("age BETWEEN ? AND ?", 20, 30).WhereDoesntHave("Posts", titleLike)
+			},
+			sql: "SELECT * FROM users WHERE deleted_at IS NULL AND age BETWEEN $1 AND $2 AND " +
+				"NOT EXISTS (SELECT 1 FROM post WHERE deleted_at IS NULL AND title LIKE $3 AND post.user_id = users.id)",
+			args: []driver.Value{int64(20), int64(30), "%Go%"},
+		},
+		{
+			name:     "Search then Where on postgres",
+			postgres: true,
+			model:    &Post{},
+			query: func(posts *Repository) *ModelQuery {
+				return posts.Query().Search("demo").Where("title", "<>", "Go ORM demo")
+			},
+			sql: "SELECT post.*, ts_rank(" + tsVector([]string{"title", "content"}) + ", plainto_tsquery('english', $1)) AS relevance " +
+				"FROM post WHERE " + tsVector([]string{"title", "content"}) + " @@ plainto_tsquery('english', $1) " +
+				"AND deleted_at IS NULL AND title <> $2 ORDER BY relevance DESC",
+			args: []driver.Value{"demo", "Go ORM demo"},
+		},
+		{
+			name:     "Where then Search on mysql",
+			postgres: false,
+			model:    &Post{},
+			query: func(posts *Repository) *ModelQuery {
+				return posts.Query().Where("user_id", "=", 1).Search("demo")
+			},
+			sql: "SELECT post.*, MATCH(title, content) AGAINST(? IN NATURAL LANGUAGE MODE) AS relevance " +
+				"FROM post WHERE MATCH(title, content) AGAINST(? IN NATURAL LANGUAGE MODE) " +
+				"AND deleted_at IS NULL AND user_id = ? ORDER BY relevance DESC",
+			args: []driver.Value{"demo", "demo", int64(1)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newORM := NewSQLMockORM
+			if tt.postgres {
+				newORM = NewPostgresSQLMockORM
+			}
+			orm, d, err := newORM(&User{}, &Post{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer orm.Close()
+			d.Mock.ExpectQuery("^" + regexp.QuoteMeta(tt.sql) + "$").
+				WithArgs(tt.args...).
+				WillReturnRows(d.Mock.NewRows([]string{"id"}))
+
+			model := tt.model
+			if model == nil {
+				model = &User{}
+			}
+			if _, err := tt.query(NewRepository(orm, model)).Find(); err != nil {
+				t.Fatalf("Find: %v", err)
+			}
+			if err := d.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// trashedUsers saves Ann, Bob and Cy, each with a live and a trashed post when posts
+// is set, and trashes Bob and Cy
+func trashedUsers(t *testing.T, posts bool) *Repository {
+	t.Helper()
+	db, err := NewSQLiteORM(":memory:", &User{}, &Post{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { Close(db) })
+	users := NewRepository(db, &User{})
+	for _, name := range []string{"Ann", "Bob", "Cy"} {
+		u := &User{Name: name, Email: name + "@example.com"}
+		if err := users.Save(u); err != nil {
+			t.Fatal(err)
+		}
+		if posts {
+			live, trashed := &Post{Title: "live", UserID: u.ID}, &Post{Title: "trashed", UserID: u.ID}
+			for _, err := range []error{
+				NewRepository(db, &Post{}).Save(live),
+				NewRepository(db, &Post{}).Save(trashed),
+				NewRepository(db, &Post{}).SoftDelete(trashed),
+			} {
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if name != "Ann" {
+			if err := users.SoftDelete(u); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return users
+}
+
+// names lists the name of each row in order, or the error
+func names(rows []map[string]interface{}, err error) []string {
+	if err != nil {
+		return []string{err.Error()}
+	}
+	out := []string{}
+	for _, row := range rows {
+		out = append(out, row["name"].(string))
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestTrashedModes(t *testing.T) {
+	tests := []struct {
+		name  string
+		query func(users *Repository) *ModelQuery
+		want  []string
+		// posts is the number of Posts eager loaded on each row, when they are
+		posts int
+	}{
+		{"live rows by default", func(u *Repository) *ModelQuery { return u.Query() }, []string{"Ann"}, 0},
+		{"WithTrashed", func(u *Repository) *ModelQuery { return u.Query().WithTrashed() }, []string{"Ann", "Bob", "Cy"}, 0},
+		{"OnlyTrashed", func(u *Repository) *ModelQuery { return u.Query().OnlyTrashed() }, []string{"Bob", "Cy"}, 0},
+		{"OnlyTrashed with Where", func(u *Repository) *ModelQuery {
+			return u.Query().Where("name", "<>", "Bob").OnlyTrashed()
+		}, []string{"Cy"}, 0},
+		{"OnlyTrashed with OrderBy and Limit", func(u *Repository) *ModelQuery {
+			return u.Query().OnlyTrashed().OrderBy("name", "DESC").Limit(1)
+		}, []string{"Cy"}, 0},
+		{"eager loaded relations hide their trashed rows", func(u *Repository) *ModelQuery {
+			return u.Query().With("Posts")
+		}, []string{"Ann"}, 1},
+		{"WithTrashed reaches the relations", func(u *Repository) *ModelQuery {
+			return u.Query().WithTrashed().With("Posts")
+		}, []string{"Ann", "Bob", "Cy"}, 2},
+		{"OnlyTrashed loads every related row", func(u *Repository) *ModelQuery {
+			return u.Query().OnlyTrashed().With("Posts")
+		}, []string{"Bob", "Cy"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := tt.query(trashedUsers(t, true)).Find()
+			if got := names(rows, err); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("rows %v, want %v", got, tt.want)
+			}
+			if tt.posts == 0 {
+				return
+			}
+			for _, row := range rows {
+				if n := reflect.ValueOf(row["Posts"]).Len(); n != tt.posts {
+					t.Errorf("%s has %d posts loaded, want %d", row["name"], n, tt.posts)
+				}
+			}
+		})
+	}
+}
+
+func TestTrashedBulk(t *testing.T) {
+	tests := []struct {
+		name string
+		act  func(users *Repository) (int64, error)
+		n    int64
+		// the live rows and every row afterwards
+		live, all []string
+	}{
+		{"Restore restores the matching trashed rows", func(u *Repository) (int64, error) {
+			return u.Query().Where("name", "=", "Bob").Restore()
+		}, 1, []string{"Ann", "Bob"}, []string{"Ann", "Bob", "Cy"}},
+		{"Restore skips the live rows", func(u *Repository) (int64, error) {
+			return u.Query().Where("name", "=", "Ann").Restore()
+		}, 0, []string{"Ann"}, []string{"Ann", "Bob", "Cy"}},
+		{"ForceDelete on OnlyTrashed", func(u *Repository) (int64, error) {
+			return u.Query().OnlyTrashed().ForceDelete()
+		}, 2, []string{"Ann"}, []string{"Ann"}},
+		{"ForceDelete on the live rows", func(u *Repository) (int64, error) {
+			return u.Query().ForceDelete()
+		}, 1, []string{}, []string{"Bob", "Cy"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := trashedUsers(t, false)
+			n, err := tt.act(users)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != tt.n {
+				t.Errorf("%d rows, want %d", n, tt.n)
+			}
+			if got := names(users.Query().Find()); !reflect.DeepEqual(got, tt.live) {
+				t.Errorf("live rows %v, want %v", got, tt.live)
+			}
+			if got := names(users.Query().WithTrashed().Find()); !reflect.DeepEqual(got, tt.all) {
+				t.Errorf("rows %v, want %v", got, tt.all)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package shared
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ESGI-M2/GO/orm/core/interfaces"
+	"github.com/ESGI-M2/GO/orm/core/query"
+)
+
+// relationAggregate is a WithCount, WithSum or WithMax of a ModelQuery
+type relationAggregate struct {
+	fn       string
+	relation string
+	column   string
+	exprs    []Expr
+	// as replaces the relation name in the result column
+	as string
+}
+
+// alias names the result column after the relation, as posts_count or posts_sum_views
+func (a relationAggregate) alias() string {
+	name := strings.ToLower(a.relation)
+	if a.as != "" {
+		name = a.as
+	}
+	if a.column == "" {
+		return name + "_" + strings.ToLower(a.fn)
+	}
+	return fmt.Sprintf("%s_%s_%s", name, strings.ToLower(a.fn), strings.ReplaceAll(a.column, ".", "_"))
+}
+
+// WhereHas keeps the rows with at least one related row matching the expressions,
+// through an EXISTS subquery. The related model's global scopes apply, soft deletes
+// included, whatever the trash mode of the query.
+//
+//	q := users.Query().WhereHas("Posts", func(b interfaces.QueryBuilder) interfaces.QueryBuilder {
+//		return b.Where("title", "LIKE", "%Go%")
+//	})
+func (q *ModelQuery) WhereHas(relation string, exprs ...Expr) *ModelQuery {
+	return q.whereHas("EXISTS", relation, exprs)
+}
+
+// WhereDoesntHave keeps the rows without any related row matching the expressions
+//
+//	q := users.Query().WhereDoesntHave("Posts")
+func (q *ModelQuery) WhereDoesntHave(relation string, exprs ...Expr) *ModelQuery {
+	return q.whereHas("NOT EXISTS", relation, exprs)
+}
+
+func (q *ModelQuery) whereHas(op, relation string, exprs []Expr) *ModelQuery {
+	r := q.repo
+	c := q.with(func(b interfaces.QueryBuilder) interfaces.QueryBuilder {
+		sub, rel, meta, err := r.relatedQuery(relation, exprs)
+		if err != nil {
+			return withError(b, err)
+		}
+		cond, err := r.correlation(relation, rel, meta)
+		if err != nil {
+			return withError(b, err)
+		}
+		sql, args, err := subquery(r.orm.GetDialect(), sub.WhereRaw(cond).Select("1"))
+		if err != nil {
+			return withError(b, err)
+		}
+		return b.WhereRaw(fmt.Sprintf("%s (%s)", op, sql), args...)
+	})
+	c.filtered = append(append([]string(nil), q.filtered...), relation)
+	return c
+}
+
+// WithCount adds the number of related rows matching the expressions to each row
+// returned by Find, FindOne and Into, as <relation>_count. "Posts as drafts" names it
+// drafts_count instead, to count the same relation twice.
+//
+//	rows, err := users.Query().WithCount("Posts").Find() // rows[0]["posts_count"]
+func (q *ModelQuery) WithCount(relation string, exprs ...Expr) *ModelQuery {
+	return q.withAggregate(relationAggregate{fn: "COUNT", relation: relation, exprs: exprs})
+}
+
+// WithSum adds the sum of a column of the related rows to each row, as
+// <relation>_sum_<column>, or nil when there are none. The relation takes an "as"
+// name as for WithCount.
+func (q *ModelQuery) WithSum(relation, column string, exprs ...Expr) *ModelQuery {
+	return q.withAggregate(relationAggregate{fn: "SUM", relation: relation, column: column, exprs: exprs})
+}
+
+// WithMax adds the largest value of a column of the related rows to each row, as
+// <relation>_max_<column>, or nil when there are none
+func (q *ModelQuery) WithMax(relation, column string, exprs ...Expr) *ModelQuery {
+	return q.withAggregate(relationAggregate{fn: "MAX", relation: relation, column: column, exprs: exprs})
+}
+
+func (q *ModelQuery) withAggregate(agg relationAggregate) *ModelQuery {
+	if name, as, ok := strings.Cut(agg.relation, " as "); ok {
+		agg.relation, agg.as = strings.TrimSpace(name), strings.TrimSpace(as)
+	}
+	c := *q
+	c.aggregates = append(append([]relationAggregate(nil), q.aggregates...), agg)
+	return &c
+}
+
+// addAggregates runs one grouped query per aggregate on the keys of the rows, the way
+// the library eager loads relations, and stores the results in the rows
+func (q *ModelQuery) addAggregates(rows []map[string]interface{}) ([]map[string]interface{}, error) {
+	if len(q.aggregates) == 0 || len(rows) == 0 {
+		return rows, nil
+	}
+	r := q.repo
+	keys := make([]interface{}, 0, len(rows))
+	for _, row := range rows {
+		if key, ok := row[r.meta.PrimaryKey]; ok {
+			keys = append(keys, key)
+		}
+	}
+	for _, agg := range q.aggregates {
+		b, err := r.aggregateQuery(agg, keys)
+		if err != nil {
+			return nil, err
+		}
+		found, err := b.Find()
+		if err != nil {
+			return nil, fmt.Errorf("failed to aggregate %s: %w", agg.relation, err)
+		}
+		values := make(map[string]interface{}, len(found))
+		for _, row := range found {
+			values[keyText(row["owner_key"])] = row["aggregate"]
+		}
+		for _, row := range rows {
+			value, ok := values[keyText(row[r.meta.PrimaryKey])]
+			if !ok && agg.fn == "COUNT" {
+				value = int64(0)
+			}
+			row[agg.alias()] = value
+		}
+	}
+	return rows, nil
+}
+
+// aggregateQuery builds the grouped query of an aggregate: one row per owner key
+func (r *Repository) aggregateQuery(agg relationAggregate, keys []interface{}) (interfaces.QueryBuilder, error) {
+	b, rel, meta, err := r.relatedQuery(agg.relation, agg.exprs)
+	if err != nil {
+		return nil, err
+	}
+	var owner string
+	switch {
+	case rel.Type == interfaces.ManyToMany:
+		if err := checkJoinTable(agg.relation, rel); err != nil {
+			return nil, err
+		}
+		b = b.Join(rel.JoinTable, fmt.Sprintf("%s.%s = %s.%s", rel.JoinTable, rel.ReferencedKey, meta.TableName, meta.PrimaryKey))
+		owner = rel.JoinTable + "." + rel.ForeignKey
+	case childRelation(r.meta, meta, rel):
+		owner = meta.TableName + "." + rel.ForeignKey
+	default:
+		return nil, fmt.Errorf("relation %s holds no children to aggregate", agg.relation)
+	}
+	target := "*"
+	if agg.column != "" {
+		target = agg.column
+		if !strings.Contains(target, ".") {
+			target = meta.TableName + "." + target
+		}
+	}
+	return b.WhereIn(owner, keys).
+		Select(owner+" AS owner_key", fmt.Sprintf("%s(%s) AS aggregate", agg.fn, target)).
+		GroupBy(owner), nil
+}
+
+// relatedQuery starts a query on the model of a relation, with its global scopes, soft
+// deletes included, and the given expressions
+func (r *Repository) relatedQuery(name string, exprs []Expr) (interfaces.QueryBuilder, *interfaces.Relation, *interfaces.ModelMetadata, error) {
+	if r.err != nil {
+		return nil, nil, nil, r.err
+	}
+	rel, ok := r.meta.Relations[name]
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("%s has no relation %s", r.meta.TableName, name)
+	}
+	meta, model, ok := r.relationTarget(name)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("relation %s points to an unknown model", name)
+	}
+	if meta.TableName == r.meta.TableName {
+		// the subquery could not tell its rows from the outer query's without aliases
+		return nil, nil, nil, fmt.Errorf("relation %s points back to %s", name, meta.TableName)
+	}
+	b := r.applyGlobalScopes(r.orm.Query(model), meta, withoutTrashed)
+	return Apply(b, exprs...), rel, meta, nil
+}
+
+// correlation renders the condition tying the rows of a relation's model to the row
+// of the outer query
+func (r *Repository) correlation(name string, rel *interfaces.Relation, meta *interfaces.ModelMetadata) (string, error) {
+	outer, inner := r.meta.TableName, meta.TableName
+	switch {
+	case rel.Type == interfaces.ManyToMany:
+		if err := checkJoinTable(name, rel); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s.%s IN (SELECT %s FROM %s WHERE %s.%s = %s.%s)", inner, meta.PrimaryKey,
+			rel.ReferencedKey, rel.JoinTable, rel.JoinTable, rel.ForeignKey, outer, r.meta.PrimaryKey), nil
+	case childRelation(r.meta, meta, rel):
+		return fmt.Sprintf("%s.%s = %s.%s", inner, rel.ForeignKey, outer, r.meta.PrimaryKey), nil
+	case rel.ForeignKey != "" && hasColumn(r.meta, rel.ForeignKey):
+		key := rel.ReferencedKey
+		if key == "" {
+			key = meta.PrimaryKey
+		}
+		return fmt.Sprintf("%s.%s = %s.%s", inner, key, outer, rel.ForeignKey), nil
+	}
+	return "", fmt.Errorf("relation %s has no foreign key column in %s or %s", name, outer, inner)
+}
+
+// relationTables lists the tables a relation reads: its model's and its join table
+func (r *Repository) relationTables(name string) []string {
+	meta, _, ok := r.relationTarget(name)
+	if !ok {
+		return nil
+	}
+	tables := []string{meta.TableName}
+	if rel := r.meta.Relations[name]; rel.Type == interfaces.ManyToMany && rel.JoinTable != "" {
+		tables = append(tables, rel.JoinTable)
+	}
+	return tables
+}
+
+// checkJoinTable reports a many-to-many relation missing part of its join table
+func checkJoinTable(name string, rel *interfaces.Relation) error {
+	if rel.JoinTable == "" || rel.ForeignKey == "" || rel.ReferencedKey == "" {
+		return fmt.Errorf("many-to-many relation %s needs a join_table, an fk and a referenced_key", name)
+	}
+	return nil
+}
+
+var numberedPlaceholder = regexp.MustCompile(`\$\d+`)
+
+// subquery renders a query to embed in another through WhereRaw. GetSQL leaves plain
+// conditions as ?, and Postgres placeholders of raw ones go back to ?, so that
+// WhereRaw numbers them all after the outer query's arguments.
+func subquery(d interfaces.Dialect, b interfaces.QueryBuilder) (string, []interface{}, error) {
+	if sb, ok := b.(*query.BuilderImpl); ok && sb.Err != nil {
+		return "", nil, sb.Err
+	}
+	sql := b.GetSQL()
+	if dialectKind(d) == kindPostgres {
+		sql = numberedPlaceholder.ReplaceAllString(sql, "?")
+	}
+	return sql, b.GetArgs(), nil
+}
+
+// keyText compares keys as text, as drivers return an int64 or []byte for an int key
+func keyText(key interface{}) string {
+	if b, ok := key.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprint(key)
+}
@@ -0,0 +1,162 @@
+package shared
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	ormcore "github.com/ESGI-M2/GO/orm"
+	"github.com/ESGI-M2/GO/orm/core/interfaces"
+)
+
+type relAuthor struct {
+	ID    int       `table:"rel_authors" orm:"pk,auto"`
+	Name  string    `orm:"column:name"`
+	Books []relBook `orm:"relation:one_to_many,fk:author_id"`
+}
+
+type relBook struct {
+	ID        int        `table:"rel_books" orm:"pk,auto"`
+	AuthorID  int        `orm:"column:author_id"`
+	Title     string     `orm:"column:title"`
+	Pages     int        `orm:"column:pages"`
+	DeletedAt *time.Time `orm:"column:deleted_at,soft"`
+	Genres    []relGenre `orm:"relation:many_to_many,fk:book_id" join_table:"rel_book_genres" referenced_key:"genre_id"`
+}
+
+type relGenre struct {
+	ID   int    `table:"rel_genres" orm:"pk,auto"`
+	Name string `orm:"column:name"`
+}
+
+// relationsORM seeds Ann with two Go books, Bob with two database books, Cy with a
+// trashed book and Dee with none
+func relationsORM(t *testing.T) ormcore.ORM {
+	t.Helper()
+	db, err := NewSQLiteORM(":memory:", &relAuthor{}, &relBook{}, &relGenre{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { Close(db) })
+	if _, err := db.GetDialect().Exec(`CREATE TABLE rel_book_genres (
+		book_id INT NOT NULL,
+		genre_id INT NOT NULL,
+		PRIMARY KEY (book_id, genre_id))`); err != nil {
+		t.Fatal(err)
+	}
+	genres := map[string]*relGenre{"Programming": {Name: "Programming"}, "Databases": {Name: "Databases"}}
+	for _, name := range []string{"Programming", "Databases"} {
+		if err := NewRepository(db, &relGenre{}).Save(genres[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	seed := []struct {
+		author string
+		books  []relBook
+		genres []string
+	}{
+		{"Ann", []relBook{{Title: "Learning Go", Pages: 320}, {Title: "Go in Practice", Pages: 280}}, []string{"Programming"}},
+		{"Bob", []relBook{{Title: "SQL Basics", Pages: 150}, {Title: "Indexing", Pages: 90}}, []string{"Programming", "Databases"}},
+		{"Cy", []relBook{{Title: "Old Go Notes", Pages: 40}}, nil},
+		{"Dee", nil, nil},
+	}
+	authors, books := NewRepository(db, &relAuthor{}), NewRepository(db, &relBook{})
+	for _, s := range seed {
+		a := &relAuthor{Name: s.author, Books: s.books}
+		if err := authors.WithAssociations().Save(a); err != nil {
+			t.Fatal(err)
+		}
+		for i := range a.Books {
+			for _, name := range s.genres {
+				if err := Association(db, &a.Books[i], "Genres").Attach(genres[name]); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if s.author == "Cy" {
+			if err := books.SoftDelete(&a.Books[0]); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return db
+}
+
+func titleLike(pattern string) Expr {
+	return func(b interfaces.QueryBuilder) interfaces.QueryBuilder {
+		return b.Where("title", "LIKE", pattern)
+	}
+}
+
+func TestWhereHas(t *testing.T) {
+	db := relationsORM(t)
+	authors, books := NewRepository(db, &relAuthor{}), NewRepository(db, &relBook{})
+	tests := []struct {
+		name   string
+		query  *ModelQuery
+		column string
+		want   string
+	}{
+		{"has a matching child", authors.Query().WhereHas("Books", titleLike("%Go%")), "name", "Ann"},
+		{"has any live child", authors.Query().WhereHas("Books"), "name", "Ann, Bob"},
+		{"has no child, trashed ones aside", authors.Query().WhereDoesntHave("Books"), "name", "Cy, Dee"},
+		{"conditions around the subquery", authors.Query().Where("name", "<>", "Ann").WhereHas("Books", titleLike("%Index%")), "name", "Bob"},
+		{"many-to-many through the join table", books.Query().WhereHas("Genres", func(b interfaces.QueryBuilder) interfaces.QueryBuilder {
+			return b.Where("name", "=", "Databases")
+		}), "title", "SQL Basics, Indexing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := tt.query.OrderBy("id", "ASC").Find()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, row := range rows {
+				got = append(got, fmt.Sprint(row[tt.column]))
+			}
+			if strings.Join(got, ", ") != tt.want {
+				t.Fatalf("got %v, want %s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("unknown relation", func(t *testing.T) {
+		if _, err := authors.Query().WhereHas("Reviews").Find(); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestRelationAggregates(t *testing.T) {
+	db := relationsORM(t)
+	authors := NewRepository(db, &relAuthor{})
+	tests := []struct {
+		name   string
+		query  *ModelQuery
+		column string
+		want   []interface{} // one per author: Ann, Bob, Cy, Dee
+	}{
+		{"WithCount", authors.Query().WithCount("Books"), "books_count", []interface{}{int64(2), int64(2), int64(0), int64(0)}},
+		{"WithCount as", authors.Query().WithCount("Books as go_books", titleLike("%Go%")), "go_books_count", []interface{}{int64(2), int64(0), int64(0), int64(0)}},
+		{"WithSum", authors.Query().WithSum("Books", "pages"), "books_sum_pages", []interface{}{int64(600), int64(240), nil, nil}},
+		{"WithMax", authors.Query().WithMax("Books", "pages"), "books_max_pages", []interface{}{int64(320), int64(150), nil, nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := tt.query.OrderBy("id", "ASC").Find()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []interface{}{}
+			for _, row := range rows {
+				got = append(got, row[tt.column])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("%s = %v, want %v", tt.column, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,616 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	ormcore "github.com/ESGI-M2/GO/orm"
+	"github.com/ESGI-M2/GO/orm/core/connection"
+	"github.com/ESGI-M2/GO/orm/core/interfaces"
+)
+
+// ErrStaleObject is returned when updating a versioned entity that was changed or
+// deleted since it was loaded
+var ErrStaleObject = errors.New("stale object")
+
+// Repository decorates the library repository with the behaviour declared by the tags
+// this package understands and the hook methods models implement (see hooks.go).
+// Methods it does not override go straight to the library.
+//
+//	CreatedAt time.Time `orm:"column:created_at,autoCreateTime"`
+//	UpdatedAt time.Time `orm:"column:updated_at,autoUpdateTime"`
+//	Version   int       `orm:"column:version,version"`
+type Repository struct {
+	interfaces.Repository
+
+	orm   ormcore.ORM
+	model interface{}
+	meta  *interfaces.ModelMetadata
+	err   error
+	now   func() time.Time
+	ctx   context.Context
+
+	// tracked holds the snapshots of loaded entities, shared by Select/Omit copies;
+	// FindInto only adds to it when tracking is on
+	tracked  *snapshots
+	tracking bool
+	selected []string
+	omitted  []string
+
+	// scopes are applied to every query after the global scopes not in without
+	scopes  []Expr
+	without []string
+
+	// session holds the identity map of a Session's repositories
+	session *Session
+	// associations makes Save save the children in relation fields too
+	associations bool
+}
+
+// Repository still satisfies the library interface, so it can replace a library
+// repository anywhere
+var _ interfaces.Repository = (*Repository)(nil)
+
+// RepositoryOption configures a Repository
+type RepositoryOption func(*Repository)
+
+// WithClock replaces time.Now, e.g. with a fixed clock in tests
+func WithClock(now func() time.Time) RepositoryOption {
+	return func(r *Repository) {
+		r.now = now
+	}
+}
+
+// WithTracking makes FindInto track the entities it loads, so that Update only writes
+// the columns that changed. Each snapshot lives until the entity is deleted or passed to
+// Forget, so use it on short-lived repositories, or use a Session, whose repositories
+// track and whose snapshots go with it.
+func WithTracking() RepositoryOption {
+	return func(r *Repository) {
+		r.tracking = true
+	}
+}
+
+// NewRepository wraps orm.Repository(model). Like the library, it does not fail on an
+// unknown model; the error is returned by the first call instead.
+func NewRepository(orm ormcore.ORM, model interface{}, opts ...RepositoryOption) *Repository {
+	r := &Repository{
+		Repository: orm.Repository(model),
+		orm:        orm,
+		model:      model,
+		now:        time.Now,
+		ctx:        context.Background(),
+		tracked:    newSnapshots(),
+	}
+	r.meta, r.err = orm.GetMetadata(model)
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// WithContext returns a repository that passes ctx to the hooks and to the
+// transactions it opens
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	c := *r
+	c.ctx = ctx
+	return &c
+}
+
+// WithTx returns a repository writing through tx, the ORM handed to a Transaction
+// callback. It opens no transaction of its own, so its writes and hooks commit or roll
+// back with the caller's. When shared.Transaction started it, a rollback also gives the
+// entities written through the repository their previous key and version back; a plain
+// orm.Transaction leaves them as written.
+func (r *Repository) WithTx(tx ormcore.ORM) *Repository {
+	c := *r
+	c.orm = tx
+	c.Repository = tx.Repository(r.model)
+	return &c
+}
+
+// Save inserts the entity when its primary key is zero and updates it otherwise. A
+// WithAssociations repository saves its relations too.
+func (r *Repository) Save(entity interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	if r.associations {
+		return r.saveGraph(entity)
+	}
+	isNew, err := r.isNew(entity)
+	if err != nil {
+		return err
+	}
+	if !isNew {
+		return r.Update(entity)
+	}
+	return r.create(entity)
+}
+
+// create inserts the entity between its BeforeCreate/BeforeSave and AfterCreate/AfterSave
+// hooks. If anything fails, or the transaction it was written in rolls back, the entity
+// gets its zero primary key and version back.
+func (r *Repository) create(entity interface{}) error {
+	pk, err := r.primaryKey(entity)
+	if err != nil {
+		return err
+	}
+	version, _, versioned := versionField(entity)
+	var loaded int64
+	if versioned {
+		loaded = version.Int()
+	}
+	reset := func() {
+		r.forgetIdentity(entity)
+		r.tracked.forget(entity)
+		pk.Set(reflect.Zero(pk.Type()))
+		if versioned {
+			version.SetInt(loaded)
+		}
+	}
+	events := []string{"BeforeCreate", "BeforeSave", "AfterCreate", "AfterSave"}
+	err = r.atomically(r.hasHooks(entity, events...), func(r *Repository) error {
+		for _, event := range events[:2] {
+			if err := r.callHook(event, entity); err != nil {
+				return err
+			}
+		}
+		r.stamp(entity, true)
+		if versioned && loaded == 0 {
+			version.SetInt(1)
+		}
+
+		var columns []string
+		for _, col := range r.meta.Columns {
+			if !col.AutoIncrement {
+				columns = append(columns, col.Name)
+			}
+		}
+		if err := r.insertColumns(entity, r.filterColumns(columns)); err != nil {
+			return err
+		}
+		r.onRollback(reset)
+		for _, event := range events[2:] {
+			if err := r.callHook(event, entity); err != nil {
+				return err
+			}
+		}
+		r.publish(Event{Type: EventCreated}, entity)
+		return nil
+	})
+	if err != nil {
+		reset()
+	}
+	return err
+}
+
+// Update writes the entity between its BeforeUpdate/BeforeSave and AfterUpdate/AfterSave
+// hooks. A tracked entity (see FindInto and Track) only writes the columns that changed
+// since it was loaded, Select/Omit narrow the columns further, and a versioned entity is
+// only written if the row still has the version it was loaded with, otherwise
+// ErrStaleObject is returned.
+func (r *Repository) Update(entity interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	version, _, versioned := versionField(entity)
+	var loaded int64
+	if versioned {
+		loaded = version.Int()
+	}
+
+	var columns []string
+	events := []string{"BeforeUpdate", "BeforeSave", "AfterUpdate", "AfterSave"}
+	err := r.atomically(r.hasHooks(entity, events...), func(r *Repository) error {
+		before := r.previous(entity)
+		for _, event := range events[:2] {
+			if err := r.callHook(event, entity); err != nil {
+				return err
+			}
+		}
+
+		for _, col := range r.meta.Columns {
+			if col.Name != r.meta.PrimaryKey {
+				columns = append(columns, col.Name)
+			}
+		}
+		columns = r.filterColumns(columns)
+		if r.tracked.has(entity) && len(r.selected) == 0 {
+			columns = intersect(columns, r.Dirty(entity))
+			if len(columns) == 0 {
+				return nil
+			}
+		}
+
+		r.stamp(entity, false)
+		for _, col := range autoUpdateColumns(entity) {
+			if !contains(r.omitted, col) {
+				columns = append(columns, col)
+			}
+		}
+		if err := r.updateColumns(entity, columns); err != nil {
+			return err
+		}
+		for _, event := range events[2:] {
+			if err := r.callHook(event, entity); err != nil {
+				return err
+			}
+		}
+		r.publish(Event{Type: EventUpdated, Before: before}, entity)
+		return nil
+	})
+	if err != nil {
+		if versioned {
+			version.SetInt(loaded)
+		}
+		return err
+	}
+	r.tracked.refresh(entity, columns)
+	return nil
+}
+
+// Delete removes the row of the entity between its BeforeDelete and AfterDelete hooks
+func (r *Repository) Delete(entity interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	pk, err := r.primaryKey(entity)
+	if err != nil {
+		return err
+	}
+	err = r.atomically(r.hasHooks(entity, "BeforeDelete", "AfterDelete"), func(r *Repository) error {
+		before := r.previous(entity)
+		if err := r.callHook("BeforeDelete", entity); err != nil {
+			return err
+		}
+		query := fmt.Sprintf("DELETE FROM %s WHERE %s = %s",
+			r.meta.TableName, r.meta.PrimaryKey, r.orm.GetDialect().GetPlaceholder(0))
+		if _, err := r.orm.GetDialect().Exec(query, pk.Interface()); err != nil {
+			return fmt.Errorf("failed to delete entity: %w", err)
+		}
+		r.invalidateCache()
+		if err := r.callHook("AfterDelete", entity); err != nil {
+			return err
+		}
+		r.publish(Event{Type: EventDeleted, Before: before}, entity)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	r.tracked.forget(entity)
+	r.forgetIdentity(entity)
+	return nil
+}
+
+// ForceDelete removes the row even when the model soft deletes
+func (r *Repository) ForceDelete(entity interface{}) error {
+	return r.Delete(entity)
+}
+
+// SoftDelete sets the soft-delete column to the current time between the
+// BeforeSoftDelete and AfterSoftDelete hooks
+func (r *Repository) SoftDelete(entity interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.setDeletedAt(entity, "SoftDelete", r.now())
+}
+
+// Restore clears the soft-delete column between the BeforeRestore and AfterRestore hooks
+func (r *Repository) Restore(entity interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.setDeletedAt(entity, "Restore", time.Time{})
+}
+
+// setDeletedAt writes the soft-delete column, cleared when at is zero, between the
+// hooks of the event. The field keeps its previous value if anything fails.
+func (r *Repository) setDeletedAt(entity interface{}, event string, at time.Time) error {
+	if !r.meta.SoftDeletes {
+		return fmt.Errorf("soft deletes not enabled for this model")
+	}
+	v, ok := structValue(entity)
+	if !ok {
+		return fmt.Errorf("entity must be a pointer to a struct, got %T", entity)
+	}
+	field, ok := fieldByColumn(v, r.meta.DeletedAt)
+	if !ok {
+		return fmt.Errorf("no field is stored in column %s", r.meta.DeletedAt)
+	}
+	previous := reflect.ValueOf(field.Interface())
+	if !at.IsZero() {
+		at = at.UTC().Truncate(time.Microsecond)
+	}
+
+	needed := r.hasHooks(entity, "Before"+event, "After"+event) || len(softCascades(entity)) > 0
+	err := r.atomically(needed, func(r *Repository) error {
+		before := r.previous(entity)
+		deletedAt, err := r.storedDeletedAt(entity, at)
+		if err != nil {
+			return err
+		}
+		if err := r.callHook("Before"+event, entity); err != nil {
+			return err
+		}
+		if at.IsZero() {
+			field.Set(reflect.Zero(field.Type()))
+		} else {
+			setTime(field, at)
+		}
+		if err := r.updateColumns(entity, []string{r.meta.DeletedAt}); err != nil {
+			return err
+		}
+		if err := r.cascadeSoft(entity, at, deletedAt); err != nil {
+			return err
+		}
+		if err := r.callHook("After"+event, entity); err != nil {
+			return err
+		}
+		if at.IsZero() {
+			r.publish(Event{Type: EventRestored, Before: before}, entity)
+		} else {
+			r.publish(Event{Type: EventDeleted, Soft: true, Before: before}, entity)
+		}
+		return nil
+	})
+	if err != nil {
+		field.Set(previous)
+		return err
+	}
+	r.tracked.refresh(entity, []string{r.meta.DeletedAt})
+	if !at.IsZero() {
+		r.forgetIdentity(entity) // hidden by the soft-delete scope from now on
+	}
+	return nil
+}
+
+// BatchCreate inserts the entities in one transaction, running each one's hooks
+func (r *Repository) BatchCreate(entities []interface{}) error {
+	return r.batch(entities, (*Repository).create)
+}
+
+// BatchUpdate updates the entities in one transaction, running each one's hooks
+func (r *Repository) BatchUpdate(entities []interface{}) error {
+	return r.batch(entities, (*Repository).Update)
+}
+
+// BatchDelete deletes the entities in one transaction, running each one's hooks
+func (r *Repository) BatchDelete(entities []interface{}) error {
+	return r.batch(entities, (*Repository).Delete)
+}
+
+// batch applies op to every entity in one transaction
+func (r *Repository) batch(entities []interface{}, op func(*Repository, interface{}) error) error {
+	if r.err != nil {
+		return r.err
+	}
+	if len(entities) == 0 {
+		return nil
+	}
+	return r.atomically(true, func(r *Repository) error {
+		for _, entity := range entities {
+			if err := op(r, entity); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// atomically runs fn with a repository bound to a new transaction, so that hooks and
+// the write commit or roll back together and events are published after the commit.
+// When there are neither hooks nor subscribers, or the repository already writes
+// through a transaction, fn runs on r itself.
+func (r *Repository) atomically(needed bool, fn func(*Repository) error) error {
+	if (!needed && !eventsOf(r.orm).subscribed(r.meta.Type)) || r.inTransaction() {
+		return fn(r)
+	}
+	return Transaction(r.ctx, r.orm, func(tx ormcore.ORM) error {
+		return fn(r.WithTx(tx))
+	})
+}
+
+// onRollback registers fn to run if the transaction the repository writes through
+// rolls back. Only transactions started by Transaction are seen; elsewhere fn never runs.
+func (r *Repository) onRollback(fn func()) {
+	if p := pendingOf(r.orm); p != nil {
+		p.onRollback(fn)
+	}
+}
+
+// inTransaction reports whether the repository writes through a transaction
+func (r *Repository) inTransaction() bool {
+	_, ok := r.orm.GetDialect().(*connection.TransactionDialect)
+	return ok
+}
+
+// updateColumns writes the given columns of the entity, matching the row by primary
+// key and, for versioned entities, by version, which is then incremented
+func (r *Repository) updateColumns(entity interface{}, columns []string) error {
+	v, ok := structValue(entity)
+	if !ok {
+		return fmt.Errorf("entity must be a pointer to a struct, got %T", entity)
+	}
+	pk, err := r.primaryKey(entity)
+	if err != nil {
+		return err
+	}
+	d := r.orm.GetDialect()
+	version, versionCol, versioned := versionField(entity)
+
+	var sets []string
+	var args []interface{}
+	seen := map[string]bool{}
+	for _, col := range columns {
+		if seen[col] || col == r.meta.PrimaryKey || (versioned && col == versionCol) {
+			continue
+		}
+		seen[col] = true
+		field, ok := fieldByColumn(v, col)
+		if !ok {
+			return fmt.Errorf("no field is stored in column %s", col)
+		}
+		sets = append(sets, fmt.Sprintf("%s = %s", col, d.GetPlaceholder(len(args))))
+		args = append(args, field.Interface())
+	}
+	if versioned {
+		sets = append(sets, fmt.Sprintf("%s = %s", versionCol, d.GetPlaceholder(len(args))))
+		args = append(args, version.Int()+1)
+	}
+	if len(sets) == 0 {
+		return nil
+	}
+
+	where := fmt.Sprintf("%s = %s", r.meta.PrimaryKey, d.GetPlaceholder(len(args)))
+	args = append(args, pk.Interface())
+	if versioned {
+		where += fmt.Sprintf(" AND %s = %s", versionCol, d.GetPlaceholder(len(args)))
+		args = append(args, version.Int())
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s", r.meta.TableName, strings.Join(sets, ", "), where)
+	result, err := d.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update record: %w", err)
+	}
+	if versioned {
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to check updated rows: %w", err)
+		}
+		if affected == 0 {
+			return fmt.Errorf("%w: %s %v is no longer at version %d", ErrStaleObject, r.meta.TableName, pk.Interface(), version.Int())
+		}
+	}
+	restore := r.tracked.checkpoint(entity)
+	var loaded int64
+	if versioned {
+		loaded = version.Int()
+		version.SetInt(loaded + 1)
+	}
+	r.onRollback(func() {
+		restore()
+		if versioned {
+			version.SetInt(loaded)
+		}
+	})
+	r.invalidateCache()
+	r.syncIdentity(entity, columns)
+	return nil
+}
+
+// versionField returns the integer field tagged `version` and its column
+func versionField(entity interface{}) (reflect.Value, string, bool) {
+	v, ok := structValue(entity)
+	if !ok {
+		return reflect.Value{}, "", false
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		col, ok := columnName(field)
+		if !ok || !parseTag(field).has("version") {
+			continue
+		}
+		switch field.Type.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return v.Field(i), col, true
+		}
+	}
+	return reflect.Value{}, "", false
+}
+
+// stamp sets the timestamp fields from the repository clock
+func (r *Repository) stamp(entity interface{}, creating bool) {
+	stampTimestamps(entity, r.now(), creating)
+}
+
+// isNew reports whether the entity has no primary key yet
+func (r *Repository) isNew(entity interface{}) (bool, error) {
+	pk, err := r.primaryKey(entity)
+	if err != nil {
+		return false, err
+	}
+	return pk.IsZero(), nil
+}
+
+// primaryKey returns the primary key field of the entity
+func (r *Repository) primaryKey(entity interface{}) (reflect.Value, error) {
+	v, ok := structValue(entity)
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("entity must be a pointer to a struct, got %T", entity)
+	}
+	if field, ok := fieldByColumn(v, r.meta.PrimaryKey); ok {
+		return field, nil
+	}
+	return reflect.Value{}, fmt.Errorf("primary key field %s not found", r.meta.PrimaryKey)
+}
+
+// fieldByColumn returns the struct field stored in the given column
+func fieldByColumn(v reflect.Value, column string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if name, ok := columnName(t.Field(i)); ok && name == column {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// StampTimestamps fills the autoCreateTime fields still at their zero value and every
+// autoUpdateTime field of a new entity, for code writing through a plain library repository
+func StampTimestamps(entity interface{}, now time.Time) {
+	stampTimestamps(entity, now, true)
+}
+
+// stampTimestamps sets autoCreateTime fields when creating (unless already set) and
+// autoUpdateTime fields every time. Times are stored in UTC, truncated to the
+// microseconds Postgres and the TIMESTAMP(6) columns Migrate creates on MySQL keep,
+// so a reloaded value compares equal; a plain MySQL TIMESTAMP keeps whole seconds.
+func stampTimestamps(entity interface{}, now time.Time, creating bool) {
+	v, ok := structValue(entity)
+	if !ok {
+		return
+	}
+	now = now.UTC().Truncate(time.Microsecond)
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := parseTag(t.Field(i))
+		field := v.Field(i)
+		switch {
+		case tag.has("autoUpdateTime"):
+			setTime(field, now)
+		case tag.has("autoCreateTime") && creating && isZeroTime(field):
+			setTime(field, now)
+		}
+	}
+}
+
+// setTime assigns a time to a time.Time or *time.Time field
+func setTime(field reflect.Value, now time.Time) {
+	switch field.Type() {
+	case reflect.TypeOf(time.Time{}):
+		field.Set(reflect.ValueOf(now))
+	case reflect.TypeOf(&time.Time{}):
+		field.Set(reflect.ValueOf(&now))
+	}
+}
+
+// isZeroTime reports whether a time.Time or *time.Time field is unset
+func isZeroTime(field reflect.Value) bool {
+	switch v := field.Interface().(type) {
+	case time.Time:
+		return v.IsZero()
+	case *time.Time:
+		return v == nil || v.IsZero()
+	}
+	return false
+}
@@ -0,0 +1,231 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	ormcore "github.com/ESGI-M2/GO/orm"
+)
+
+type versionItem struct {
+	ID      int    `table:"version_items" orm:"pk,auto"`
+	Name    string `orm:"column:name,unique"`
+	Version int    `orm:"column:version,version"`
+}
+
+var errAbortTx = errors.New("abort")
+
+func TestOptimisticLocking(t *testing.T) {
+	tests := []struct {
+		name string
+		// act writes with a, loaded at version 1 under the name "a"; its error must
+		// match wantErr
+		act     func(db ormcore.ORM, items *Repository, a *versionItem) error
+		wantErr error
+		// the version of a and of its row afterwards, and the row's name
+		version int
+		row     string
+		// retry is set when a was put back and can be written again
+		retry bool
+	}{
+		{"Update bumps the version", func(_ ormcore.ORM, items *Repository, a *versionItem) error {
+			a.Name = "b"
+			return items.Update(a)
+		}, nil, 2, "b:2", false},
+		{"a stale copy is refused", func(_ ormcore.ORM, items *Repository, a *versionItem) error {
+			other := *a
+			other.Name = "b"
+			if err := items.Update(&other); err != nil {
+				return err
+			}
+			a.Name = "c"
+			return items.Update(a)
+		}, ErrStaleObject, 1, "b:2", false},
+		{"a deleted row is stale", func(db ormcore.ORM, items *Repository, a *versionItem) error {
+			if err := items.Delete(&versionItem{ID: a.ID}); err != nil {
+				return err
+			}
+			return items.Update(a)
+		}, ErrStaleObject, 1, "", false},
+		{"a rolled back batch puts every version back", func(_ ormcore.ORM, items *Repository, a *versionItem) error {
+			stale := &versionItem{ID: a.ID, Name: "c", Version: 7}
+			a.Name = "b"
+			return items.BatchUpdate([]interface{}{a, stale})
+		}, ErrStaleObject, 1, "a:1", true},
+		{"a rolled back Transaction puts the version back", func(db ormcore.ORM, items *Repository, a *versionItem) error {
+			return Transaction(context.Background(), db, func(tx ormcore.ORM) error {
+				a.Name = "b"
+				if err := items.WithTx(tx).Update(a); err != nil {
+					return err
+				}
+				return errAbortTx
+			})
+		}, errAbortTx, 1, "a:1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := NewSQLiteORM(":memory:", &versionItem{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer Close(db)
+			items := NewRepository(db, &versionItem{})
+			a := &versionItem{Name: "a"}
+			if err := items.Save(a); err != nil {
+				t.Fatal(err)
+			}
+			if a.Version != 1 {
+				t.Fatalf("inserted at version %d, want 1", a.Version)
+			}
+
+			if err := tt.act(db, items, a); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error %v, want %v", err, tt.wantErr)
+			}
+			if a.Version != tt.version {
+				t.Errorf("version %d, want %d", a.Version, tt.version)
+			}
+			if got := versionRow(t, db, a.ID); got != tt.row {
+				t.Errorf("row %q, want %q", got, tt.row)
+			}
+			if tt.retry {
+				if err := items.Update(a); err != nil {
+					t.Fatalf("Update after the rollback: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestBatchCreateRollback(t *testing.T) {
+	db, err := NewSQLiteORM(":memory:", &versionItem{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(db)
+	items := NewRepository(db, &versionItem{})
+	first, duplicate := &versionItem{Name: "a"}, &versionItem{Name: "a"}
+	if err := items.BatchCreate([]interface{}{first, duplicate}); err == nil {
+		t.Fatal("expected a unique violation")
+	}
+	for _, e := range []*versionItem{first, duplicate} {
+		if e.ID != 0 || e.Version != 0 {
+			t.Errorf("entity left at id %d, version %d", e.ID, e.Version)
+		}
+	}
+	if n, _ := items.Count(); n != 0 {
+		t.Fatalf("%d rows left by a failed batch", n)
+	}
+	if err := items.Save(first); err != nil || first.ID == 0 {
+		t.Fatalf("Save after the rollback: id %d, %v", first.ID, err)
+	}
+}
+
+// versionRow returns the name and version stored for an id, "" when there is no row
+func versionRow(t *testing.T, db ormcore.ORM, id int) string {
+	t.Helper()
+	row, err := db.Query(&versionItem{}).Where("id", "=", id).FindOne()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v:%v", row["name"], row["version"])
+}
+
+type stampItem struct {
+	ID        int        `table:"stamp_items" orm:"pk,auto"`
+	Name      string     `orm:"column:name"`
+	CreatedAt time.Time  `orm:"column:created_at,autoCreateTime"`
+	UpdatedAt *time.Time `orm:"column:updated_at,autoUpdateTime"`
+	DeletedAt *time.Time `orm:"column:deleted_at,soft"`
+}
+
+func TestAutoTimestamps(t *testing.T) {
+	paris := time.FixedZone("CEST", 2*60*60)
+	t0 := time.Date(2024, 5, 6, 12, 0, 0, 123456789, paris)
+	t1 := t0.Add(time.Hour)
+	set := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		// act saves item with the clock at t0; later writes move it forward
+		act func(items *Repository, clock *time.Time, item *stampItem) error
+		// the stored times, zero when unset
+		created, updated, deleted time.Time
+	}{
+		{"Save stamps both fields", func(items *Repository, _ *time.Time, item *stampItem) error {
+			return items.Save(item)
+		}, t0, t0, time.Time{}},
+		{"Save keeps a CreatedAt already set", func(items *Repository, _ *time.Time, item *stampItem) error {
+			item.CreatedAt = set
+			return items.Save(item)
+		}, set, t0, time.Time{}},
+		{"Update only moves UpdatedAt", func(items *Repository, clock *time.Time, item *stampItem) error {
+			if err := items.Save(item); err != nil {
+				return err
+			}
+			*clock = t1
+			item.Name = "b"
+			return items.Update(item)
+		}, t0, t1, time.Time{}},
+		{"UpdateFields moves UpdatedAt", func(items *Repository, clock *time.Time, item *stampItem) error {
+			if err := items.Save(item); err != nil {
+				return err
+			}
+			*clock = t1
+			item.Name = "b"
+			return items.UpdateFields(item, "name")
+		}, t0, t1, time.Time{}},
+		{"BatchCreate stamps every entity", func(items *Repository, _ *time.Time, item *stampItem) error {
+			return items.BatchCreate([]interface{}{&stampItem{Name: "b"}, item})
+		}, t0, t0, time.Time{}},
+		{"SoftDelete only sets deleted_at", func(items *Repository, clock *time.Time, item *stampItem) error {
+			if err := items.Save(item); err != nil {
+				return err
+			}
+			*clock = t1
+			return items.SoftDelete(item)
+		}, t0, t0, t1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := NewSQLiteORM(":memory:", &stampItem{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer Close(db)
+			clock := t0
+			items := NewRepository(db, &stampItem{}, WithClock(func() time.Time { return clock }))
+			item := &stampItem{Name: "a"}
+			if err := tt.act(items, &clock, item); err != nil {
+				t.Fatal(err)
+			}
+			if loc := item.UpdatedAt.Location(); loc != time.UTC {
+				t.Errorf("UpdatedAt in %v, want UTC", loc)
+			}
+
+			row, err := db.Query(&stampItem{}).Where("id", "=", item.ID).FindOne()
+			if err != nil {
+				t.Fatal(err)
+			}
+			stored := &stampItem{}
+			if err := Decode(row, stored); err != nil {
+				t.Fatal(err)
+			}
+			check := func(field string, got *time.Time, want time.Time) {
+				t.Helper()
+				// stored times keep microseconds
+				want = want.Truncate(time.Microsecond)
+				if (got == nil) != want.IsZero() || got != nil && !got.Equal(want) {
+					t.Errorf("%s %v, want %v", field, got, want)
+				}
+			}
+			check("created_at", &stored.CreatedAt, tt.created)
+			check("updated_at", stored.UpdatedAt, tt.updated)
+			check("deleted_at", stored.DeletedAt, tt.deleted)
+		})
+	}
+}
@@ -0,0 +1,330 @@
+package shared
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	ormcore "github.com/ESGI-M2/GO/orm"
+	"github.com/ESGI-M2/GO/orm/core/interfaces"
+)
+
+// fieldColumn pairs a struct field with the column the library extracted for it
+type fieldColumn struct {
+	field  reflect.StructField
+	tag    ormTag
+	column interfaces.Column
+}
+
+// tableIndex is an index declared through `index`, `index:name` or `unique:name`
+type tableIndex struct {
+	name    string
+	unique  bool
+	columns []string
+}
+
+// Migrate registers the models and creates their tables from the orm tags, in the order
+// given so that foreign keys can reference tables created earlier. On top of what
+// ORM.Migrate understands it handles:
+//
+//	size:191              VARCHAR length
+//	type:TEXT             column type override
+//	default:draft         default value, quoted for string fields
+//	not null              NOT NULL (the default unless `nullable`)
+//	index, index:name     single or composite (same name) index
+//	unique:name           composite unique index
+//	fk:users.id           foreign key, with on_delete:cascade / on_update:...;
+//	                      the table as declared, renamed by ApplyNaming
+//	json, fulltext        native JSON column, full-text index
+//
+// Indexes are also added to tables that already exist; their columns are left alone.
+func Migrate(orm ormcore.ORM, models ...interface{}) error {
+	d := orm.GetDialect()
+	kind := dialectKind(d)
+
+	for _, model := range models {
+		if err := orm.RegisterModel(model); err != nil {
+			return fmt.Errorf("failed to register model %T: %w", model, err)
+		}
+		meta, columns, err := modelColumns(orm, model)
+		if err != nil {
+			return err
+		}
+
+		exists, err := d.TableExists(meta.TableName)
+		if err != nil {
+			return fmt.Errorf("failed to check if table %s exists: %w", meta.TableName, err)
+		}
+		if !exists {
+			if _, err := d.Exec(createTableSQL(orm, kind, meta.TableName, columns)); err != nil {
+				return fmt.Errorf("failed to create table %s: %w", meta.TableName, err)
+			}
+		}
+
+		for _, idx := range tableIndexes(meta.TableName, columns) {
+			if kind == kindMySQL {
+				found, err := mysqlIndexExists(d, meta.TableName, idx.name)
+				if err != nil {
+					return err
+				}
+				if found {
+					continue
+				}
+			}
+			if _, err := d.Exec(createIndexSQL(kind, meta.TableName, idx)); err != nil {
+				return fmt.Errorf("failed to create index %s: %w", idx.name, err)
+			}
+		}
+	}
+
+	return MigrateFullTextIndexes(orm, models...)
+}
+
+// SchemaSQL returns the statements Migrate would run for the models on an empty
+// database, excluding full-text indexes
+func SchemaSQL(orm ormcore.ORM, models ...interface{}) ([]string, error) {
+	kind := dialectKind(orm.GetDialect())
+
+	var stmts []string
+	for _, model := range models {
+		if err := orm.RegisterModel(model); err != nil {
+			return nil, fmt.Errorf("failed to register model %T: %w", model, err)
+		}
+		meta, columns, err := modelColumns(orm, model)
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, createTableSQL(orm, kind, meta.TableName, columns))
+		for _, idx := range tableIndexes(meta.TableName, columns) {
+			stmts = append(stmts, createIndexSQL(kind, meta.TableName, idx))
+		}
+	}
+	return stmts, nil
+}
+
+// modelColumns returns the metadata of a model with its columns in field order
+func modelColumns(orm ormcore.ORM, model interface{}) (*interfaces.ModelMetadata, []fieldColumn, error) {
+	meta, err := orm.GetMetadata(model)
+	if err != nil {
+		return nil, nil, err
+	}
+	byName := make(map[string]interfaces.Column, len(meta.Columns))
+	for _, col := range meta.Columns {
+		byName[col.Name] = col
+	}
+
+	var columns []fieldColumn
+	t := meta.Type
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, ok := columnName(field)
+		if !ok {
+			continue
+		}
+		col, ok := byName[name]
+		if !ok {
+			continue
+		}
+		columns = append(columns, fieldColumn{field: field, tag: parseTag(field), column: col})
+	}
+	return meta, columns, nil
+}
+
+// createTableSQL renders the CREATE TABLE statement, foreign keys as table constraints
+// referencing the table under the name ApplyNaming gave it
+func createTableSQL(orm ormcore.ORM, kind, table string, columns []fieldColumn) string {
+	var defs, constraints []string
+	for _, c := range columns {
+		defs = append(defs, columnSQL(kind, c))
+
+		ref := c.tag["fk"]
+		if ref == "" {
+			continue
+		}
+		parts := strings.SplitN(ref, ".", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		fk := fmt.Sprintf("CONSTRAINT fk_%s_%s FOREIGN KEY (%s) REFERENCES %s(%s)",
+			table, c.column.Name, c.column.Name, tableOf(orm, parts[0]), parts[1])
+		if action := c.tag["on_delete"]; action != "" {
+			fk += " ON DELETE " + referentialAction(action)
+		}
+		if action := c.tag["on_update"]; action != "" {
+			fk += " ON UPDATE " + referentialAction(action)
+		}
+		constraints = append(constraints, fk)
+	}
+
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n  %s\n)",
+		table, strings.Join(append(defs, constraints...), ",\n  "))
+}
+
+// columnSQL renders a single column definition
+func columnSQL(kind string, c fieldColumn) string {
+	col := c.column
+
+	if col.PrimaryKey && col.AutoIncrement {
+		switch kind {
+		case kindPostgres:
+			if col.Type == "BIGINT" {
+				return col.Name + " BIGSERIAL PRIMARY KEY"
+			}
+			return col.Name + " SERIAL PRIMARY KEY"
+		case kindSQLite:
+			return col.Name + " INTEGER PRIMARY KEY AUTOINCREMENT"
+		default:
+			return fmt.Sprintf("%s %s NOT NULL AUTO_INCREMENT PRIMARY KEY", col.Name, col.Type)
+		}
+	}
+
+	parts := []string{col.Name, columnType(kind, c)}
+
+	if c.tag.has("not null") || !col.Nullable {
+		parts = append(parts, "NOT NULL")
+	} else if kind == kindMySQL {
+		// Older MySQL servers make TIMESTAMP columns NOT NULL unless told otherwise
+		parts = append(parts, "NULL")
+	}
+	if value, ok := c.tag["default"]; ok && value != "" {
+		parts = append(parts, "DEFAULT "+defaultLiteral(c.field.Type, value))
+	}
+	if col.PrimaryKey {
+		parts = append(parts, "PRIMARY KEY")
+	}
+	// A bare `unique`; `unique:name` becomes a composite index instead
+	if value, ok := c.tag["unique"]; ok && value == "" {
+		parts = append(parts, "UNIQUE")
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// columnType picks the SQL type: `type:` wins, then JSON, then the library's type
+// translated for the dialect, with the `size:`/`length:` applied to VARCHARs
+func columnType(kind string, c fieldColumn) string {
+	if t := c.tag["type"]; t != "" {
+		return t
+	}
+	if c.tag.has("json") {
+		switch kind {
+		case kindPostgres:
+			return "JSONB"
+		case kindSQLite:
+			return "TEXT"
+		default:
+			return "JSON"
+		}
+	}
+
+	sqlType := c.column.Type
+	// The library maps every pointer to TEXT, including the usual *time.Time
+	if c.field.Type == reflect.TypeOf(&time.Time{}) {
+		sqlType = "TIMESTAMP"
+	}
+	size := c.tag["size"]
+	if size == "" {
+		size = c.tag["length"]
+	}
+	if size != "" && strings.HasPrefix(sqlType, "VARCHAR") {
+		sqlType = fmt.Sprintf("VARCHAR(%s)", size)
+	}
+
+	// A MySQL TIMESTAMP keeps whole seconds unless told otherwise, and the
+	// repository writes microseconds
+	if kind == kindMySQL && sqlType == "TIMESTAMP" {
+		return "TIMESTAMP(6)"
+	}
+	if kind == kindPostgres {
+		switch sqlType {
+		case "DOUBLE":
+			return "DOUBLE PRECISION"
+		case "FLOAT":
+			return "REAL"
+		case "INT UNSIGNED":
+			return "BIGINT"
+		case "BIGINT UNSIGNED":
+			return "NUMERIC(20)"
+		case "BLOB":
+			return "BYTEA"
+		}
+	}
+	return sqlType
+}
+
+// defaultLiteral quotes defaults of string fields; other values are used verbatim so
+// that numbers, booleans and expressions such as CURRENT_TIMESTAMP work
+func defaultLiteral(t reflect.Type, value string) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.String {
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	}
+	return value
+}
+
+// referentialAction turns "cascade" or "set_null" into CASCADE / SET NULL
+func referentialAction(action string) string {
+	return strings.ToUpper(strings.ReplaceAll(action, "_", " "))
+}
+
+// tableIndexes collects the indexes declared on the columns, composite indexes being
+// the columns sharing a name, in field order
+func tableIndexes(table string, columns []fieldColumn) []tableIndex {
+	byName := map[string]*tableIndex{}
+	add := func(name string, unique bool, column string) {
+		idx, ok := byName[name]
+		if !ok {
+			idx = &tableIndex{name: name, unique: unique}
+			byName[name] = idx
+		}
+		idx.columns = append(idx.columns, column)
+	}
+
+	for _, c := range columns {
+		if name, ok := c.tag["index"]; ok {
+			if name == "" {
+				name = fmt.Sprintf("idx_%s_%s", table, c.column.Name)
+			}
+			add(name, false, c.column.Name)
+		}
+		if name := c.tag["unique"]; name != "" {
+			add(name, true, c.column.Name)
+		}
+	}
+
+	indexes := make([]tableIndex, 0, len(byName))
+	for _, idx := range byName {
+		indexes = append(indexes, *idx)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i].name < indexes[j].name })
+	return indexes
+}
+
+// createIndexSQL renders a CREATE INDEX statement; MySQL has no IF NOT EXISTS
+func createIndexSQL(kind, table string, idx tableIndex) string {
+	stmt := "CREATE INDEX "
+	if idx.unique {
+		stmt = "CREATE UNIQUE INDEX "
+	}
+	if kind != kindMySQL {
+		stmt += "IF NOT EXISTS "
+	}
+	return fmt.Sprintf("%s%s ON %s (%s)", stmt, idx.name, table, strings.Join(idx.columns, ", "))
+}
+
+// mysqlIndexExists reports whether the table of the current database has the index
+func mysqlIndexExists(d interfaces.Dialect, table, name string) (bool, error) {
+	var count int
+	err := d.QueryRow("SELECT COUNT(*) FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?", table, name).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up index %s: %w", name, err)
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,138 @@
+package shared
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type schemaAuthor struct {
+	ID    int    `table:"schema_authors" orm:"pk,auto"`
+	Email string `orm:"column:email,size:191,unique"`
+}
+
+type schemaArticle struct {
+	ID          int        `table:"schema_articles" orm:"pk,auto"`
+	AuthorID    int        `orm:"column:author_id,fk:schema_authors.id,on_delete:cascade,unique:uq_schema_articles_slug"`
+	Slug        string     `orm:"column:slug,size:120,unique:uq_schema_articles_slug"`
+	Body        string     `orm:"column:body,type:TEXT"`
+	Status      string     `orm:"column:status,size:20,default:draft,index:idx_schema_articles_status"`
+	PublishedAt *time.Time `orm:"column:published_at,nullable,index:idx_schema_articles_status"`
+	Views       int        `orm:"column:views,index"`
+}
+
+func TestSchemaSQL(t *testing.T) {
+	tests := map[string][]string{
+		kindMySQL: {
+			"CREATE TABLE IF NOT EXISTS schema_articles (\n" +
+				"  id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,\n" +
+				"  author_id INT NOT NULL,\n" +
+				"  slug VARCHAR(120) NOT NULL,\n" +
+				"  body TEXT NOT NULL,\n" +
+				"  status VARCHAR(20) NOT NULL DEFAULT 'draft',\n" +
+				"  published_at TIMESTAMP(6) NULL,\n" +
+				"  views INT NOT NULL,\n" +
+				"  CONSTRAINT fk_schema_articles_author_id FOREIGN KEY (author_id) REFERENCES schema_authors(id) ON DELETE CASCADE\n" +
+				")",
+			"CREATE INDEX idx_schema_articles_status ON schema_articles (status, published_at)",
+			"CREATE INDEX idx_schema_articles_views ON schema_articles (views)",
+			"CREATE UNIQUE INDEX uq_schema_articles_slug ON schema_articles (author_id, slug)",
+		},
+		kindPostgres: {
+			"CREATE TABLE IF NOT EXISTS schema_articles (\n" +
+				"  id SERIAL PRIMARY KEY,\n" +
+				"  author_id INT NOT NULL,\n" +
+				"  slug VARCHAR(120) NOT NULL,\n" +
+				"  body TEXT NOT NULL,\n" +
+				"  status VARCHAR(20) NOT NULL DEFAULT 'draft',\n" +
+				"  published_at TIMESTAMP,\n" +
+				"  views INT NOT NULL,\n" +
+				"  CONSTRAINT fk_schema_articles_author_id FOREIGN KEY (author_id) REFERENCES schema_authors(id) ON DELETE CASCADE\n" +
+				")",
+			"CREATE INDEX IF NOT EXISTS idx_schema_articles_status ON schema_articles (status, published_at)",
+			"CREATE INDEX IF NOT EXISTS idx_schema_articles_views ON schema_articles (views)",
+			"CREATE UNIQUE INDEX IF NOT EXISTS uq_schema_articles_slug ON schema_articles (author_id, slug)",
+		},
+		kindSQLite: {
+			"CREATE TABLE IF NOT EXISTS schema_articles (\n" +
+				"  id INTEGER PRIMARY KEY AUTOINCREMENT,\n" +
+				"  author_id INT NOT NULL,\n" +
+				"  slug VARCHAR(120) NOT NULL,\n" +
+				"  body TEXT NOT NULL,\n" +
+				"  status VARCHAR(20) NOT NULL DEFAULT 'draft',\n" +
+				"  published_at TIMESTAMP,\n" +
+				"  views INT NOT NULL,\n" +
+				"  CONSTRAINT fk_schema_articles_author_id FOREIGN KEY (author_id) REFERENCES schema_authors(id) ON DELETE CASCADE\n" +
+				")",
+			"CREATE INDEX IF NOT EXISTS idx_schema_articles_status ON schema_articles (status, published_at)",
+			"CREATE INDEX IF NOT EXISTS idx_schema_articles_views ON schema_articles (views)",
+			"CREATE UNIQUE INDEX IF NOT EXISTS uq_schema_articles_slug ON schema_articles (author_id, slug)",
+		},
+	}
+	for kind, orm := range exprORMs(t) {
+		t.Run(kind, func(t *testing.T) {
+			stmts, err := SchemaSQL(orm, &schemaArticle{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := tests[kind]; !reflect.DeepEqual(stmts, want) {
+				t.Fatalf("DDL\n got %q\nwant %q", stmts, want)
+			}
+		})
+	}
+}
+
+func TestMigrateConstraints(t *testing.T) {
+	tests := []struct {
+		name string
+		// act writes next to an author with one article, slug "hello"
+		act     func(articles *Repository, author *schemaAuthor) error
+		wantErr bool
+		// articles counts the rows left
+		articles int64
+	}{
+		{"the same slug for another author", func(articles *Repository, _ *schemaAuthor) error {
+			other := &schemaAuthor{Email: "bo@example.com"}
+			if err := NewRepository(articles.orm, &schemaAuthor{}).Save(other); err != nil {
+				return err
+			}
+			return articles.Save(&schemaArticle{AuthorID: other.ID, Slug: "hello"})
+		}, false, 2},
+		{"a duplicate author and slug", func(articles *Repository, author *schemaAuthor) error {
+			return articles.Save(&schemaArticle{AuthorID: author.ID, Slug: "hello"})
+		}, true, 1},
+		{"an unknown author", func(articles *Repository, _ *schemaAuthor) error {
+			return articles.Save(&schemaArticle{AuthorID: 999, Slug: "other"})
+		}, true, 1},
+		{"deleting the author cascades", func(articles *Repository, author *schemaAuthor) error {
+			return NewRepository(articles.orm, &schemaAuthor{}).Delete(author)
+		}, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := NewSQLiteORM(":memory:")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer Close(db)
+			if err := Migrate(db, &schemaAuthor{}, &schemaArticle{}); err != nil {
+				t.Fatal(err)
+			}
+			author := &schemaAuthor{Email: "ann@example.com"}
+			if err := NewRepository(db, &schemaAuthor{}).Save(author); err != nil {
+				t.Fatal(err)
+			}
+			articles := NewRepository(db, &schemaArticle{})
+			if err := articles.Save(&schemaArticle{AuthorID: author.ID, Slug: "hello"}); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tt.act(articles, author); (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want an error: %v", err, tt.wantErr)
+			}
+			if n, err := articles.Count(); err != nil || n != tt.articles {
+				t.Fatalf("%d articles (%v), want %d", n, err, tt.articles)
+			}
+		})
+	}
+}
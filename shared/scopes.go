@@ -0,0 +1,302 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	ormcore "github.com/ESGI-M2/GO/orm"
+	"github.com/ESGI-M2/GO/orm/core/interfaces"
+)
+
+// SoftDeleteScope names the global scope that hides soft-deleted rows of models with
+// a `soft` column
+const SoftDeleteScope = "soft_delete"
+
+// GlobalScope builds the expression applied to every query on a model. It receives
+// the repository context, e.g. to read the current tenant.
+type GlobalScope func(ctx context.Context) Expr
+
+type namedScope struct {
+	name  string
+	scope GlobalScope
+}
+
+type scopeKey struct {
+	orm   interface{}
+	model reflect.Type
+}
+
+// globalScopes holds the scopes registered per ORM (see ormKey) and model type
+var (
+	scopesMu     sync.RWMutex
+	globalScopes = map[scopeKey][]namedScope{}
+)
+
+// AddGlobalScope registers a scope applied to every query a shared.Repository runs on
+// the model, until turned off with WithoutGlobalScope(name). Registering a name again
+// replaces the scope.
+func AddGlobalScope(orm ormcore.ORM, model interface{}, name string, scope GlobalScope) {
+	scopesMu.Lock()
+	defer scopesMu.Unlock()
+	key := scopeKey{ormKey(orm), modelType(model)}
+	for i, s := range globalScopes[key] {
+		if s.name == name {
+			globalScopes[key][i].scope = scope
+			return
+		}
+	}
+	globalScopes[key] = append(globalScopes[key], namedScope{name, scope})
+}
+
+// scopesOf lists the global scopes of a model, the soft-delete scope first
+func scopesOf(orm ormcore.ORM, meta *interfaces.ModelMetadata) []namedScope {
+	var scopes []namedScope
+	if meta.SoftDeletes {
+		column := meta.DeletedAt
+		scopes = append(scopes, namedScope{SoftDeleteScope, func(context.Context) Expr {
+			return func(q interfaces.QueryBuilder) interfaces.QueryBuilder {
+				return q.WhereNull(column)
+			}
+		}})
+	}
+	scopesMu.RLock()
+	defer scopesMu.RUnlock()
+	return append(scopes, globalScopes[scopeKey{ormKey(orm), meta.Type}]...)
+}
+
+// WithScopes returns a repository whose queries also apply the given expressions.
+// Scopes with parameters are plain functions returning an Expr:
+//
+//	func OlderThan(age int) shared.Expr {
+//		return func(q interfaces.QueryBuilder) interfaces.QueryBuilder {
+//			return q.Where("age", ">", age)
+//		}
+//	}
+//
+//	adults, err := repo.WithScopes(OlderThan(18)).FindAll()
+//
+// The library's Scope(name, args...) is left alone, so *Repository still satisfies
+// interfaces.Repository.
+func (r *Repository) WithScopes(scopes ...Expr) *Repository {
+	c := *r
+	c.scopes = append(append([]Expr(nil), r.scopes...), scopes...)
+	return &c
+}
+
+// WithoutGlobalScope returns a repository whose queries skip the named global scopes.
+// Naming a scope the model does not have is an error, returned by the first call.
+func (r *Repository) WithoutGlobalScope(names ...string) *Repository {
+	c := *r
+	c.without = append(append([]string(nil), r.without...), names...)
+	if c.err != nil {
+		return &c
+	}
+	for _, name := range names {
+		known := false
+		for _, s := range scopesOf(r.orm, r.meta) {
+			known = known || s.name == name
+		}
+		if !known {
+			c.err = fmt.Errorf("model %s has no global scope %q", r.meta.TableName, name)
+			break
+		}
+	}
+	return &c
+}
+
+// Find returns the row with the given primary key as a map, if the scopes allow it.
+// Unlike the library's Find, it does not return a trashed row; r.Repository.Find does.
+// It always queries, in a session too; Get returns the session's instance.
+func (r *Repository) Find(id interface{}) (interface{}, error) {
+	result, err := r.Query().Where(r.meta.PrimaryKey, "=", id).FindOne()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find record: %w", err)
+	}
+	if result == nil {
+		// a nil map would make a non-nil interface
+		return nil, nil
+	}
+	return result, nil
+}
+
+// FindAll returns every row the scopes allow
+func (r *Repository) FindAll() ([]interface{}, error) {
+	results, err := r.Query().Find()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find all records: %w", err)
+	}
+	return rowsToInterfaces(results), nil
+}
+
+// FindBy returns the rows matching every criterion that the scopes allow
+func (r *Repository) FindBy(criteria map[string]interface{}) ([]interface{}, error) {
+	results, err := r.where(criteria).Find()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find records by criteria: %w", err)
+	}
+	return rowsToInterfaces(results), nil
+}
+
+// FindOneBy returns the first row matching every criterion that the scopes allow, or
+// nil
+func (r *Repository) FindOneBy(criteria map[string]interface{}) (interface{}, error) {
+	result, err := r.where(criteria).FindOne()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find record by criteria: %w", err)
+	}
+	if result == nil {
+		// a nil map would make a non-nil interface
+		return nil, nil
+	}
+	return result, nil
+}
+
+// Count counts the rows the scopes allow
+func (r *Repository) Count() (int64, error) {
+	return r.Query().Count()
+}
+
+// Exists reports whether the scopes allow the row with the given primary key
+func (r *Repository) Exists(id interface{}) (bool, error) {
+	return r.Query().Where(r.meta.PrimaryKey, "=", id).Exists()
+}
+
+// Pluck returns one column of every row the scopes allow
+func (r *Repository) Pluck(field string) ([]interface{}, error) {
+	results, err := r.Query().Builder().Select(field).Find()
+	if err != nil {
+		return nil, fmt.Errorf("failed to pluck field: %w", err)
+	}
+	var values []interface{}
+	for _, result := range results {
+		if value, ok := result[field]; ok {
+			values = append(values, value)
+		}
+	}
+	return values, nil
+}
+
+// Value returns one column of the first row the scopes allow
+func (r *Repository) Value(field string) (interface{}, error) {
+	result, err := r.Query().Builder().Select(field).Limit(1).FindOne()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get value: %w", err)
+	}
+	return result[field], nil
+}
+
+// FindWithRelations returns the row with the given primary key, with the relations
+// eager loaded, if the scopes allow it, or nil. The related rows get their model's
+// global scopes too.
+func (r *Repository) FindWithRelations(id interface{}, relations ...string) (interface{}, error) {
+	result, err := withRelations(r.Query(), relations).Where(r.meta.PrimaryKey, "=", id).FindOne()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find record with relations: %w", err)
+	}
+	if result == nil {
+		return nil, nil
+	}
+	return result, nil
+}
+
+// FindAllWithRelations returns every row the scopes allow, with the relations eager
+// loaded
+func (r *Repository) FindAllWithRelations(relations ...string) ([]interface{}, error) {
+	results, err := withRelations(r.Query(), relations).Find()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find all records with relations: %w", err)
+	}
+	return rowsToInterfaces(results), nil
+}
+
+// FindByWithRelations returns the rows matching every criterion that the scopes allow,
+// with the relations eager loaded
+func (r *Repository) FindByWithRelations(criteria map[string]interface{}, relations ...string) ([]interface{}, error) {
+	results, err := withRelations(r.where(criteria), relations).Find()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find records by criteria with relations: %w", err)
+	}
+	return rowsToInterfaces(results), nil
+}
+
+// Chunk passes the rows the scopes allow to fn, size rows at a time in primary key
+// order, until fn returns an error
+func (r *Repository) Chunk(size int, fn func([]interface{}) error) error {
+	if r.err != nil {
+		return r.err
+	}
+	if size <= 0 {
+		return fmt.Errorf("chunk size must be positive, got %d", size)
+	}
+	q := r.Query().OrderBy(r.meta.PrimaryKey, "ASC").Limit(size)
+	for offset := 0; ; offset += size {
+		results, err := q.Offset(offset).Find()
+		if err != nil {
+			return fmt.Errorf("failed to get chunk: %w", err)
+		}
+		if len(results) == 0 {
+			return nil
+		}
+		if err := fn(rowsToInterfaces(results)); err != nil {
+			return err
+		}
+		if len(results) < size {
+			return nil
+		}
+	}
+}
+
+// Each passes the rows the scopes allow to fn one by one, loading them in chunks of
+// 100, until fn returns an error
+func (r *Repository) Each(fn func(interface{}) error) error {
+	return r.Chunk(100, func(rows []interface{}) error {
+		for _, row := range rows {
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// withRelations eager loads relations on a scoped query
+func withRelations(q *ModelQuery, relations []string) *ModelQuery {
+	for _, relation := range relations {
+		q = q.With(relation)
+	}
+	return q
+}
+
+// FindTrashed returns the soft-deleted rows the other scopes allow
+func (r *Repository) FindTrashed() ([]interface{}, error) {
+	results, err := r.Query().OnlyTrashed().Find()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find trashed records: %w", err)
+	}
+	return rowsToInterfaces(results), nil
+}
+
+// RestoreBy restores the soft-deleted rows matching every criterion
+func (r *Repository) RestoreBy(criteria map[string]interface{}) error {
+	_, err := r.where(criteria).Restore()
+	return err
+}
+
+// where adds an equality condition per criterion to a scoped query
+func (r *Repository) where(criteria map[string]interface{}) *ModelQuery {
+	q := r.Query()
+	for field, value := range criteria {
+		q = q.Where(field, "=", value)
+	}
+	return q
+}
+
+func rowsToInterfaces(rows []map[string]interface{}) []interface{} {
+	out := make([]interface{}, len(rows))
+	for i, row := range rows {
+		out[i] = row
+	}
+	return out
+}
@@ -0,0 +1,130 @@
+package shared
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/ESGI-M2/GO/orm/core/interfaces"
+)
+
+func TestScopedFindMissing(t *testing.T) {
+	db, err := NewSQLiteORM(":memory:", &User{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(db)
+	users := NewRepository(db, &User{})
+	tests := []struct {
+		name string
+		find func() (interface{}, error)
+	}{
+		{"Find", func() (interface{}, error) { return users.Find(1) }},
+		{"FindOneBy", func() (interface{}, error) { return users.FindOneBy(map[string]interface{}{"name": "nobody"}) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row, err := tt.find()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if row != nil {
+				t.Fatalf("got %#v, want a nil interface", row)
+			}
+		})
+	}
+}
+
+func TestGlobalScopesApplyToEveryRead(t *testing.T) {
+	db, err := NewSQLiteORM(":memory:", &User{}, &Post{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(db)
+	AddGlobalScope(db, &User{}, "adults", func(context.Context) Expr {
+		return func(q interfaces.QueryBuilder) interfaces.QueryBuilder { return q.Where("age", ">=", 18) }
+	})
+	users, posts := NewRepository(db, &User{}), NewRepository(db, &Post{})
+	var ids []int
+	for _, u := range []*User{{Name: "Ann", Age: 20}, {Name: "Bob", Age: 15}, {Name: "Cy", Age: 30}} {
+		u.Email = u.Name + "@example.com"
+		if err := users.Save(u); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, u.ID)
+		for _, title := range []string{"kept", "trashed"} {
+			p := &Post{Title: title, UserID: u.ID}
+			if err := posts.Save(p); err != nil {
+				t.Fatal(err)
+			}
+			if title == "trashed" {
+				if err := posts.SoftDelete(p); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+	if err := users.SoftDelete(&User{ID: ids[2]}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		read func() ([]interface{}, error)
+		// posts is set when the rows carry their Posts
+		posts bool
+	}{
+		{"FindAll", users.FindAll, false},
+		{"FindBy", func() ([]interface{}, error) { return users.FindBy(map[string]interface{}{}) }, false},
+		{"FindAllWithRelations", func() ([]interface{}, error) { return users.FindAllWithRelations("Posts") }, true},
+		{"FindByWithRelations", func() ([]interface{}, error) {
+			return users.FindByWithRelations(map[string]interface{}{}, "Posts")
+		}, true},
+		{"FindWithRelations", func() ([]interface{}, error) {
+			var rows []interface{}
+			for _, id := range ids {
+				row, err := users.FindWithRelations(id, "Posts")
+				if err != nil {
+					return nil, err
+				}
+				if row != nil {
+					rows = append(rows, row)
+				}
+			}
+			return rows, nil
+		}, true},
+		{"Chunk", func() ([]interface{}, error) {
+			var rows []interface{}
+			err := users.Chunk(1, func(chunk []interface{}) error {
+				rows = append(rows, chunk...)
+				return nil
+			})
+			return rows, err
+		}, false},
+		{"Each", func() ([]interface{}, error) {
+			var rows []interface{}
+			err := users.Each(func(row interface{}) error {
+				rows = append(rows, row)
+				return nil
+			})
+			return rows, err
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := tt.read()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != 1 || rows[0].(map[string]interface{})["name"] != "Ann" {
+				t.Fatalf("rows %v, want Ann only", rows)
+			}
+			if tt.posts {
+				loaded := rows[0].(map[string]interface{})["Posts"]
+				if n := reflect.ValueOf(loaded).Len(); n != 1 {
+					t.Fatalf("%d posts loaded, want the one not trashed", n)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package shared
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	ormcore "github.com/ESGI-M2/GO/orm"
+)
+
+// Session is a unit of work with an identity map: within it, each row is loaded into
+// one instance, which Get returns again without a query and which the session's
+// writes keep up to date. Use one session per request or per transaction
+// and drop it after; its instances are not refreshed by writes made elsewhere.
+//
+//	sess := shared.NewSession(db)
+//	users := sess.Repository(&User{})
+//	a, _ := users.Get(1)
+//	b, _ := users.Get(1) // a == b, no second query
+type Session struct {
+	orm ormcore.ORM
+
+	mu         sync.Mutex
+	identities map[identityKey]interface{}
+	// tracked is shared by the session's repositories, so Flush sees what changed
+	tracked *snapshots
+	added   []interface{}
+	removed []interface{}
+}
+
+type identityKey struct {
+	model reflect.Type
+	id    string
+}
+
+// NewSession starts a session on an ORM, or on the tx of a transaction
+func NewSession(orm ormcore.ORM) *Session {
+	return &Session{orm: orm, identities: make(map[identityKey]interface{}), tracked: newSnapshots()}
+}
+
+// Repository returns a repository on the model whose loads and writes go through the
+// session's identity map
+func (s *Session) Repository(model interface{}, opts ...RepositoryOption) *Repository {
+	r := NewRepository(s.orm, model, opts...)
+	r.session = s
+	r.tracked = s.tracked
+	r.tracking = true
+	return r
+}
+
+// Clear forgets every instance and its snapshot, so the next Get loads the rows again
+func (s *Session) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.identities = make(map[identityKey]interface{})
+	s.tracked.clear()
+}
+
+func identityOf(model reflect.Type, id interface{}) identityKey {
+	// the key is compared as text, as an id given as int matches a row's int64
+	return identityKey{model, fmt.Sprint(id)}
+}
+
+func (s *Session) lookup(key identityKey) (interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entity, ok := s.identities[key]
+	return entity, ok
+}
+
+// remember maps the key to entity unless another instance holds it already, and
+// returns the instance that does
+func (s *Session) remember(key identityKey, entity interface{}) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if known, ok := s.identities[key]; ok {
+		return known
+	}
+	s.identities[key] = entity
+	return entity
+}
+
+func (s *Session) forget(key identityKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.identities, key)
+}
+
+// Get returns the entity with the given primary key as a pointer to the model. In a
+// session, the instance it returns is the one already loaded or written by the
+// session, if any, without re-checking the scopes; otherwise the row is loaded.
+//
+//	u, err := users.Get(1)
+//	user := u.(*User)
+func (r *Repository) Get(id interface{}) (interface{}, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.session != nil {
+		if entity, ok := r.session.lookup(identityOf(r.meta.Type, id)); ok {
+			return entity, nil
+		}
+	}
+	entity := reflect.New(r.meta.Type).Interface()
+	if err := r.FindInto(id, entity); err != nil {
+		return nil, err
+	}
+	if r.session != nil {
+		if known := r.session.remember(identityOf(r.meta.Type, id), entity); known != entity {
+			return known, nil
+		}
+	}
+	return entity, nil
+}
+
+// syncIdentity records a write of the given columns of an entity in the session: the
+// entity becomes the row's instance, or, when the session already holds another
+// instance of the row, the written columns are copied to it
+func (r *Repository) syncIdentity(entity interface{}, columns []string) {
+	if r.session == nil {
+		return
+	}
+	pk, err := r.primaryKey(entity)
+	if err != nil {
+		return
+	}
+	known := r.session.remember(identityOf(r.meta.Type, pk.Interface()), entity)
+	if known == entity {
+		return
+	}
+	from, _ := structValue(entity)
+	to, _ := structValue(known)
+	if _, col, ok := versionField(entity); ok {
+		columns = append(append([]string(nil), columns...), col)
+	}
+	for _, col := range columns {
+		src, ok := fieldByColumn(from, col)
+		dst, ok2 := fieldByColumn(to, col)
+		if ok && ok2 {
+			dst.Set(src)
+		}
+	}
+	r.tracked.refresh(known, columns)
+}
+
+// forgetIdentity removes a deleted entity's row from the session
+func (r *Repository) forgetIdentity(entity interface{}) {
+	if r.session == nil {
+		return
+	}
+	if pk, err := r.primaryKey(entity); err == nil {
+		r.session.forget(identityOf(r.meta.Type, pk.Interface()))
+	}
+}
@@ -0,0 +1,103 @@
+package shared
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSessionIdentityMap(t *testing.T) {
+	tests := []struct {
+		name string
+		// act returns the instance the session holds for ann's row afterwards, nil
+		// when it has none, and the instance it is expected to be
+		act func(t *testing.T, sess *Session, users *Repository, ann *User) (got, want interface{})
+	}{
+		{"Get returns the loaded instance", func(t *testing.T, _ *Session, users *Repository, ann *User) (interface{}, interface{}) {
+			first := mustGet(t, users, ann.ID)
+			return mustGet(t, users, ann.ID), first
+		}},
+		{"Find still returns the row map", func(t *testing.T, _ *Session, users *Repository, ann *User) (interface{}, interface{}) {
+			first := mustGet(t, users, ann.ID)
+			found, err := users.Find(ann.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if row, ok := found.(map[string]interface{}); !ok || row["name"] != "Ann" {
+				t.Fatalf("Find = %#v, want the row map", found)
+			}
+			return mustGet(t, users, ann.ID), first
+		}},
+		{"Save makes the saved entity the instance", func(t *testing.T, _ *Session, users *Repository, _ *User) (interface{}, interface{}) {
+			cy := &User{Name: "Cy", Email: "cy@example.com"}
+			if err := users.Save(cy); err != nil {
+				t.Fatal(err)
+			}
+			return mustGet(t, users, cy.ID), cy
+		}},
+		{"an update through a copy reaches the instance", func(t *testing.T, _ *Session, users *Repository, ann *User) (interface{}, interface{}) {
+			first := mustGet(t, users, ann.ID).(*User)
+			if err := users.UpdateFields(&User{ID: ann.ID, Name: "Anna"}, "name"); err != nil {
+				t.Fatal(err)
+			}
+			if first.Name != "Anna" {
+				t.Errorf("instance name %q, want Anna", first.Name)
+			}
+			return mustGet(t, users, ann.ID), first
+		}},
+		{"Clear loads the row again", func(t *testing.T, sess *Session, users *Repository, ann *User) (interface{}, interface{}) {
+			first := mustGet(t, users, ann.ID)
+			sess.Clear()
+			if again := mustGet(t, users, ann.ID); again == first {
+				t.Error("Clear kept the instance")
+			}
+			return nil, nil
+		}},
+		{"Find gives nil after a soft delete", func(t *testing.T, _ *Session, users *Repository, ann *User) (interface{}, interface{}) {
+			if err := users.SoftDelete(mustGet(t, users, ann.ID)); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := users.Get(ann.ID); !errors.Is(err, ErrRecordNotFound) {
+				t.Errorf("Get error %v, want ErrRecordNotFound", err)
+			}
+			found, err := users.Find(ann.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return found, nil
+		}},
+		{"Find gives nil for a missing row", func(t *testing.T, _ *Session, users *Repository, _ *User) (interface{}, interface{}) {
+			found, err := users.Find(999)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return found, nil
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := NewSQLiteORM(":memory:", &User{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer Close(db)
+			ann := &User{Name: "Ann", Email: "ann@example.com"}
+			if err := NewRepository(db, &User{}).Save(ann); err != nil {
+				t.Fatal(err)
+			}
+			sess := NewSession(db)
+			got, want := tt.act(t, sess, sess.Repository(&User{}), ann)
+			if got != want {
+				t.Fatalf("instance %p, want %p", got, want)
+			}
+		})
+	}
+}
+
+func mustGet(t *testing.T, r *Repository, id interface{}) interface{} {
+	t.Helper()
+	entity, err := r.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entity
+}
@@ -0,0 +1,245 @@
+package shared
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	ormcore "github.com/ESGI-M2/GO/orm"
+	"github.com/ESGI-M2/GO/orm/core/interfaces"
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteDialect implements interfaces.Dialect on top of the pure-Go modernc.org/sqlite
+// driver, so it builds without cgo. ConnectionConfig.Database is the file path, or
+// ":memory:" for a throwaway database.
+type SQLiteDialect struct {
+	db *sql.DB
+}
+
+// NewSQLiteDialect creates a new SQLite dialect instance
+func NewSQLiteDialect() *SQLiteDialect {
+	return &SQLiteDialect{}
+}
+
+// NewSQLiteORM opens (or creates) the SQLite database at path, registers the models
+// and creates their tables. Pass ":memory:" to run without any file or server.
+func NewSQLiteORM(path string, models ...interface{}) (ormcore.ORM, error) {
+	orm := ormcore.New(NewSQLiteDialect())
+	if err := orm.Connect(ormcore.ConnectionConfig{Database: path}); err != nil {
+		return nil, err
+	}
+	for _, model := range models {
+		if err := orm.RegisterModel(model); err != nil {
+			orm.Close()
+			return nil, fmt.Errorf("failed to register model %T: %w", model, err)
+		}
+	}
+	if err := orm.Migrate(); err != nil {
+		orm.Close()
+		return nil, fmt.Errorf("failed to migrate tables: %w", err)
+	}
+	return orm, nil
+}
+
+// Connect opens the database file named by config.Database
+func (s *SQLiteDialect) Connect(config interfaces.ConnectionConfig) error {
+	path := config.Database
+	if path == "" {
+		path = ":memory:"
+	}
+
+	db, err := sql.Open("sqlite", path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_time_format=sqlite")
+	if err != nil {
+		return fmt.Errorf("failed to open SQLite: %w", err)
+	}
+
+	// SQLite serialises writers anyway, and every connection to ":memory:" would
+	// otherwise see its own empty database.
+	db.SetMaxOpenConns(1)
+	if config.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(time.Duration(config.ConnMaxLifetime) * time.Second)
+	}
+
+	s.db = db
+	if err := s.Ping(); err != nil {
+		return fmt.Errorf("failed to ping SQLite: %w", err)
+	}
+	return nil
+}
+
+// Close closes the database connection
+func (s *SQLiteDialect) Close() error {
+	if s.db != nil {
+		return s.db.Close()
+	}
+	return nil
+}
+
+// Ping tests the database connection
+func (s *SQLiteDialect) Ping() error {
+	if s.db == nil {
+		return fmt.Errorf("database connection not established")
+	}
+	return s.db.Ping()
+}
+
+// Exec executes a query without returning rows
+func (s *SQLiteDialect) Exec(query string, args ...interface{}) (sql.Result, error) {
+	if s.db == nil {
+		return nil, fmt.Errorf("database connection not established")
+	}
+	return s.db.Exec(query, args...)
+}
+
+// Query executes a query that returns rows
+func (s *SQLiteDialect) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	if s.db == nil {
+		return nil, fmt.Errorf("database connection not established")
+	}
+	return s.db.Query(query, args...)
+}
+
+// QueryRow executes a query that returns a single row
+func (s *SQLiteDialect) QueryRow(query string, args ...interface{}) *sql.Row {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.QueryRow(query, args...)
+}
+
+// Begin starts a new transaction
+func (s *SQLiteDialect) Begin() (interfaces.Transaction, error) {
+	return s.BeginTx(context.Background(), nil)
+}
+
+// BeginTx starts a new transaction with options
+func (s *SQLiteDialect) BeginTx(ctx context.Context, opts *sql.TxOptions) (interfaces.Transaction, error) {
+	if s.db == nil {
+		return nil, fmt.Errorf("database connection not established")
+	}
+	return s.db.BeginTx(ctx, opts)
+}
+
+// CreateTable creates a table with the given columns
+func (s *SQLiteDialect) CreateTable(tableName string, columns []interfaces.Column) error {
+	var columnDefs []string
+	for _, col := range columns {
+		columnDefs = append(columnDefs, s.buildColumnDefinition(col))
+	}
+
+	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n  %s\n)",
+		tableName, strings.Join(columnDefs, ",\n  "))
+
+	_, err := s.Exec(query)
+	return err
+}
+
+// DropTable drops a table
+func (s *SQLiteDialect) DropTable(tableName string) error {
+	_, err := s.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", tableName))
+	return err
+}
+
+// TableExists checks if a table exists
+func (s *SQLiteDialect) TableExists(tableName string) (bool, error) {
+	var count int
+	err := s.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", tableName).Scan(&count)
+	return count > 0, err
+}
+
+// GetSQLType maps Go types to SQLite column types
+func (s *SQLiteDialect) GetSQLType(goType reflect.Type) string {
+	switch goType.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "INTEGER"
+	case reflect.Float32, reflect.Float64:
+		return "REAL"
+	case reflect.Bool:
+		return "BOOLEAN"
+	case reflect.Struct:
+		if goType == reflect.TypeOf(time.Time{}) {
+			return "DATETIME"
+		}
+		return "TEXT"
+	case reflect.Slice:
+		if goType.Elem().Kind() == reflect.Uint8 {
+			return "BLOB"
+		}
+		return "TEXT"
+	default:
+		return "TEXT"
+	}
+}
+
+// GetPlaceholder returns the placeholder for parameterized queries
+func (s *SQLiteDialect) GetPlaceholder(index int) string {
+	return "?"
+}
+
+// FullTextSearch returns a LIKE-based search, since FTS5 needs a dedicated virtual table
+func (s *SQLiteDialect) FullTextSearch(field, query string) string {
+	return fmt.Sprintf("%s LIKE '%%%s%%'", field, strings.ReplaceAll(query, "'", "''"))
+}
+
+// GetRandomFunction returns SQLite RANDOM() function
+func (s *SQLiteDialect) GetRandomFunction() string {
+	return "RANDOM()"
+}
+
+// GetDateFunction returns SQLite CURRENT_TIMESTAMP
+func (s *SQLiteDialect) GetDateFunction() string {
+	return "CURRENT_TIMESTAMP"
+}
+
+// GetJSONExtract returns SQLite json_extract function
+func (s *SQLiteDialect) GetJSONExtract() string {
+	return "json_extract"
+}
+
+// buildColumnDefinition builds a SQLite column definition
+func (s *SQLiteDialect) buildColumnDefinition(col interfaces.Column) string {
+	// SQLite only auto-increments a column declared exactly INTEGER PRIMARY KEY
+	if col.PrimaryKey && col.AutoIncrement {
+		return fmt.Sprintf("%s INTEGER PRIMARY KEY AUTOINCREMENT", col.Name)
+	}
+
+	parts := []string{col.Name, col.Type}
+
+	if !col.Nullable {
+		parts = append(parts, "NOT NULL")
+	}
+
+	if col.Default != nil {
+		if str, ok := col.Default.(string); ok {
+			parts = append(parts, fmt.Sprintf("DEFAULT '%s'", strings.ReplaceAll(str, "'", "''")))
+		} else {
+			parts = append(parts, fmt.Sprintf("DEFAULT %v", col.Default))
+		}
+	}
+
+	if col.PrimaryKey {
+		parts = append(parts, "PRIMARY KEY")
+	}
+
+	if col.Unique {
+		parts = append(parts, "UNIQUE")
+	}
+
+	if col.ForeignKey != nil {
+		fkDef := fmt.Sprintf("REFERENCES %s(%s)", col.ForeignKey.ReferencedTable, col.ForeignKey.ReferencedColumn)
+		if col.ForeignKey.OnDelete != "" {
+			fkDef += fmt.Sprintf(" ON DELETE %s", col.ForeignKey.OnDelete)
+		}
+		if col.ForeignKey.OnUpdate != "" {
+			fkDef += fmt.Sprintf(" ON UPDATE %s", col.ForeignKey.OnUpdate)
+		}
+		parts = append(parts, fkDef)
+	}
+
+	return strings.Join(parts, " ")
+}
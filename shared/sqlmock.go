@@ -0,0 +1,268 @@
+package shared
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	ormcore "github.com/ESGI-M2/GO/orm"
+	"github.com/ESGI-M2/GO/orm/core/interfaces"
+)
+
+// SQLMockDialect implements interfaces.Dialect on top of go-sqlmock. Every statement the
+// ORM sends must be declared up front through Mock (ExpectQuery, ExpectExec, ExpectBegin,
+// ...), which makes error paths such as duplicate keys or deadlocks deterministic.
+// It also implements interfaces.QueryLogger, recording each statement it receives.
+type SQLMockDialect struct {
+	Mock sqlmock.Sqlmock
+
+	db   *sql.DB
+	mu   sync.Mutex
+	logs []interfaces.QueryLog
+	// postgres numbers placeholders as $1, $2, ... instead of ?
+	postgres bool
+}
+
+// NewSQLMockDialect creates a dialect whose expectations are matched as regular expressions
+func NewSQLMockDialect() (*SQLMockDialect, error) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sqlmock: %w", err)
+	}
+	return &SQLMockDialect{Mock: mock, db: db}, nil
+}
+
+// NewSQLMockORM returns a connected ORM backed by a fresh SQLMockDialect. Models are
+// registered but no table is created, so no DDL expectation is needed.
+func NewSQLMockORM(models ...interface{}) (ormcore.ORM, *SQLMockDialect, error) {
+	d, err := NewSQLMockDialect()
+	if err != nil {
+		return nil, nil, err
+	}
+	orm := ormcore.New(d)
+	if err := orm.Connect(ormcore.ConnectionConfig{}); err != nil {
+		return nil, nil, err
+	}
+	for _, model := range models {
+		if err := orm.RegisterModel(model); err != nil {
+			return nil, nil, fmt.Errorf("failed to register model %T: %w", model, err)
+		}
+	}
+	return orm, d, nil
+}
+
+// NewPostgresSQLMockORM is NewSQLMockORM with Postgres placeholders, so that the SQL
+// the ORM renders for Postgres, $n numbering included, can be checked without a server
+func NewPostgresSQLMockORM(models ...interface{}) (ormcore.ORM, *SQLMockDialect, error) {
+	orm, d, err := NewSQLMockORM(models...)
+	if err != nil {
+		return nil, nil, err
+	}
+	d.postgres = true
+	return orm, d, nil
+}
+
+// ExpectationsWereMet reports any expectation the ORM did not consume
+func (s *SQLMockDialect) ExpectationsWereMet() error {
+	return s.Mock.ExpectationsWereMet()
+}
+
+// Log records a query log entry
+func (s *SQLMockDialect) Log(log interfaces.QueryLog) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logs = append(s.logs, log)
+}
+
+// GetLogs returns the statements received so far, in order
+func (s *SQLMockDialect) GetLogs() []interfaces.QueryLog {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]interfaces.QueryLog(nil), s.logs...)
+}
+
+// ClearLogs forgets the recorded statements
+func (s *SQLMockDialect) ClearLogs() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logs = nil
+}
+
+// record logs a statement together with its outcome
+func (s *SQLMockDialect) record(query string, args []interface{}, start time.Time, err error) {
+	s.Log(interfaces.QueryLog{
+		SQL:      query,
+		Args:     args,
+		Duration: time.Since(start),
+		Time:     start,
+		Error:    err,
+	})
+}
+
+// Connect is a no-op, the mock connection is opened by NewSQLMockDialect
+func (s *SQLMockDialect) Connect(config interfaces.ConnectionConfig) error {
+	return nil
+}
+
+// Close closes the mock connection
+func (s *SQLMockDialect) Close() error {
+	return s.db.Close()
+}
+
+// Ping is a no-op for the mock connection
+func (s *SQLMockDialect) Ping() error {
+	return nil
+}
+
+// Exec executes a query without returning rows
+func (s *SQLMockDialect) Exec(query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	res, err := s.db.Exec(query, args...)
+	s.record(query, args, start, err)
+	return res, err
+}
+
+// Query executes a query that returns rows
+func (s *SQLMockDialect) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := s.db.Query(query, args...)
+	s.record(query, args, start, err)
+	return rows, err
+}
+
+// QueryRow executes a query that returns a single row
+func (s *SQLMockDialect) QueryRow(query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := s.db.QueryRow(query, args...)
+	s.record(query, args, start, row.Err())
+	return row
+}
+
+// Begin starts a new transaction
+func (s *SQLMockDialect) Begin() (interfaces.Transaction, error) {
+	return s.BeginTx(context.Background(), nil)
+}
+
+// BeginTx starts a new transaction with options
+func (s *SQLMockDialect) BeginTx(ctx context.Context, opts *sql.TxOptions) (interfaces.Transaction, error) {
+	start := time.Now()
+	tx, err := s.db.BeginTx(ctx, opts)
+	s.record("BEGIN", nil, start, err)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlMockTransaction{tx: tx, dialect: s}, nil
+}
+
+// CreateTable sends a CREATE TABLE statement, expect it with ExpectExec("CREATE TABLE")
+func (s *SQLMockDialect) CreateTable(tableName string, columns []interfaces.Column) error {
+	var columnDefs []string
+	for _, col := range columns {
+		columnDefs = append(columnDefs, fmt.Sprintf("%s %s", col.Name, col.Type))
+	}
+	_, err := s.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", tableName, strings.Join(columnDefs, ", ")))
+	return err
+}
+
+// DropTable sends a DROP TABLE statement, expect it with ExpectExec("DROP TABLE")
+func (s *SQLMockDialect) DropTable(tableName string) error {
+	_, err := s.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", tableName))
+	return err
+}
+
+// TableExists sends a COUNT query, expect it with ExpectQuery("information_schema.tables")
+func (s *SQLMockDialect) TableExists(tableName string) (bool, error) {
+	var count int
+	err := s.QueryRow("SELECT COUNT(*) FROM information_schema.tables WHERE table_name = ?", tableName).Scan(&count)
+	return count > 0, err
+}
+
+// GetSQLType returns the SQL type for a given Go type
+func (s *SQLMockDialect) GetSQLType(goType reflect.Type) string {
+	switch goType.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "INT"
+	case reflect.Float32, reflect.Float64:
+		return "DOUBLE"
+	case reflect.Bool:
+		return "BOOLEAN"
+	case reflect.String:
+		return "VARCHAR(255)"
+	default:
+		return "TEXT"
+	}
+}
+
+// GetPlaceholder returns the placeholder for parameterized queries
+func (s *SQLMockDialect) GetPlaceholder(index int) string {
+	if s.postgres {
+		return fmt.Sprintf("$%d", index+1)
+	}
+	return "?"
+}
+
+// FullTextSearch returns MySQL full-text search syntax
+func (s *SQLMockDialect) FullTextSearch(field, query string) string {
+	return fmt.Sprintf("MATCH(%s) AGAINST('%s' IN BOOLEAN MODE)", field, query)
+}
+
+// GetRandomFunction returns the MySQL RAND() function
+func (s *SQLMockDialect) GetRandomFunction() string {
+	return "RAND()"
+}
+
+// GetDateFunction returns the NOW() function
+func (s *SQLMockDialect) GetDateFunction() string {
+	return "NOW()"
+}
+
+// GetJSONExtract returns the MySQL JSON_EXTRACT function
+func (s *SQLMockDialect) GetJSONExtract() string {
+	return "JSON_EXTRACT"
+}
+
+// sqlMockTransaction records statements run inside a mocked transaction
+type sqlMockTransaction struct {
+	tx      *sql.Tx
+	dialect *SQLMockDialect
+}
+
+func (t *sqlMockTransaction) Commit() error {
+	start := time.Now()
+	err := t.tx.Commit()
+	t.dialect.record("COMMIT", nil, start, err)
+	return err
+}
+
+func (t *sqlMockTransaction) Rollback() error {
+	start := time.Now()
+	err := t.tx.Rollback()
+	t.dialect.record("ROLLBACK", nil, start, err)
+	return err
+}
+
+func (t *sqlMockTransaction) Exec(query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	res, err := t.tx.Exec(query, args...)
+	t.dialect.record(query, args, start, err)
+	return res, err
+}
+
+func (t *sqlMockTransaction) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := t.tx.Query(query, args...)
+	t.dialect.record(query, args, start, err)
+	return rows, err
+}
+
+func (t *sqlMockTransaction) QueryRow(query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := t.tx.QueryRow(query, args...)
+	t.dialect.record(query, args, start, row.Err())
+	return row
+}
@@ -0,0 +1,61 @@
+package shared
+
+import (
+	"reflect"
+	"strings"
+)
+
+// ormTag holds the parts of an `orm:"..."` struct tag. Bare flags such as "pk" map to "".
+type ormTag map[string]string
+
+// parseTag splits the orm tag of a struct field the same way the library does
+func parseTag(field reflect.StructField) ormTag {
+	tag := ormTag{}
+	for _, part := range strings.Split(field.Tag.Get("orm"), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if kv := strings.SplitN(part, ":", 2); len(kv) == 2 {
+			tag[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		} else {
+			tag[part] = ""
+		}
+	}
+	return tag
+}
+
+// has reports whether the tag contains the key, with or without a value
+func (t ormTag) has(key string) bool {
+	_, ok := t[key]
+	return ok
+}
+
+// columnName returns the column a struct field is stored in, and false for fields
+// that are not columns (untagged, "-", or relations)
+func columnName(field reflect.StructField) (string, bool) {
+	raw := field.Tag.Get("orm")
+	if raw == "" || raw == "-" {
+		return "", false
+	}
+	tag := parseTag(field)
+	if tag.has("relation") {
+		return "", false
+	}
+	if name := tag["column"]; name != "" {
+		return name, true
+	}
+	return strings.ToLower(field.Name), true
+}
+
+// structValue dereferences a pointer to a struct, returning false for anything else
+func structValue(entity interface{}) (reflect.Value, bool) {
+	v := reflect.ValueOf(entity)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return reflect.Value{}, false
+		}
+		v = v.Elem()
+	}
+	return v, v.Kind() == reflect.Struct
+}
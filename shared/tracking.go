@@ -0,0 +1,322 @@
+package shared
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// ErrRecordNotFound is returned by FindInto when no row matches
+var ErrRecordNotFound = errors.New("record not found")
+
+// snapshots remembers the column values of tracked entities as they were last loaded
+// or written, keyed by entity pointer
+type snapshots struct {
+	mu   sync.Mutex
+	rows map[interface{}]map[string]interface{}
+}
+
+func newSnapshots() *snapshots {
+	return &snapshots{rows: make(map[interface{}]map[string]interface{})}
+}
+
+func (s *snapshots) has(entity interface{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.rows[entity]
+	return ok
+}
+
+func (s *snapshots) get(entity interface{}) (map[string]interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	row, ok := s.rows[entity]
+	return row, ok
+}
+
+func (s *snapshots) set(entity interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rows[entity] = columnValues(entity)
+}
+
+// refresh records the written columns of an entity that is already tracked
+func (s *snapshots) refresh(entity interface{}, columns []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot, ok := s.rows[entity]
+	if !ok {
+		return
+	}
+	values := columnValues(entity)
+	for _, col := range columns {
+		snapshot[col] = values[col]
+	}
+}
+
+// checkpoint returns a function putting the entity's snapshot back as it is now, or
+// untracking the entity if it is not tracked
+func (s *snapshots) checkpoint(entity interface{}) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot, ok := s.rows[entity]
+	saved := make(map[string]interface{}, len(snapshot))
+	for col, value := range snapshot {
+		saved[col] = value
+	}
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if ok {
+			s.rows[entity] = saved
+		} else {
+			delete(s.rows, entity)
+		}
+	}
+}
+
+// clear drops every snapshot
+func (s *snapshots) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rows = make(map[interface{}]map[string]interface{})
+}
+
+func (s *snapshots) forget(entity interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.rows, entity)
+}
+
+// FindInto loads the row with the given primary key into dst. A repository created
+// WithTracking, or by a Session, tracks it, so that a later Update only writes the
+// fields that changed.
+func (r *Repository) FindInto(id interface{}, dst interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	row, err := r.Query().Where(r.meta.PrimaryKey, "=", id).FindOne()
+	if err != nil {
+		return fmt.Errorf("failed to find record: %w", err)
+	}
+	if row == nil {
+		return fmt.Errorf("%w: %s %v", ErrRecordNotFound, r.meta.TableName, id)
+	}
+	if err := Decode(row, dst); err != nil {
+		return err
+	}
+	if err := r.callHook("AfterFind", dst); err != nil {
+		return err
+	}
+	if r.tracking {
+		r.Track(dst)
+	}
+	return nil
+}
+
+// Track snapshots an entity loaded by other means, e.g. through Decode
+func (r *Repository) Track(entity interface{}) {
+	r.tracked.set(entity)
+}
+
+// Forget stops tracking an entity; its next Update writes every column again
+func (r *Repository) Forget(entity interface{}) {
+	r.tracked.forget(entity)
+}
+
+// Dirty lists the columns of a tracked entity that changed since it was loaded or last
+// written; an untracked entity reports every column
+func (r *Repository) Dirty(entity interface{}) []string {
+	current := columnValues(entity)
+	snapshot, ok := r.tracked.get(entity)
+
+	var dirty []string
+	for _, col := range r.meta.Columns {
+		if col.Name == r.meta.PrimaryKey {
+			continue
+		}
+		if !ok || !reflect.DeepEqual(current[col.Name], snapshot[col.Name]) {
+			dirty = append(dirty, col.Name)
+		}
+	}
+	return dirty
+}
+
+// UpdateFields writes only the named columns of the entity, plus its autoUpdateTime
+// columns, whatever else changed. Like a raw column write, it runs no hooks.
+func (r *Repository) UpdateFields(entity interface{}, columns ...string) error {
+	if r.err != nil {
+		return r.err
+	}
+	if len(columns) == 0 {
+		return fmt.Errorf("UpdateFields needs at least one column")
+	}
+	err := r.atomically(false, func(r *Repository) error {
+		before := r.previous(entity)
+		r.stamp(entity, false)
+		columns = append(append([]string(nil), columns...), autoUpdateColumns(entity)...)
+		if err := r.updateColumns(entity, columns); err != nil {
+			return err
+		}
+		r.publish(Event{Type: EventUpdated, Before: before}, entity)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	r.tracked.refresh(entity, columns)
+	return nil
+}
+
+// Select returns a repository whose Save and Update only write the given columns
+func (r *Repository) Select(columns ...string) *Repository {
+	c := *r
+	c.selected = columns
+	return &c
+}
+
+// Omit returns a repository whose Save and Update never write the given columns
+func (r *Repository) Omit(columns ...string) *Repository {
+	c := *r
+	c.omitted = columns
+	return &c
+}
+
+// filterColumns applies Select and Omit to a column list
+func (r *Repository) filterColumns(columns []string) []string {
+	if len(r.selected) > 0 {
+		columns = intersect(columns, r.selected)
+	}
+	var kept []string
+	for _, col := range columns {
+		if !contains(r.omitted, col) {
+			kept = append(kept, col)
+		}
+	}
+	return kept
+}
+
+// insertColumns inserts the given columns of the entity and sets its auto-increment key
+func (r *Repository) insertColumns(entity interface{}, columns []string) error {
+	v, ok := structValue(entity)
+	if !ok {
+		return fmt.Errorf("entity must be a pointer to a struct, got %T", entity)
+	}
+	d := r.orm.GetDialect()
+
+	var names, marks []string
+	var args []interface{}
+	for _, col := range columns {
+		field, ok := fieldByColumn(v, col)
+		if !ok {
+			return fmt.Errorf("no field is stored in column %s", col)
+		}
+		names = append(names, col)
+		marks = append(marks, d.GetPlaceholder(len(args)))
+		args = append(args, field.Interface())
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", r.meta.TableName, strings.Join(names, ", "), strings.Join(marks, ", "))
+
+	var autoField reflect.Value
+	if r.meta.AutoIncrement != "" {
+		autoField, _ = fieldByColumn(v, r.meta.AutoIncrement)
+	}
+
+	var id int64
+	if dialectKind(d) == kindPostgres && autoField.IsValid() {
+		if err := d.QueryRow(query+" RETURNING "+r.meta.AutoIncrement, args...).Scan(&id); err != nil {
+			return fmt.Errorf("failed to insert entity: %w", err)
+		}
+	} else {
+		result, err := d.Exec(query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to insert entity: %w", err)
+		}
+		if autoField.IsValid() {
+			if id, err = result.LastInsertId(); err != nil {
+				return fmt.Errorf("failed to read inserted id: %w", err)
+			}
+		}
+	}
+	if autoField.IsValid() && autoField.CanInt() {
+		autoField.SetInt(id)
+	}
+	r.invalidateCache()
+	r.syncIdentity(entity, columns)
+	return nil
+}
+
+// columnValues captures the value stored for each column. Valuers such as JSON are
+// captured through Value and pointers by their target, so that changes made in place
+// are noticed.
+func columnValues(entity interface{}) map[string]interface{} {
+	values := map[string]interface{}{}
+	v, ok := structValue(entity)
+	if !ok {
+		return values
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		col, ok := columnName(t.Field(i))
+		if !ok {
+			continue
+		}
+		field := v.Field(i)
+		switch {
+		case field.Type().Implements(reflect.TypeOf((*driver.Valuer)(nil)).Elem()):
+			value, err := field.Interface().(driver.Valuer).Value()
+			if err != nil {
+				value = err.Error()
+			}
+			values[col] = value
+		case field.Kind() == reflect.Ptr:
+			if field.IsNil() {
+				values[col] = nil
+			} else {
+				values[col] = field.Elem().Interface()
+			}
+		default:
+			values[col] = field.Interface()
+		}
+	}
+	return values
+}
+
+// autoUpdateColumns lists the columns tagged autoUpdateTime
+func autoUpdateColumns(entity interface{}) []string {
+	var columns []string
+	v, ok := structValue(entity)
+	if !ok {
+		return nil
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if col, ok := columnName(t.Field(i)); ok && parseTag(t.Field(i)).has("autoUpdateTime") {
+			columns = append(columns, col)
+		}
+	}
+	return columns
+}
+
+// intersect keeps the elements of a that are also in b, in the order of a
+func intersect(a, b []string) []string {
+	var out []string
+	for _, s := range a {
+		if contains(b, s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package shared
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	ormcore "github.com/ESGI-M2/GO/orm"
+)
+
+type trackItem struct {
+	ID        int       `table:"track_items" orm:"pk,auto"`
+	Name      string    `orm:"column:name"`
+	Stock     int       `orm:"column:stock"`
+	UpdatedAt time.Time `orm:"column:updated_at,autoUpdateTime"`
+}
+
+// trackORM opens a database with one saved item, Pen with a stock of 10
+func trackORM(t *testing.T) (ormcore.ORM, int) {
+	t.Helper()
+	db, err := NewSQLiteORM(":memory:", &trackItem{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { Close(db) })
+	item := &trackItem{Name: "Pen", Stock: 10}
+	if err := NewRepository(db, &trackItem{}).Save(item); err != nil {
+		t.Fatal(err)
+	}
+	return db, item.ID
+}
+
+func TestDirty(t *testing.T) {
+	all := []string{"name", "stock", "updated_at"}
+	tests := []struct {
+		name   string
+		opts   []RepositoryOption
+		change func(r *Repository, item *trackItem)
+		want   []string
+	}{
+		{"tracked and unchanged", []RepositoryOption{WithTracking()}, func(*Repository, *trackItem) {}, nil},
+		{"tracked and changed", []RepositoryOption{WithTracking()}, func(_ *Repository, it *trackItem) { it.Name = "Ink" }, []string{"name"}},
+		{"untracked", nil, func(*Repository, *trackItem) {}, all},
+		{"forgotten", []RepositoryOption{WithTracking()}, func(r *Repository, it *trackItem) { r.Forget(it) }, all},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, id := trackORM(t)
+			items := NewRepository(db, &trackItem{}, tt.opts...)
+			item := &trackItem{}
+			if err := items.FindInto(id, item); err != nil {
+				t.Fatal(err)
+			}
+			tt.change(items, item)
+			if got := items.Dirty(item); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Dirty = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartialUpdates(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(db ormcore.ORM, id int) error
+		// the stock is set to 3 behind the repository's back before the write
+		want string
+	}{
+		{"Update writes the changed fields of a tracked entity", func(db ormcore.ORM, id int) error {
+			items := NewRepository(db, &trackItem{}, WithTracking())
+			item := &trackItem{}
+			if err := items.FindInto(id, item); err != nil {
+				return err
+			}
+			setStock(t, db, 3)
+			item.Name = "Ink"
+			return items.Update(item)
+		}, "Ink:3"},
+		{"Update writes every field of an untracked entity", func(db ormcore.ORM, id int) error {
+			items := NewRepository(db, &trackItem{})
+			item := &trackItem{}
+			if err := items.FindInto(id, item); err != nil {
+				return err
+			}
+			setStock(t, db, 3)
+			item.Name = "Ink"
+			return items.Update(item)
+		}, "Ink:10"},
+		{"UpdateFields writes the named columns only", func(db ormcore.ORM, id int) error {
+			setStock(t, db, 3)
+			item := &trackItem{ID: id, Name: "Ink", Stock: 99}
+			return NewRepository(db, &trackItem{}).UpdateFields(item, "name")
+		}, "Ink:3"},
+		{"Select", func(db ormcore.ORM, id int) error {
+			setStock(t, db, 3)
+			return NewRepository(db, &trackItem{}).Select("name").Update(&trackItem{ID: id, Name: "Ink", Stock: 99})
+		}, "Ink:3"},
+		{"Omit", func(db ormcore.ORM, id int) error {
+			setStock(t, db, 3)
+			return NewRepository(db, &trackItem{}).Omit("stock").Update(&trackItem{ID: id, Name: "Ink", Stock: 99})
+		}, "Ink:3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, id := trackORM(t)
+			if err := tt.write(db, id); err != nil {
+				t.Fatal(err)
+			}
+			row, err := db.Query(&trackItem{}).Where("id", "=", id).FindOne()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprintf("%v:%v", row["name"], row["stock"]); got != tt.want {
+				t.Fatalf("row %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func setStock(t *testing.T, db ormcore.ORM, stock int) {
+	t.Helper()
+	if _, err := db.GetDialect().Exec("UPDATE track_items SET stock = ?", stock); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdateFieldsKeepsTheCallersSlice(t *testing.T) {
+	db, id := trackORM(t)
+	columns := make([]string, 1, 4)
+	columns[0] = "name"
+	if err := NewRepository(db, &trackItem{}).UpdateFields(&trackItem{ID: id, Name: "Ink"}, columns...); err != nil {
+		t.Fatal(err)
+	}
+	if spare := columns[:2][1]; spare != "" {
+		t.Fatalf("UpdateFields appended %q to the caller's slice", spare)
+	}
+}